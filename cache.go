@@ -0,0 +1,264 @@
+package gogo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/guillermo/gogo/fs"
+)
+
+// modTimeGranularity is the coarsest resolution of modification times among
+// common filesystems, the 2 seconds of FAT
+const modTimeGranularity = 2 * time.Second
+
+// fileCache keeps the content and the syntax tree of the files touched by a
+// project, so consecutive operations on the same file don't read and parse it
+// again. Entries are checked against the filesystem before every use: a file
+// whose modification time or size changed is read again, and parsed again only
+// if its content hash changed too. So is a file modified within
+// modTimeGranularity of the check, as a change in that window may have kept
+// the same time and size.
+//
+// The cache is safe for concurrent use. Callers hold the lock of a file (see
+// lock) while they use its entry. The content of an entry is also changed
+// under the cache mutex, so overlay can read it without the file lock.
+type fileCache struct {
+	fset  *token.FileSet // Shared by every parsed file
+	mu    sync.Mutex     // Protects files and locks
+	files map[string]*cachedFile
+	locks map[string]*sync.Mutex
+}
+
+// cachedFile is the cached state of a single file
+type cachedFile struct {
+	exists  bool              // The file exists on disk
+	disk    []byte            // Content on disk when last read or written
+	sum     [sha256.Size]byte // Hash of the content on disk
+	modTime time.Time         // Modification time of the content on disk
+	size    int64             // Size of the content on disk
+	statted time.Time         // When modTime and size were read
+	content []byte            // Current content, differs from disk while changes are buffered
+	style   textStyle         // Line endings, byte order mark and final newline of the file on disk
+	file    *ast.File         // Parsed content, nil until needed
+	events  []Event           // Operations with changes not written yet
+}
+
+// newFileCache creates an empty cache
+func newFileCache() *fileCache {
+	return &fileCache{
+		fset:  token.NewFileSet(),
+		files: make(map[string]*cachedFile),
+		locks: make(map[string]*sync.Mutex),
+	}
+}
+
+// lock locks a file for a read-modify-write cycle and returns the function
+// that unlocks it
+func (c *fileCache) lock(filename string) func() {
+	c.mu.Lock()
+	mu, ok := c.locks[filename]
+	if !ok {
+		mu = &sync.Mutex{}
+		c.locks[filename] = mu
+	}
+	c.mu.Unlock()
+
+	mu.Lock()
+	return mu.Unlock
+}
+
+// get returns the entry of a file, nil if the file was never loaded
+func (c *fileCache) get(filename string) *cachedFile {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.files[filename]
+}
+
+// filenames returns the names of the cached files in order
+func (c *fileCache) filenames() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	filenames := make([]string, 0, len(c.files))
+	for filename := range c.files {
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+	return filenames
+}
+
+// stat records the modification time and size of the content on disk
+func (f *cachedFile) stat(info os.FileInfo) {
+	f.modTime, f.size, f.statted = info.ModTime(), info.Size(), time.Now()
+}
+
+// unchanged reports whether a file on disk surely has the content of the
+// entry, without reading it: same modification time and size, recorded long
+// enough after the modification that a later change would have moved the time
+func (f *cachedFile) unchanged(info os.FileInfo) bool {
+	return f.exists && info.ModTime().Equal(f.modTime) && info.Size() == f.size &&
+		f.statted.Sub(f.modTime) >= modTimeGranularity
+}
+
+// dirty reports whether the entry has changes not written to disk
+func (f *cachedFile) dirty() bool {
+	return !bytes.Equal(f.content, f.disk)
+}
+
+// load returns the entry of a file, reading it again if it changed on disk.
+// The caller must hold the lock of the file.
+// A file that changed on disk while it has buffered changes is an error, as
+// the changes were made to content that no longer exists.
+func (c *fileCache) load(filesystem fs.FS, filename string) (*cachedFile, error) {
+	entry := c.get(filename)
+
+	info, err := filesystem.Stat(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to stat existing file: %w", err)
+	}
+	if err != nil {
+		if entry != nil && !entry.exists {
+			return entry, nil
+		}
+		if entry != nil && entry.dirty() {
+			return nil, fmt.Errorf("%s was removed with unflushed changes", filename)
+		}
+		entry = &cachedFile{}
+		c.set(filename, entry)
+		return entry, nil
+	}
+
+	if entry != nil && entry.unchanged(info) {
+		return entry, nil
+	}
+
+	content, err := filesystem.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read existing file: %w", err)
+	}
+	sum := sha256.Sum256(content)
+
+	// Touched but not changed: keep the entry and its syntax tree
+	if entry != nil && entry.exists && sum == entry.sum {
+		entry.stat(info)
+		return entry, nil
+	}
+
+	if entry != nil && entry.dirty() {
+		return nil, fmt.Errorf("%s changed on disk with unflushed changes", filename)
+	}
+
+	entry = &cachedFile{
+		exists:  true,
+		disk:    content,
+		sum:     sum,
+		content: content,
+		style:   detectStyle(content),
+	}
+	entry.stat(info)
+	c.set(filename, entry)
+	return entry, nil
+}
+
+// set replaces the entry of a file
+func (c *fileCache) set(filename string, entry *cachedFile) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old := c.files[filename]; old != nil {
+		c.forget(old)
+	}
+	c.files[filename] = entry
+}
+
+// forget drops the syntax tree of an entry, releasing it from the file set
+func (c *fileCache) forget(entry *cachedFile) {
+	if entry.file != nil {
+		c.fset.RemoveFile(c.fset.File(entry.file.Package))
+		entry.file = nil
+	}
+}
+
+// editor returns an editor for the current content of an entry, parsing it
+// only if there is no syntax tree for it yet
+func (c *fileCache) editor(filename string, entry *cachedFile, packageName string) (*editor, error) {
+	if len(entry.content) == 0 {
+		ed, err := newEditor(nil, packageName)
+		if err != nil {
+			return nil, err
+		}
+		ed.filename = filename
+		return ed, nil
+	}
+
+	// The file is edited in canonical form, see textStyle
+	source := entry.style.canonical(entry.content)
+	if entry.file == nil {
+		file, err := parser.ParseFile(c.fset, filename, source, parser.ParseComments)
+		if err != nil {
+			return nil, newParseError(filename, "", 0, err)
+		}
+		entry.file = file
+	}
+	ed := newFileEditor(c.fset, entry.file, source, false)
+	ed.filename = filename
+	return ed, nil
+}
+
+// update records the result of an edit. The syntax tree is kept only if the
+// content is the same and the editor didn't modify the tree.
+func (c *fileCache) update(entry *cachedFile, ed *editor, content []byte) {
+	if ed.mutated() || !bytes.Equal(content, entry.content) {
+		c.forget(entry)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.content = content
+}
+
+// written records that the current content of an entry is on disk
+func (c *fileCache) written(filesystem fs.FS, filename string, entry *cachedFile) {
+	entry.exists = true
+	entry.disk = entry.content
+	entry.sum = sha256.Sum256(entry.content)
+	entry.modTime, entry.size = time.Time{}, -1
+	if info, err := filesystem.Stat(filename); err == nil {
+		entry.stat(info)
+	}
+}
+
+// discard drops the buffered changes of an entry
+func (c *fileCache) discard(entry *cachedFile) {
+	if entry.dirty() {
+		c.forget(entry)
+
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		entry.content = entry.disk
+	}
+}
+
+// overlay returns the current content of the cached files in a directory
+// that exist or are about to be created
+func (c *fileCache) overlay(dir string) map[string][]byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	files := make(map[string][]byte)
+	for filename, entry := range c.files {
+		if path.Dir(filename) == dir && len(entry.content) > 0 {
+			files[filename] = entry.content
+		}
+	}
+	return files
+}
@@ -0,0 +1,87 @@
+package gogo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/guillermo/gogo/fs"
+)
+
+// Drift is a file that a generator would change, see Project.Check
+type Drift struct {
+	File   string // File that would change
+	Action string // "create", "modify" or "delete"
+	Owned  bool   // The file is marked as generated: it is stale or was edited by hand
+	Diff   string // Change that regenerating would make
+}
+
+// Check runs generate on a preview of the project and returns the files it
+// would change, in path order, without changing any. No drift means the
+// generated code is up to date; CI can fail otherwise.
+//
+// The preview is a project with the same options writing to an fs.OverlayFS
+// on top of the filesystem of p. It accepts every change, records no history
+// and emits no events.
+func (p *Project) Check(generate func(preview *Project) error) ([]Drift, error) {
+	overlay := fs.NewOverlayFS(p.fs)
+	opts := p.opts
+	opts.FS = overlay
+	opts.ConflictFunc, opts.ConflictFuncContext = ConflictAccept, nil
+	opts.Buffered = false
+	opts.History = 0
+	opts.Events, opts.Logger = nil, nil
+	preview, err := New(opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := generate(preview); err != nil {
+		return nil, err
+	}
+
+	changes, err := overlay.Changes()
+	if err != nil {
+		return nil, fmt.Errorf("failed to compare the preview: %w", err)
+	}
+	var drifts []Drift
+	for _, change := range changes {
+		if internalFile(change.Path) {
+			continue
+		}
+		old, err := p.fs.ReadFile(change.Path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read %s: %w", change.Path, err)
+		}
+		// Permissions alone are not drift
+		if change.Kind == fs.ChangeModify && bytes.Equal(old, change.Data) {
+			continue
+		}
+
+		drift := Drift{File: change.Path, Owned: isGenerated(old)}
+		switch change.Kind {
+		case fs.ChangeCreate:
+			drift.Action = "create"
+		case fs.ChangeModify:
+			drift.Action = "modify"
+		case fs.ChangeRemove:
+			drift.Action = "delete"
+		}
+		drift.Diff = generateDiff(normalizeText(old), normalizeText(change.Data), change.Path)
+		drifts = append(drifts, drift)
+	}
+	return drifts, nil
+}
+
+// internalFile reports whether a path is one of the files projects keep for
+// themselves, which are not generated code: the lock, the journal, the
+// history and temp files
+func internalFile(name string) bool {
+	if name == fs.LockFile || name == journalFile || strings.HasPrefix(name, historyDir+"/") {
+		return true
+	}
+	temp, _ := path.Match(".gogo-*.tmp", path.Base(name))
+	return temp
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/guillermo/gogo"
+)
+
+// runApply reconciles the project in -dir with JSON spec files
+func runApply(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("apply", stderr)
+	shared := addProjectFlags(flags)
+	schema := flags.Bool("schema", false, "print the JSON Schema of specs and exit")
+	root := flags.String("root", "", "directory the specs and their includes must be in, the directory of each spec if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *schema {
+		_, err := io.WriteString(stdout, gogo.SpecSchema)
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("no spec given")
+	}
+
+	project, err := shared.open()
+	if err != nil {
+		return err
+	}
+	return done(project, stdout, applySpecs(project, flags.Args(), *root))
+}
+
+// applySpecs applies spec files to a project. A spec named "-" is read from
+// stdin, with its includes relative to the root of the project. Other specs
+// and their includes must be in root, the directory of each spec if empty.
+func applySpecs(project *gogo.Project, names []string, root string) error {
+	var errs []error
+	for _, name := range names {
+		var err error
+		if name == "-" {
+			err = gogo.ApplySpec(project, os.Stdin)
+		} else {
+			err = applySpecFile(project, name, root)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// applySpecFile applies a spec file with its includes resolved within root
+func applySpecFile(project *gogo.Project, name, root string) error {
+	if root == "" {
+		root = filepath.Dir(name)
+	}
+	absRoot, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	absName, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(absRoot, absName)
+	if err != nil || !filepath.IsLocal(rel) {
+		return fmt.Errorf("spec is outside of -root %s", root)
+	}
+	return gogo.ApplySpecFS(project, os.DirFS(absRoot), filepath.ToSlash(rel))
+}
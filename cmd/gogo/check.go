@@ -0,0 +1,55 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/guillermo/gogo"
+)
+
+// runCheck applies JSON spec files to a preview of the project in -dir and
+// fails, printing the diff, if any file would change
+func runCheck(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("check", stderr)
+	dir := flags.String("dir", ".", "root of the project")
+	pkg := flags.String("package", "", "package name of new files")
+	root := flags.String("root", "", "directory the specs and their includes must be in, the directory of each spec if empty")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("no spec given")
+	}
+
+	fsys, err := gogo.OpenFS(*dir)
+	if err != nil {
+		return err
+	}
+	project, err := gogo.New(gogo.Options{FS: fsys, InitialPackageName: *pkg, ConflictFunc: gogo.ConflictReject})
+	if err != nil {
+		return err
+	}
+	drifts, err := project.Check(func(preview *gogo.Project) error {
+		return applySpecs(preview, flags.Args(), *root)
+	})
+	if err != nil {
+		return err
+	}
+	if len(drifts) == 0 {
+		fmt.Fprintln(stdout, "Generated code is up to date")
+		return nil
+	}
+
+	for _, drift := range drifts {
+		reason := "stale"
+		switch {
+		case drift.Action == "create":
+			reason = "missing"
+		case drift.Owned:
+			reason = "stale or edited by hand"
+		}
+		fmt.Fprintf(stdout, "%s %s (%s)\n%s\n", drift.Action, drift.File, reason, drift.Diff)
+	}
+	return fmt.Errorf("%d files out of date, run gogo apply", len(drifts))
+}
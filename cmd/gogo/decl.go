@@ -0,0 +1,378 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/guillermo/gogo"
+)
+
+// listFlag is a flag that can be repeated, like -field ID:int -field Name:string
+type listFlag []string
+
+func (l *listFlag) String() string { return strings.Join(*l, ", ") }
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// projectFlags are the flags shared by the commands that change a project
+type projectFlags struct {
+	dir     string
+	pkg     string
+	accept  bool
+	reject  bool
+	ask     bool
+	history int
+}
+
+// addProjectFlags defines the shared flags in a flag set
+func addProjectFlags(flags *flag.FlagSet) *projectFlags {
+	f := &projectFlags{}
+	flags.StringVar(&f.dir, "dir", ".", "root of the project")
+	flags.StringVar(&f.pkg, "package", "", "package name of new files")
+	flags.BoolVar(&f.accept, "accept", false, "apply every change without asking")
+	flags.BoolVar(&f.reject, "reject", false, "apply nothing, only report the changes")
+	flags.BoolVar(&f.ask, "ask", false, "show every change and ask before applying it (default)")
+	flags.IntVar(&f.history, "history", 0, "runs kept in .gogo/history for gogo undo, 0 records nothing")
+	return f
+}
+
+// declFlags are the flags shared by the declaration commands
+type declFlags struct {
+	*projectFlags
+	file     string
+	preserve bool
+	content  string
+}
+
+// addDeclFlags defines the flags of a declaration command in a flag set
+func addDeclFlags(flags *flag.FlagSet) *declFlags {
+	f := &declFlags{projectFlags: addProjectFlags(flags)}
+	flags.StringVar(&f.file, "file", "", "file to create or modify, relative to -dir (required)")
+	flags.BoolVar(&f.preserve, "preserve", false, "keep existing declarations that are not given")
+	flags.StringVar(&f.content, "content", "", "raw Go source instead of the other flags")
+	return f
+}
+
+// open validates the flags and creates the project
+func (f *declFlags) open(flags *flag.FlagSet) (*gogo.Project, error) {
+	if flags.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+	if f.file == "" {
+		return nil, errors.New("-file is required")
+	}
+	return f.projectFlags.open()
+}
+
+// conflictFunc returns the ConflictFunc chosen by -accept, -reject and -ask
+func (f *projectFlags) conflictFunc() (gogo.ConflictFunc, error) {
+	chosen := 0
+	for _, set := range []bool{f.accept, f.reject, f.ask} {
+		if set {
+			chosen++
+		}
+	}
+	switch {
+	case chosen > 1:
+		return nil, errors.New("-accept, -reject and -ask are mutually exclusive")
+	case f.accept:
+		return gogo.ConflictAccept, nil
+	case f.reject:
+		return gogo.ConflictReject, nil
+	}
+	return gogo.ConflictAsk, nil
+}
+
+// open creates the project in -dir
+func (f *projectFlags) open() (*gogo.Project, error) {
+	conflict, err := f.conflictFunc()
+	if err != nil {
+		return nil, err
+	}
+
+	fsys, err := gogo.OpenFS(f.dir)
+	if err != nil {
+		return nil, err
+	}
+	return gogo.New(gogo.Options{FS: fsys, InitialPackageName: f.pkg, ConflictFunc: conflict, History: f.history})
+}
+
+// done prints the report of the project after an operation. Rejected
+// changes are reported, not failures: -reject and answering no to -ask are
+// how the changes are previewed.
+func done(project *gogo.Project, stdout io.Writer, err error) error {
+	if err != nil && !errors.Is(err, gogo.ErrRejected) {
+		return err
+	}
+	return project.Report().WriteText(stdout)
+}
+
+// splitPair splits "name<sep>value", both parts required
+func splitPair(value, sep, format string) (string, string, error) {
+	name, rest, ok := strings.Cut(value, sep)
+	name, rest = strings.TrimSpace(name), strings.TrimSpace(rest)
+	if !ok || name == "" || rest == "" {
+		return "", "", fmt.Errorf("invalid value %q, expected %s", value, format)
+	}
+	return name, rest, nil
+}
+
+// parseFields parses -field values: Name:Type or Name:Type:tag
+func parseFields(values []string) ([]gogo.StructField, error) {
+	var fields []gogo.StructField
+	for _, value := range values {
+		name, rest, err := splitPair(value, ":", "Name:Type or Name:Type:tag")
+		if err != nil {
+			return nil, err
+		}
+		// Types have no colons, so the rest is the tag
+		typ, tag, _ := strings.Cut(rest, ":")
+		tag = strings.Trim(strings.TrimSpace(tag), "`")
+		fields = append(fields, gogo.StructField{Name: name, Type: strings.TrimSpace(typ), Annotation: tag})
+	}
+	return fields, nil
+}
+
+// parseParams parses -param values: name:Type
+func parseParams(values []string) ([]gogo.Parameter, error) {
+	var params []gogo.Parameter
+	for _, value := range values {
+		name, typ, err := splitPair(value, ":", "name:Type")
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, gogo.Parameter{Name: name, Type: typ})
+	}
+	return params, nil
+}
+
+// parseValues parses -var and -const values: name=value or name:Type=value
+func parseValues(values []string) (names, types, inits []string, err error) {
+	for _, value := range values {
+		left, init, err := splitPair(value, "=", "name=value or name:Type=value")
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		name, typ, _ := strings.Cut(left, ":")
+		names = append(names, strings.TrimSpace(name))
+		types = append(types, strings.TrimSpace(typ))
+		inits = append(inits, init)
+	}
+	return names, types, inits, nil
+}
+
+// runStruct creates or updates a struct
+func runStruct(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("struct", stderr)
+	shared := addDeclFlags(flags)
+	name := flags.String("name", "", "name of the struct (required)")
+	var fieldFlags, deleteFlags listFlag
+	flags.Var(&fieldFlags, "field", "field as Name:Type or Name:Type:tag, repeatable")
+	flags.Var(&deleteFlags, "delete", "name of a field to remove, repeatable")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("-name is required")
+	}
+	fields, err := parseFields(fieldFlags)
+	if err != nil {
+		return err
+	}
+	var deleted []gogo.StructField
+	for _, field := range deleteFlags {
+		deleted = append(deleted, gogo.StructField{Name: field})
+	}
+
+	project, err := shared.open(flags)
+	if err != nil {
+		return err
+	}
+	return done(project, stdout, project.Struct(gogo.StructOpts{
+		Filename:         shared.file,
+		Name:             *name,
+		Fields:           fields,
+		Content:          shared.content,
+		DeleteFields:     deleted,
+		PreserveExisting: shared.preserve,
+	}))
+}
+
+// runMethod creates or updates a method
+func runMethod(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("method", stderr)
+	shared := addDeclFlags(flags)
+	name := flags.String("name", "", "name of the method (required)")
+	receiver := flags.String("receiver", "", `receiver as "name Type", like "u *User" (required)`)
+	returns := flags.String("returns", "", `return type, like "error" or "(string, error)"`)
+	body := flags.String("body", "", "body of the method")
+	var paramFlags listFlag
+	flags.Var(&paramFlags, "param", "parameter as name:Type, repeatable")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("-name is required")
+	}
+	receiverName, receiverType, err := splitPair(*receiver, " ", `"name Type"`)
+	if err != nil {
+		return fmt.Errorf("-receiver: %w", err)
+	}
+	params, err := parseParams(paramFlags)
+	if err != nil {
+		return err
+	}
+
+	project, err := shared.open(flags)
+	if err != nil {
+		return err
+	}
+	return done(project, stdout, project.Method(gogo.MethodOpts{
+		Filename:         shared.file,
+		Name:             *name,
+		ReceiverName:     receiverName,
+		ReceiverType:     receiverType,
+		Parameters:       params,
+		ReturnType:       *returns,
+		Body:             *body,
+		Content:          shared.content,
+		PreserveExisting: shared.preserve,
+	}))
+}
+
+// runFunc creates or updates a function
+func runFunc(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("func", stderr)
+	shared := addDeclFlags(flags)
+	name := flags.String("name", "", "name of the function (required)")
+	returns := flags.String("returns", "", `return type, like "error" or "(string, error)"`)
+	body := flags.String("body", "", "body of the function")
+	var paramFlags listFlag
+	flags.Var(&paramFlags, "param", "parameter as name:Type, repeatable")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *name == "" {
+		return errors.New("-name is required")
+	}
+	params, err := parseParams(paramFlags)
+	if err != nil {
+		return err
+	}
+
+	project, err := shared.open(flags)
+	if err != nil {
+		return err
+	}
+	return done(project, stdout, project.Function(gogo.FunctionOpts{
+		Filename:         shared.file,
+		Name:             *name,
+		Parameters:       params,
+		ReturnType:       *returns,
+		Body:             *body,
+		Content:          shared.content,
+		PreserveExisting: shared.preserve,
+	}))
+}
+
+// runVar creates or updates variables
+func runVar(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("var", stderr)
+	shared := addDeclFlags(flags)
+	var varFlags, deleteFlags listFlag
+	flags.Var(&varFlags, "var", "variable as name=value or name:Type=value, repeatable")
+	flags.Var(&deleteFlags, "delete", "name of a variable to remove, repeatable")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	names, types, values, err := parseValues(varFlags)
+	if err != nil {
+		return err
+	}
+	var variables []gogo.Variable
+	for i := range names {
+		variables = append(variables, gogo.Variable{Name: names[i], Type: types[i], Value: values[i]})
+	}
+
+	project, err := shared.open(flags)
+	if err != nil {
+		return err
+	}
+	return done(project, stdout, project.Variable(gogo.VariableOpts{
+		Filename:         shared.file,
+		Variables:        variables,
+		Content:          shared.content,
+		DeleteVariables:  deleteFlags,
+		PreserveExisting: shared.preserve,
+	}))
+}
+
+// runConst creates or updates constants
+func runConst(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("const", stderr)
+	shared := addDeclFlags(flags)
+	var constFlags, deleteFlags listFlag
+	flags.Var(&constFlags, "const", "constant as name=value or name:Type=value, repeatable")
+	flags.Var(&deleteFlags, "delete", "name of a constant to remove, repeatable")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	names, types, values, err := parseValues(constFlags)
+	if err != nil {
+		return err
+	}
+	var constants []gogo.Constant
+	for i := range names {
+		constants = append(constants, gogo.Constant{Name: names[i], Type: types[i], Value: values[i]})
+	}
+
+	project, err := shared.open(flags)
+	if err != nil {
+		return err
+	}
+	return done(project, stdout, project.Constant(gogo.ConstantOpts{
+		Filename:         shared.file,
+		Constants:        constants,
+		Content:          shared.content,
+		DeleteConstants:  deleteFlags,
+		PreserveExisting: shared.preserve,
+	}))
+}
+
+// runType creates or updates type definitions
+func runType(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("type", stderr)
+	shared := addDeclFlags(flags)
+	var typeFlags, deleteFlags listFlag
+	flags.Var(&typeFlags, "type", `type as Name=Definition, like "ID=int64", repeatable`)
+	flags.Var(&deleteFlags, "delete", "name of a type to remove, repeatable")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	var types []gogo.TypeDef
+	for _, value := range typeFlags {
+		name, definition, err := splitPair(value, "=", "Name=Definition")
+		if err != nil {
+			return err
+		}
+		types = append(types, gogo.TypeDef{Name: name, Definition: definition})
+	}
+
+	project, err := shared.open(flags)
+	if err != nil {
+		return err
+	}
+	return done(project, stdout, project.Type(gogo.TypeOpts{
+		Filename:         shared.file,
+		Types:            types,
+		Content:          shared.content,
+		DeleteTypes:      deleteFlags,
+		PreserveExisting: shared.preserve,
+	}))
+}
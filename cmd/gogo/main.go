@@ -0,0 +1,95 @@
+// Command gogo modifies Go code from the command line.
+//
+// Usage:
+//
+//	gogo <command> [flags]
+//
+// Commands:
+//
+//	apply   reconcile the project with JSON spec files
+//	check   fail if JSON spec files would change the project
+//	const   create or update constants
+//	func    create or update a function
+//	method  create or update a method
+//	struct  create or update a struct
+//	type    create or update type definitions
+//	run     run the generators of //gogo: directives
+//	undo    revert the last run recorded in .gogo/history
+//	var     create or update variables
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// command is a subcommand of gogo
+type command struct {
+	summary string
+	run     func(args []string, stdout, stderr io.Writer) error
+}
+
+var commands = map[string]command{
+	"apply":  {"reconcile the project with JSON spec files", runApply},
+	"check":  {"fail if JSON spec files would change the project", runCheck},
+	"const":  {"create or update constants", runConst},
+	"func":   {"create or update a function", runFunc},
+	"method": {"create or update a method", runMethod},
+	"struct": {"create or update a struct", runStruct},
+	"type":   {"create or update type definitions", runType},
+	"run":    {"run the generators of //gogo: directives", runRun},
+	"undo":   {"revert the last run recorded in .gogo/history", runUndo},
+	"var":    {"create or update variables", runVar},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command line and returns the exit code
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "-help" || args[0] == "help" {
+		usage(stderr)
+		return 2
+	}
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "gogo: unknown command %q\n", args[0])
+		usage(stderr)
+		return 2
+	}
+	if err := cmd.run(args[1:], stdout, stderr); err != nil {
+		if err == flag.ErrHelp {
+			return 2
+		}
+		fmt.Fprintf(stderr, "gogo %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// usage prints the list of commands
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: gogo <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].summary)
+	}
+}
+
+// newFlagSet returns the flag set of a command, printing errors to stderr
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	flags := flag.NewFlagSet("gogo "+name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	return flags
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunDeclarations(t *testing.T) {
+	dir := t.TempDir()
+	commands := [][]string{
+		{"struct", "-dir", dir, "-package", "models", "-file", "user.go", "-accept", "-name", "User", "-field", "ID:int64", "-field", `Name:string:json:"name"`},
+		{"method", "-dir", dir, "-file", "user.go", "-accept", "-name", "Key", "-receiver", "u *User", "-returns", "int64", "-body", "return u.ID"},
+		{"func", "-dir", dir, "-file", "user.go", "-accept", "-name", "NewUser", "-param", "id:int64", "-returns", "*User", "-body", "return &User{ID: id}"},
+		{"var", "-dir", dir, "-file", "user.go", "-accept", "-var", "Default=NewUser(1)"},
+		{"const", "-dir", dir, "-file", "user.go", "-accept", "-const", "Max:int64=10"},
+		{"type", "-dir", dir, "-file", "user.go", "-accept", "-type", "ID=int64"},
+	}
+	for _, args := range commands {
+		var stdout, stderr bytes.Buffer
+		if code := run(args, &stdout, &stderr); code != 0 {
+			t.Fatalf("gogo %s exited with %d: %s", args[0], code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), "user.go") {
+			t.Errorf("Expected a report for gogo %s, got %q", args[0], stdout.String())
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(dir, "user.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"package models",
+		"Name string `json:\"name\"`",
+		"func (u *User) Key() int64 {",
+		"func NewUser(id int64) *User {",
+		"var Default = NewUser(1)",
+		"const Max int64 = 10",
+		"type ID int64",
+	} {
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Expected %q in:\n%s", want, content)
+		}
+	}
+
+	// Rejected changes leave the file alone
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"struct", "-dir", dir, "-file", "user.go", "-reject", "-name", "User", "-field", "ID:string"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected success, got %d: %s", code, stderr.String())
+	}
+	if again, _ := os.ReadFile(filepath.Join(dir, "user.go")); !bytes.Equal(again, content) {
+		t.Errorf("Expected no change, got:\n%s", again)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"struct", "-dir", dir, "-name", "User"}, "-file is required"},
+		{[]string{"struct", "-dir", dir, "-file", "user.go"}, "-name is required"},
+		{[]string{"struct", "-dir", dir, "-file", "user.go", "-name", "User", "-accept", "-reject"}, "mutually exclusive"},
+		{[]string{"struct", "-dir", dir, "-file", "user.go", "-name", "User", "-field", "ID"}, "expected Name:Type"},
+		{[]string{"method", "-dir", dir, "-file", "user.go", "-name", "Key", "-receiver", "User"}, "-receiver"},
+		{[]string{"const", "-dir", dir, "-file", "user.go", "-const", "Max"}, "expected name=value"},
+		{[]string{"nope"}, "unknown command"},
+	}
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		if code := run(tt.args, &stdout, &stderr); code == 0 || !strings.Contains(stderr.String(), tt.want) {
+			t.Errorf("gogo %v: expected an error with %q, got %d: %s", tt.args, tt.want, code, stderr.String())
+		}
+	}
+}
+
+func TestRunApply(t *testing.T) {
+	dir := t.TempDir()
+	specs := t.TempDir()
+	os.WriteFile(filepath.Join(specs, "types.json"), []byte(`{"version": 1, "files": [{"path": "ids.go", "types": [{"name": "ID", "definition": "int64"}]}]}`), 0644)
+	os.WriteFile(filepath.Join(specs, "user.json"), []byte(`{"version": 1, "include": ["types.json"], "files": [{"path": "user.go", "structs": [{"name": "User", "fields": [{"name": "ID", "type": "ID"}]}]}]}`), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"apply", "-dir", dir, "-package", "models", "-accept", filepath.Join(specs, "user.json")}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected success, got %d: %s", code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "2 files: 2 created") {
+		t.Errorf("Unexpected report:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+	os.WriteFile(filepath.Join(specs, "bad.json"), []byte(`{"version": 1, "files": [{"path": "user.txt"}]}`), 0644)
+	if code := run([]string{"apply", "-dir", dir, filepath.Join(specs, "bad.json")}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "bad.json#/files/0/path") {
+		t.Errorf("Expected a validation error, got %d: %s", code, stderr.String())
+	}
+
+	// Includes outside the directory of the spec need a -root holding both
+	stderr.Reset()
+	os.MkdirAll(filepath.Join(specs, "app"), 0755)
+	os.WriteFile(filepath.Join(specs, "app", "app.json"), []byte(`{"version": 1, "include": ["../types.json"], "files": [{"path": "app.go", "types": [{"name": "AppID", "definition": "ID"}]}]}`), 0644)
+	if code := run([]string{"apply", "-dir", dir, "-accept", filepath.Join(specs, "app", "app.json")}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "../types.json is outside of the root") {
+		t.Errorf("Expected the include to be refused, got %d: %s", code, stderr.String())
+	}
+	if code := run([]string{"apply", "-dir", dir, "-accept", "-root", specs, filepath.Join(specs, "app", "app.json")}, &stdout, &stderr); code != 0 {
+		t.Errorf("Expected success, got %d: %s", code, stderr.String())
+	}
+	stderr.Reset()
+	if code := run([]string{"apply", "-dir", dir, "-accept", "-root", filepath.Join(specs, "app"), filepath.Join(specs, "user.json")}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "outside of -root") {
+		t.Errorf("Expected the spec to be refused, got %d: %s", code, stderr.String())
+	}
+
+	stdout.Reset()
+	if code := run([]string{"apply", "-schema"}, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), `"$defs"`) {
+		t.Errorf("Expected the schema, got %d: %s", code, stdout.String())
+	}
+}
+
+func TestRunUndo(t *testing.T) {
+	dir := t.TempDir()
+	spec := filepath.Join(t.TempDir(), "spec.json")
+	original := "package models\n\ntype ID int\n"
+	os.WriteFile(filepath.Join(dir, "ids.go"), []byte(original), 0644)
+	os.WriteFile(spec, []byte(`{"version": 1, "files": [{"path": "ids.go", "types": [{"name": "ID", "definition": "int64"}]}]}`), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"apply", "-dir", dir, "-accept", "-history", "5", spec}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected success, got %d: %s", code, stderr.String())
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "ids.go")); !strings.Contains(string(content), "type ID int64") {
+		t.Fatalf("Expected ids.go to be modified, got:\n%s", content)
+	}
+
+	if code := run([]string{"undo", "-dir", dir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected success, got %d: %s", code, stderr.String())
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "ids.go")); string(content) != original {
+		t.Errorf("Expected ids.go to be restored, got:\n%s", content)
+	}
+
+	// Without -history nothing is recorded
+	if code := run([]string{"apply", "-dir", dir, "-accept", spec}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected success, got %d: %s", code, stderr.String())
+	}
+	stderr.Reset()
+	if code := run([]string{"undo", "-dir", dir}, &stdout, &stderr); code != 1 || !strings.Contains(stderr.String(), "no run to undo") {
+		t.Errorf("Expected nothing to undo, got %d: %s", code, stderr.String())
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	dir := t.TempDir()
+	spec := filepath.Join(t.TempDir(), "spec.json")
+	os.WriteFile(spec, []byte(`{"version": 1, "files": [{"path": "ids.go", "types": [{"name": "ID", "definition": "int64"}]}]}`), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"check", "-dir", dir, "-package", "models", spec}, &stdout, &stderr); code != 1 || !strings.Contains(stdout.String(), "create ids.go (missing)") || !strings.Contains(stderr.String(), "1 files out of date") {
+		t.Fatalf("Expected drift, got %d:\n%s%s", code, stdout.String(), stderr.String())
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ids.go")); err == nil {
+		t.Fatal("Expected check to write nothing")
+	}
+
+	if code := run([]string{"apply", "-dir", dir, "-package", "models", "-accept", spec}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected success, got %d: %s", code, stderr.String())
+	}
+	stdout.Reset()
+	if code := run([]string{"check", "-dir", dir, spec}, &stdout, &stderr); code != 0 || !strings.Contains(stdout.String(), "up to date") {
+		t.Errorf("Expected no drift, got %d:\n%s", code, stdout.String())
+	}
+}
+
+func TestRunDirectives(t *testing.T) {
+	dir := t.TempDir()
+	templates := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0644)
+	os.MkdirAll(filepath.Join(dir, "models"), 0755)
+	os.WriteFile(filepath.Join(dir, "models", "user.go"), []byte("package models\n\n//gogo:enum Role admin,member\n//gogo:generate table name=users\n"), 0644)
+	os.WriteFile(filepath.Join(templates, "table.json.tmpl"), []byte(`{"version": 1, "files": [{"path": {{printf "%s/tables.go" .Dir | json}}, "package": {{json .Package}}, "consts": [{"name": "Table", "value": {{printf "%q" .Options.name | json}}}]}]}`), 0644)
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"run", "-dir", dir, "-accept", "-templates", templates, "./..."}, &stdout, &stderr); code != 0 {
+		t.Fatalf("Expected success, got %d: %s", code, stderr.String())
+	}
+	roles, _ := os.ReadFile(filepath.Join(dir, "models", "role_enum.go"))
+	tables, _ := os.ReadFile(filepath.Join(dir, "models", "tables.go"))
+	if !strings.Contains(string(roles), `RoleAdmin Role = "admin"`) || !strings.Contains(string(tables), `const Table = "users"`) {
+		t.Errorf("Unexpected output:\n%s\n%s", roles, tables)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/guillermo/gogo"
+)
+
+// runRun runs the generators of the //gogo: directives of the project in
+// -dir. Arguments are package patterns like ./... (the default) or ./models.
+func runRun(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("run", stderr)
+	shared := addProjectFlags(flags)
+	templates := flags.String("templates", "", "directory of NAME.json.tmpl spec templates, registered as generator NAME")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	registry := gogo.DefaultRegistry
+	if *templates != "" {
+		if err := registerTemplates(registry, *templates); err != nil {
+			return err
+		}
+	}
+
+	project, err := shared.open()
+	if err != nil {
+		return err
+	}
+	return done(project, stdout, project.RunDirectives(registry, flags.Args()...))
+}
+
+// registerTemplates registers every NAME.json.tmpl file of dir as the
+// generator NAME, see Registry.RegisterTemplate
+func registerTemplates(registry *gogo.Registry, dir string) error {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json.tmpl"))
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("no templates in %s", dir)
+	}
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.Base(file), ".json.tmpl")
+		tmpl, err := template.New(filepath.Base(file)).Funcs(gogo.TemplateFuncs).Parse(string(content))
+		if err != nil {
+			return fmt.Errorf("failed to parse template: %w", err)
+		}
+		registry.RegisterTemplate(name, tmpl)
+	}
+	return nil
+}
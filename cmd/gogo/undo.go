@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/guillermo/gogo"
+)
+
+// runUndo reverts the last run of the project in -dir
+func runUndo(args []string, stdout, stderr io.Writer) error {
+	flags := newFlagSet("undo", stderr)
+	dir := flags.String("dir", ".", "root of the project")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("unexpected arguments %v", flags.Args())
+	}
+
+	fsys, err := gogo.OpenFS(*dir)
+	if err != nil {
+		return err
+	}
+	project, err := gogo.New(gogo.Options{FS: fsys, ConflictFunc: gogo.ConflictReject})
+	if err != nil {
+		return err
+	}
+	if err := project.Undo(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(stdout, "Reverted the last run")
+	return nil
+}
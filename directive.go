@@ -0,0 +1,335 @@
+package gogo
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"unicode"
+)
+
+// directivePrefix starts the comments that are directives. Like //go:
+// directives, there is no space after the slashes.
+const directivePrefix = "//gogo:"
+
+// Directive is a //gogo: comment asking for code to be generated:
+//
+//	//gogo:enum Status active,inactive
+//	//gogo:generate crud table=users
+//
+// The first word after the prefix names the generator, except for
+// //gogo:generate, where the generator is the first argument. The rest of
+// the comment are arguments: key=value pairs are options, anything else is a
+// positional argument. Values with spaces are double quoted.
+type Directive struct {
+	Name    string            // Generator, like "enum" or "crud"
+	Args    []string          // Positional arguments, like ["Status", "active,inactive"]
+	Options map[string]string // key=value arguments, like {"table": "users"}
+	File    string            // File of the comment
+	Line    int               // Line of the comment
+	Package string            // Package of the file
+}
+
+// Dir returns the directory of the file of the directive, where generators
+// usually write
+func (d Directive) Dir() string {
+	return path.Dir(d.File)
+}
+
+// String returns the location and the comment of the directive
+func (d Directive) String() string {
+	return fmt.Sprintf("%s:%d: //gogo:%s", d.File, d.Line, d.Name)
+}
+
+// Generator generates the code a directive asks for in a project
+type Generator func(p *Project, d Directive) error
+
+// Registry maps the names of directives to their generators. It is safe for
+// concurrent use.
+type Registry struct {
+	mu         sync.RWMutex
+	generators map[string]Generator
+}
+
+// DefaultRegistry is the registry used by gogo run. It has the built-in
+// generators, see NewRegistry.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns a registry with the built-in generators:
+//
+//	//gogo:enum Name value,value... [type=int] [file=name.go]
+//
+// enum writes a type and a constant per value to file, by default the name
+// of the type in snake case followed by _enum.go, in the package and the
+// directory of the directive. See SpecEnum.
+func NewRegistry() *Registry {
+	r := &Registry{generators: make(map[string]Generator)}
+	r.Register("enum", generateEnum)
+	return r
+}
+
+// Register adds or replaces the generator of a directive
+func (r *Registry) Register(name string, generator Generator) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.generators[name] = generator
+}
+
+// TemplateFuncs are the functions for the templates of RegisterTemplate.
+// json writes a value as JSON, quoted and escaped, so the values of options
+// can't break the spec.
+var TemplateFuncs = template.FuncMap{
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// RegisterTemplate registers a generator that executes tmpl with the
+// Directive as data and applies the result as a JSON spec, see ApplySpec.
+// Parse tmpl with TemplateFuncs to write values with json:
+//
+//	{"version": 1, "files": [{"path": {{printf "%s/%s.go" .Dir .Options.table | json}}, "package": {{json .Package}}, ...}]}
+func (r *Registry) RegisterTemplate(name string, tmpl *template.Template) {
+	r.Register(name, func(p *Project, d Directive) error {
+		var spec bytes.Buffer
+		if err := tmpl.Execute(&spec, d); err != nil {
+			return fmt.Errorf("failed to execute template %s: %w", tmpl.Name(), err)
+		}
+		return ApplySpec(p, &spec)
+	})
+}
+
+// Lookup returns the generator of a directive
+func (r *Registry) Lookup(name string) (Generator, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	generator, ok := r.generators[name]
+	return generator, ok
+}
+
+// Directives returns the directives of the Go files matching patterns, in
+// file and line order. Patterns are like those of the go command, relative
+// to the root of the project: "./..." matches every file, "./models" the
+// files of a directory and "./models/..." those of a tree. No patterns is
+// "./...". Files marked as generated are skipped, so generated code can't
+// ask for more.
+func (p *Project) Directives(patterns ...string) ([]Directive, error) {
+	files, err := p.Files()
+	if err != nil {
+		return nil, err
+	}
+
+	var directives []Directive
+	for _, filename := range files {
+		if !matchPatterns(filename, patterns) {
+			continue
+		}
+		content, err := p.fs.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		if !bytes.Contains(content, []byte(directivePrefix)) || isGenerated(content) {
+			continue
+		}
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, filename, content, parser.ParseComments)
+		if err != nil {
+			return nil, newParseError(filename, "", 0, err)
+		}
+		for _, group := range file.Comments {
+			for _, comment := range group.List {
+				text, ok := strings.CutPrefix(comment.Text, directivePrefix)
+				if !ok {
+					continue
+				}
+				directive, err := parseDirective(text)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", fset.Position(comment.Pos()), err)
+				}
+				directive.File = filename
+				directive.Line = fset.Position(comment.Pos()).Line
+				directive.Package = file.Name.Name
+				directives = append(directives, directive)
+			}
+		}
+	}
+	return directives, nil
+}
+
+// RunDirectives runs the generator of every directive of the files matching
+// patterns, see Directives. A directive without a generator in the registry
+// fails with ErrNotFound. An error in one directive does not stop the others.
+func (p *Project) RunDirectives(registry *Registry, patterns ...string) error {
+	directives, err := p.Directives(patterns...)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, directive := range directives {
+		generator, ok := registry.Lookup(directive.Name)
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: no generator: %w", directive, ErrNotFound))
+			continue
+		}
+		if err := generator(p, directive); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", directive, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// matchPatterns reports whether a file matches any of the patterns of
+// Directives
+func matchPatterns(filename string, patterns []string) bool {
+	if len(patterns) == 0 {
+		patterns = []string{"./..."}
+	}
+	dir := path.Dir(filename)
+	for _, pattern := range patterns {
+		pattern = path.Clean(pattern)
+		if tree, ok := strings.CutSuffix(pattern, "..."); ok {
+			tree = strings.TrimSuffix(tree, "/")
+			if tree == "" || tree == "." || dir == tree || strings.HasPrefix(dir, tree+"/") {
+				return true
+			}
+		} else if dir == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDirective parses the text of a directive after the prefix
+func parseDirective(text string) (Directive, error) {
+	words, err := splitArgs(text)
+	if err != nil {
+		return Directive{}, err
+	}
+	if len(words) > 0 && words[0] == "generate" {
+		words = words[1:]
+	}
+	if len(words) == 0 || words[0] == "" {
+		return Directive{}, errors.New("directive without a generator")
+	}
+
+	directive := Directive{Name: words[0], Options: make(map[string]string)}
+	for _, word := range words[1:] {
+		if key, value, ok := strings.Cut(word, "="); ok && isOptionKey(key) {
+			directive.Options[key] = value
+		} else {
+			directive.Args = append(directive.Args, word)
+		}
+	}
+	return directive, nil
+}
+
+// splitArgs splits the arguments of a directive on spaces, keeping double
+// quoted parts, like name="Full name", together
+func splitArgs(text string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '"':
+			// A quoted part ends at the first unescaped quote
+			end := i + 1
+			for end < len(text) && text[end] != '"' {
+				if text[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(text) {
+				return nil, fmt.Errorf("unterminated quote in %q", text)
+			}
+			unquoted, err := strconv.Unquote(text[i : end+1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid quote in %q: %w", text, err)
+			}
+			word.WriteString(unquoted)
+			inWord = true
+			i = end
+		case c == ' ' || c == '\t':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// isOptionKey reports whether the text before "=" is the key of an option
+func isOptionKey(key string) bool {
+	if key == "" {
+		return false
+	}
+	for _, r := range key {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+// generateEnum is the built-in generator of //gogo:enum
+func generateEnum(p *Project, d Directive) error {
+	if len(d.Args) != 2 || !token.IsIdentifier(d.Args[0]) {
+		return invalidOptions("expected //gogo:enum Name value,value...")
+	}
+	enum := SpecEnum{Name: d.Args[0], Type: d.Options["type"]}
+	for _, value := range strings.Split(d.Args[1], ",") {
+		value = strings.TrimSpace(value)
+		if value == "" || !token.IsIdentifier(enum.Name+exportedName(value)) {
+			return invalidOptions("invalid enum value %q", value)
+		}
+		enum.Values = append(enum.Values, value)
+	}
+
+	filename := d.Options["file"]
+	if filename == "" {
+		filename = snakeCase(enum.Name) + "_enum.go"
+	}
+	typeDef, consts := enum.decls()
+	return p.File(FileSpec{
+		Path:    path.Join(d.Dir(), filename),
+		Package: d.Package,
+		Types:   []TypeDef{typeDef},
+		Consts:  consts,
+	})
+}
+
+// snakeCase converts a CamelCase name to snake_case: OrderStatus makes
+// order_status
+func snakeCase(name string) string {
+	var snake strings.Builder
+	for i, r := range name {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				snake.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		snake.WriteRune(r)
+	}
+	return snake.String()
+}
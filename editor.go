@@ -0,0 +1,743 @@
+package gogo
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/printer"
+	"go/token"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// editor applies declaration changes to the source of a single Go file with
+// one parse and one format. Changes to existing declarations are recorded as
+// text replacements, so everything else in the file is kept as written, and
+// new declarations are appended at the end of the file.
+type editor struct {
+	filename string // File being edited, for errors
+	fset     *token.FileSet
+	file     *ast.File
+	content  []byte
+	created  bool // The file did not exist and starts from a package clause
+
+	edits    []textEdit             // Replacements, applied in offset order
+	structs  map[*ast.TypeSpec]bool // Existing structs modified in place
+	deleted  map[ast.Spec]bool      // Existing specs to remove
+	removed  map[ast.Decl]bool      // Existing declarations to remove
+	pending  []*pendingDecl         // Declarations to append
+	imports  []string               // Imports to ensure, as "path" or "name path"
+	preamble *FileOpts              // Preamble to reconcile, if any
+	pruned   bool                   // Declarations were pruned, so imports may be unused
+
+	// importName returns the name declared by an import path, "" if unknown,
+	// so imports made unused by pruning can be removed. Nil knows no names.
+	importName func(importPath string) string
+}
+
+// textEdit replaces the source between two offsets
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// pendingDecl is a declaration appended at the end of the file
+type pendingDecl struct {
+	keys []string     // Keys of the declared names, see declKey
+	tok  token.Token  // Token of the declaration, used to space declarations
+	text string       // Source code of the declaration
+	node *ast.GenDecl // Struct declaration still being modified, rendered when done
+}
+
+// declKey returns the key identifying a top-level declaration
+func declKey(tok token.Token, name string) string {
+	return tok.String() + " " + name
+}
+
+// methodKey returns the key identifying a method
+func methodKey(receiverType, name string) string {
+	typeName := strings.TrimPrefix(strings.TrimSpace(receiverType), "*")
+	if idx := strings.Index(typeName, "["); idx >= 0 {
+		typeName = typeName[:idx]
+	}
+	return "method " + typeName + "." + name
+}
+
+// newEditor parses content for editing. Empty content starts a new file in
+// the given package.
+func newEditor(content []byte, packageName string) (*editor, error) {
+	created := len(content) == 0
+	if created {
+		if packageName == "" {
+			packageName = "main"
+		}
+		content = []byte(fmt.Sprintf("package %s\n", packageName))
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Go file: %w", err)
+	}
+
+	return newFileEditor(fset, file, content, created), nil
+}
+
+// newFileEditor returns an editor for an already parsed file
+func newFileEditor(fset *token.FileSet, file *ast.File, content []byte, created bool) *editor {
+	return &editor{
+		fset:    fset,
+		file:    file,
+		content: content,
+		created: created,
+		structs: make(map[*ast.TypeSpec]bool),
+		deleted: make(map[ast.Spec]bool),
+		removed: make(map[ast.Decl]bool),
+	}
+}
+
+// mutated reports whether the syntax tree of the file was modified, in which
+// case it no longer matches the original content
+func (e *editor) mutated() bool {
+	return len(e.structs) > 0
+}
+
+// owned reports whether the existing file is marked as generated
+func (e *editor) owned() bool {
+	return !e.created && ast.IsGenerated(e.file)
+}
+
+// ensureStruct creates the struct or modifies its fields
+func (e *editor) ensureStruct(s structDef) error {
+	key := declKey(token.TYPE, s.Name)
+
+	if pending := e.findPending(key); pending != nil {
+		if pending.node == nil {
+			pending.node = createStructDecl(s)
+			pending.text = ""
+		} else {
+			modifyStruct(pending.node, s)
+		}
+		return nil
+	}
+
+	// Modify an existing struct in place
+	if genDecl := findOrCreateStruct(e.file, s.Name); genDecl != nil {
+		modifyStruct(genDecl, s)
+		for _, spec := range genDecl.Specs {
+			if typeSpec, ok := spec.(*ast.TypeSpec); ok && typeSpec.Name.Name == s.Name {
+				e.structs[typeSpec] = true
+			}
+		}
+		return nil
+	}
+
+	// Replace an existing type of another kind
+	if _, spec := e.findSpec(token.TYPE, s.Name); spec != nil {
+		text, err := e.render(createStructDecl(s).Specs[0])
+		if err != nil {
+			return err
+		}
+		e.replace(spec.Pos(), spec.End(), text)
+		return nil
+	}
+
+	e.pending = append(e.pending, &pendingDecl{keys: []string{key}, tok: token.TYPE, node: createStructDecl(s)})
+	return nil
+}
+
+// ensureMethod creates or replaces a method
+func (e *editor) ensureMethod(opts MethodOpts) error {
+	return e.ensureFunc(methodKey(opts.ReceiverType, opts.Name), methodSource(opts))
+}
+
+// ensureFunction creates or replaces a function
+func (e *editor) ensureFunction(opts FunctionOpts) error {
+	return e.ensureFunc(declKey(token.FUNC, opts.Name), functionSource(opts))
+}
+
+// ensureFunc creates or replaces the function or method with the given key
+func (e *editor) ensureFunc(key, source string) error {
+	if _, err := parseSnippet(e.filename, source); err != nil {
+		return err
+	}
+
+	for _, decl := range e.file.Decls {
+		if funcDecl, ok := decl.(*ast.FuncDecl); ok && funcKey(funcDecl) == key {
+			e.replace(funcDecl.Pos(), funcDecl.End(), source)
+			return nil
+		}
+	}
+
+	if pending := e.findPending(key); pending != nil {
+		pending.text = source
+		return nil
+	}
+
+	e.pending = append(e.pending, &pendingDecl{keys: []string{key}, tok: token.FUNC, text: source})
+	return nil
+}
+
+// ensureValue creates or replaces a variable or constant
+func (e *editor) ensureValue(tok token.Token, name, typ, value string) error {
+	return e.ensureSpec(tok, name, valueSpecSource(name, typ, value))
+}
+
+// ensureType creates or replaces a type definition
+func (e *editor) ensureType(typeDef TypeDef) error {
+	return e.ensureSpec(token.TYPE, typeDef.Name, typeSpecSource(typeDef))
+}
+
+// ensureSpec creates or replaces the spec declaring name
+func (e *editor) ensureSpec(tok token.Token, name, source string) error {
+	if _, err := parseSnippet(e.filename, tok.String()+" "+source); err != nil {
+		return err
+	}
+
+	key := declKey(tok, name)
+	if _, spec := e.findSpec(tok, name); spec != nil {
+		e.replace(spec.Pos(), spec.End(), source)
+		return nil
+	}
+
+	if pending := e.findPending(key); pending != nil {
+		pending.text = tok.String() + " " + source
+		pending.node = nil
+		return nil
+	}
+
+	e.pending = append(e.pending, &pendingDecl{keys: []string{key}, tok: tok, text: tok.String() + " " + source})
+	return nil
+}
+
+// deleteSpec removes the declaration of name with the given token
+func (e *editor) deleteSpec(tok token.Token, name string) {
+	if pending := e.findPending(declKey(tok, name)); pending != nil {
+		e.dropPending(pending)
+	}
+	if _, spec := e.findSpec(tok, name); spec != nil {
+		e.deleted[spec] = true
+	}
+}
+
+// ensureSource adds the declarations of the given token found in Go source,
+// replacing existing declarations of the same names
+func (e *editor) ensureSource(tok token.Token, source string) error {
+	snippet, err := parseSnippet(e.filename, source)
+	if err != nil {
+		return err
+	}
+
+	for _, decl := range snippet.file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != tok {
+			continue
+		}
+
+		names := make(map[string]bool)
+		var keys []string
+		for _, spec := range genDecl.Specs {
+			for _, name := range specNames(spec) {
+				names[name] = true
+				keys = append(keys, declKey(tok, name))
+			}
+		}
+
+		// Drop pending declarations of the same names
+		for _, key := range keys {
+			if pending := e.findPending(key); pending != nil {
+				e.dropPending(pending)
+			}
+		}
+
+		// Find the existing specs declaring the same names
+		var matches []ast.Spec
+		var matchDecls []*ast.GenDecl
+		for _, existing := range e.file.Decls {
+			existingDecl, ok := existing.(*ast.GenDecl)
+			if !ok || existingDecl.Tok != tok {
+				continue
+			}
+			for _, spec := range existingDecl.Specs {
+				for _, name := range specNames(spec) {
+					if names[name] {
+						matches = append(matches, spec)
+						if len(matchDecls) == 0 || matchDecls[len(matchDecls)-1] != existingDecl {
+							matchDecls = append(matchDecls, existingDecl)
+						}
+						break
+					}
+				}
+			}
+		}
+
+		// A declaration with exactly the same specs is replaced in place
+		if len(matchDecls) == 1 && len(matches) == len(genDecl.Specs) && len(matchDecls[0].Specs) == len(matches) {
+			e.replace(matchDecls[0].Pos(), matchDecls[0].End(), snippet.text(genDecl.Pos(), genDecl.End()))
+			continue
+		}
+
+		// Otherwise the old specs are removed and the declaration is appended
+		for _, spec := range matches {
+			e.deleted[spec] = true
+		}
+		e.pending = append(e.pending, &pendingDecl{keys: keys, tok: tok, text: snippet.text(declStart(genDecl), genDecl.End())})
+	}
+
+	return nil
+}
+
+// ensureImports adds imports given as "path" or "name path"
+func (e *editor) ensureImports(imports []string) {
+	for _, spec := range imports {
+		e.imports = appendUnique(e.imports, spec)
+	}
+}
+
+// setPreamble reconciles the preamble of the file with the file options
+func (e *editor) setPreamble(opts FileOpts) {
+	e.preamble = &opts
+}
+
+// prune removes the declarations whose keys are not in keep
+func (e *editor) prune(keep map[string]bool) {
+	for _, decl := range e.file.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if !keep[funcKey(d)] {
+				e.removed[d] = true
+				e.pruned = true
+			}
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue
+			}
+			for _, spec := range d.Specs {
+				kept := false
+				for _, name := range specNames(spec) {
+					if keep[declKey(d.Tok, name)] {
+						kept = true
+					}
+				}
+				if !kept {
+					e.deleted[spec] = true
+					e.pruned = true
+				}
+			}
+		}
+	}
+}
+
+// bytes returns the formatted source with all the changes applied
+func (e *editor) bytes() ([]byte, error) {
+	for _, decl := range e.file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok {
+			if e.removed[decl] {
+				e.replace(declStart(decl), decl.End(), "")
+			}
+			continue
+		}
+
+		// Collect the specs to delete from this declaration
+		var kept, deleted []ast.Spec
+		for _, spec := range genDecl.Specs {
+			if e.deleted[spec] {
+				deleted = append(deleted, spec)
+			} else {
+				kept = append(kept, spec)
+			}
+		}
+
+		if len(deleted) > 0 && len(kept) == 0 {
+			e.replace(declStart(genDecl), genDecl.End(), "")
+			continue
+		}
+		for _, spec := range deleted {
+			start, end := specRange(spec)
+			e.replace(start, end, "")
+		}
+
+		// Render the modified structs only, other specs of a grouped
+		// declaration may have their own changes
+		for _, spec := range kept {
+			if typeSpec, ok := spec.(*ast.TypeSpec); ok && e.structs[typeSpec] {
+				text, err := e.render(typeSpec)
+				if err != nil {
+					return nil, err
+				}
+				start, end := specRange(typeSpec) // Rendered with its comments
+				e.replace(start, end, text)
+			}
+		}
+	}
+
+	if len(e.imports) > 0 {
+		e.addImportEdit()
+	}
+	if e.preamble != nil && *e.preamble != (FileOpts{}) {
+		start := e.offset(e.file.Package)
+		e.edits = append(e.edits, textEdit{start: 0, end: start, text: preamble(e.fset, e.file, e.content, *e.preamble)})
+	}
+
+	// Apply the edits in order. Two edits of the same source, even at the
+	// same offset, would lose one of them.
+	edits := append([]textEdit(nil), e.edits...)
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	var buf bytes.Buffer
+	last := 0
+	for i, edit := range edits {
+		if edit.start < last || i > 0 && edit.start == edits[i-1].start {
+			return nil, fmt.Errorf("conflicting changes at offset %d", edit.start)
+		}
+		buf.Write(e.content[last:edit.start])
+		buf.WriteString(edit.text)
+		last = edit.end
+	}
+	buf.Write(e.content[last:])
+
+	// Append the new declarations
+	if len(e.pending) > 0 {
+		source := bytes.TrimRight(buf.Bytes(), " \t\n")
+		buf.Reset()
+		buf.Write(source)
+
+		prevTok, prevSingle := token.PACKAGE, false
+		if n := len(e.file.Decls); n > 0 && !e.created {
+			lastDecl := e.file.Decls[n-1]
+			prevTok, prevSingle = declToken(lastDecl), e.singleLine(lastDecl)
+		}
+
+		for _, pending := range e.pending {
+			text := pending.text
+			if pending.node != nil {
+				var err error
+				if text, err = e.render(pending.node); err != nil {
+					return nil, err
+				}
+			}
+			single := !strings.Contains(text, "\n")
+
+			// Keep one-line declarations of the same kind together
+			if pending.tok == prevTok && pending.tok != token.FUNC && single && prevSingle {
+				buf.WriteString("\n")
+			} else {
+				buf.WriteString("\n\n")
+			}
+			buf.WriteString(text)
+			prevTok, prevSingle = pending.tok, single
+		}
+		buf.WriteString("\n")
+	}
+
+	formatted, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format Go code: %w", err)
+	}
+
+	if e.pruned {
+		return removeUnusedImports(formatted, e.importName)
+	}
+	return formatted, nil
+}
+
+// addImportEdit records the edit that adds the missing imports
+func (e *editor) addImportEdit() {
+	var specs bytes.Buffer
+	for _, spec := range e.imports {
+		name, importPath := "", spec
+		if fields := strings.Fields(spec); len(fields) == 2 {
+			name, importPath = fields[0], fields[1]
+		}
+
+		found := false
+		for _, existing := range e.file.Imports {
+			if existingPath, err := strconv.Unquote(existing.Path.Value); err == nil && existingPath == importPath {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		specs.WriteString("\t")
+		if name != "" {
+			specs.WriteString(name + " ")
+		}
+		specs.WriteString(strconv.Quote(importPath) + "\n")
+	}
+	if specs.Len() == 0 {
+		return
+	}
+
+	// Find the first import declaration, if any
+	var importDecl *ast.GenDecl
+	for _, decl := range e.file.Decls {
+		if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.IMPORT {
+			importDecl = genDecl
+			break
+		}
+	}
+
+	switch {
+	case importDecl == nil:
+		// Add a new import block after the package clause
+		offset := e.offset(e.file.Name.End())
+		e.edits = append(e.edits, textEdit{start: offset, end: offset, text: "\n\nimport (\n" + specs.String() + ")\n"})
+	case importDecl.Lparen.IsValid():
+		// Add the specs at the end of the existing block
+		offset := e.offset(importDecl.Rparen)
+		e.edits = append(e.edits, textEdit{start: offset, end: offset, text: "\n" + specs.String()})
+	default:
+		// Turn the single import into a block
+		spec := e.text(importDecl.Specs[0].Pos(), importDecl.End())
+		e.replace(importDecl.Pos(), importDecl.End(), "import (\n\t"+spec+"\n"+specs.String()+")")
+	}
+}
+
+// findSpec returns the existing spec declaring name with the given token
+func (e *editor) findSpec(tok token.Token, name string) (*ast.GenDecl, ast.Spec) {
+	for _, decl := range e.file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != tok {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			for _, specName := range specNames(spec) {
+				if specName == name {
+					return genDecl, spec
+				}
+			}
+		}
+	}
+	return nil, nil
+}
+
+// findPending returns the pending declaration with the given key
+func (e *editor) findPending(key string) *pendingDecl {
+	for _, pending := range e.pending {
+		for _, pendingKey := range pending.keys {
+			if pendingKey == key {
+				return pending
+			}
+		}
+	}
+	return nil
+}
+
+// dropPending removes a pending declaration
+func (e *editor) dropPending(drop *pendingDecl) {
+	for i, pending := range e.pending {
+		if pending == drop {
+			e.pending = append(e.pending[:i], e.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// replace records the replacement of the source between two positions
+func (e *editor) replace(start, end token.Pos, text string) {
+	startOffset := e.offset(start)
+	e.edits = append(e.edits, textEdit{start: startOffset, end: e.offset(end), text: text})
+}
+
+// offset returns the offset of a position in the source
+func (e *editor) offset(pos token.Pos) int {
+	return e.fset.Position(pos).Offset
+}
+
+// text returns the source between two positions
+func (e *editor) text(start, end token.Pos) string {
+	return string(e.content[e.offset(start):e.offset(end)])
+}
+
+// singleLine reports whether a node spans a single line
+func (e *editor) singleLine(node ast.Node) bool {
+	return e.fset.Position(node.Pos()).Line == e.fset.Position(node.End()).Line
+}
+
+// render formats a node, keeping the comments inside it if it comes from the
+// parsed file
+func (e *editor) render(node ast.Node) (string, error) {
+	var printed interface{} = node
+	if node.Pos().IsValid() {
+		var comments []*ast.CommentGroup
+		for _, group := range e.file.Comments {
+			if group.Pos() >= node.Pos() && group.End() <= node.End() {
+				comments = append(comments, group)
+			}
+		}
+		printed = &printer.CommentedNode{Node: node, Comments: comments}
+	}
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, e.fset, printed); err != nil {
+		return "", fmt.Errorf("failed to format Go code: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// snippet is a piece of Go source parsed on its own
+type snippet struct {
+	fset    *token.FileSet
+	file    *ast.File
+	content []byte
+}
+
+// snippetHeader is the package clause added to snippets to parse them
+const snippetHeader = "package tmp\n\n"
+
+// parseSnippet parses declarations given as Go source for filename
+func parseSnippet(filename, source string) (*snippet, error) {
+	content := []byte(snippetHeader + source)
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
+	if err != nil {
+		return nil, newParseError(filename, source, strings.Count(snippetHeader, "\n"), err)
+	}
+	return &snippet{fset: fset, file: file, content: content}, nil
+}
+
+// text returns the snippet source between two positions
+func (s *snippet) text(start, end token.Pos) string {
+	return string(s.content[s.fset.Position(start).Offset:s.fset.Position(end).Offset])
+}
+
+// funcKey returns the key identifying a function or method declaration
+func funcKey(funcDecl *ast.FuncDecl) string {
+	if funcDecl.Recv != nil && len(funcDecl.Recv.List) > 0 {
+		return methodKey(receiverTypeName(funcDecl.Recv.List[0].Type), funcDecl.Name.Name)
+	}
+	return declKey(token.FUNC, funcDecl.Name.Name)
+}
+
+// specNames returns the names declared by a type or value spec
+func specNames(spec ast.Spec) []string {
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		return []string{s.Name.Name}
+	case *ast.ValueSpec:
+		names := make([]string, 0, len(s.Names))
+		for _, name := range s.Names {
+			names = append(names, name.Name)
+		}
+		return names
+	}
+	return nil
+}
+
+// declToken returns the token of a declaration
+func declToken(decl ast.Decl) token.Token {
+	if genDecl, ok := decl.(*ast.GenDecl); ok {
+		return genDecl.Tok
+	}
+	return token.FUNC
+}
+
+// declStart returns the start of a declaration including its doc comment
+func declStart(decl ast.Decl) token.Pos {
+	switch d := decl.(type) {
+	case *ast.GenDecl:
+		if d.Doc != nil {
+			return d.Doc.Pos()
+		}
+	case *ast.FuncDecl:
+		if d.Doc != nil {
+			return d.Doc.Pos()
+		}
+	}
+	return decl.Pos()
+}
+
+// specRange returns the range of a spec including its comments
+func specRange(spec ast.Spec) (token.Pos, token.Pos) {
+	start, end := spec.Pos(), spec.End()
+	var doc, comment *ast.CommentGroup
+	switch s := spec.(type) {
+	case *ast.TypeSpec:
+		doc, comment = s.Doc, s.Comment
+	case *ast.ValueSpec:
+		doc, comment = s.Doc, s.Comment
+	case *ast.ImportSpec:
+		doc, comment = s.Doc, s.Comment
+	}
+	if doc != nil {
+		start = doc.Pos()
+	}
+	if comment != nil {
+		end = comment.End()
+	}
+	return start, end
+}
+
+// removeUnusedImports removes the imports that Go source does not reference.
+// The name of an import is its alias or, without one, the name importName
+// returns for its path. Imports whose name is unknown are kept.
+func removeUnusedImports(content []byte, importName func(importPath string) string) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Go file: %w", err)
+	}
+
+	// Collect the names used in selectors. Local variables shadowing an
+	// import count too, which only keeps an import that could be removed.
+	used := make(map[string]bool)
+	ast.Inspect(file, func(node ast.Node) bool {
+		if sel, ok := node.(*ast.SelectorExpr); ok {
+			if ident, ok := sel.X.(*ast.Ident); ok {
+				used[ident.Name] = true
+			}
+		}
+		return true
+	})
+
+	var edits []textEdit
+	for _, decl := range file.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.IMPORT {
+			continue
+		}
+
+		var unused []ast.Spec
+		for _, spec := range genDecl.Specs {
+			importSpec := spec.(*ast.ImportSpec)
+			name := ""
+			if importSpec.Name != nil {
+				name = importSpec.Name.Name
+			} else if importPath, err := strconv.Unquote(importSpec.Path.Value); err == nil && importName != nil {
+				name = importName(importPath)
+			}
+			if name != "" && name != "_" && name != "." && !used[name] {
+				unused = append(unused, spec)
+			}
+		}
+
+		if len(unused) > 0 && len(unused) == len(genDecl.Specs) {
+			edits = append(edits, textEdit{start: fset.Position(declStart(genDecl)).Offset, end: fset.Position(genDecl.End()).Offset})
+			continue
+		}
+		for _, spec := range unused {
+			start, end := specRange(spec)
+			edits = append(edits, textEdit{start: fset.Position(start).Offset, end: fset.Position(end).Offset})
+		}
+	}
+	if len(edits) == 0 {
+		return content, nil
+	}
+
+	var buf bytes.Buffer
+	last := 0
+	for _, edit := range edits {
+		buf.Write(content[last:edit.start])
+		last = edit.end
+	}
+	buf.Write(content[last:])
+
+	return format.Source(buf.Bytes())
+}
@@ -0,0 +1,161 @@
+package gogo
+
+import (
+	"errors"
+	"fmt"
+	"go/scanner"
+	"sort"
+)
+
+// Errors returned by the project, to be checked with errors.Is
+var (
+	ErrRejected       = errors.New("changes rejected") // The ConflictFunc rejected the changes to a file
+	ErrNotFound       = errors.New("not found")        // A file, module or declaration doesn't exist
+	ErrInvalidOptions = errors.New("invalid options")  // The options of an operation are incomplete or contradictory
+	ErrEdited         = errors.New("edited since run") // A file changed after the run Undo would revert
+)
+
+// invalidOptions returns an error wrapping ErrInvalidOptions
+func invalidOptions(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrInvalidOptions, fmt.Sprintf(format, args...))
+}
+
+// ParseError is a syntax error in an existing file or in the Go source built
+// from the options of an operation (a body, a type, raw Content...)
+type ParseError struct {
+	File    string // File being modified
+	Snippet string // Source with the error when it comes from the options, empty for the existing file
+	Line    int    // Line of the error in the file or in the snippet, starting at 1
+	Column  int    // Column of the error, starting at 1
+	Msg     string // Error message
+	Err     error  // Error of the parser
+}
+
+func (e *ParseError) Error() string {
+	if e.Snippet != "" {
+		return fmt.Sprintf("%s: invalid Go source at %d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+	}
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// newParseError converts an error of go/parser to a ParseError. Lines are
+// shifted by skipLines, the lines added to the source before parsing it.
+func newParseError(file, snippet string, skipLines int, err error) *ParseError {
+	parseErr := &ParseError{File: file, Snippet: snippet, Msg: err.Error(), Err: err}
+
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		parseErr.Line = list[0].Pos.Line - skipLines
+		parseErr.Column = list[0].Pos.Column
+		parseErr.Msg = list[0].Msg
+	}
+	return parseErr
+}
+
+// FileStatus is what happened to a file, see Project.Results
+type FileStatus int
+
+const (
+	StatusUnchanged FileStatus = iota // The file already had every declaration as requested
+	StatusPending                     // The file has buffered changes not flushed yet
+	StatusCreated                     // The file was created
+	StatusModified                    // The file was modified
+	StatusRejected                    // The ConflictFunc rejected the changes
+	StatusFailed                      // An operation or the write failed, see Result.Err
+)
+
+func (s FileStatus) String() string {
+	switch s {
+	case StatusUnchanged:
+		return "unchanged"
+	case StatusPending:
+		return "pending"
+	case StatusCreated:
+		return "created"
+	case StatusModified:
+		return "modified"
+	case StatusRejected:
+		return "rejected"
+	case StatusFailed:
+		return "failed"
+	}
+	return fmt.Sprintf("FileStatus(%d)", int(s))
+}
+
+// Result is what happened to a file touched by the project
+type Result struct {
+	File   string
+	Status FileStatus
+	Err    error // Last error, for StatusFailed
+}
+
+// fileResult is the result of a file with the details for the report
+type fileResult struct {
+	Result
+	created bool     // The project created the file
+	decls   []string // Declarations changed, see FileReport
+	added   int      // Lines added by the writes
+	removed int      // Lines removed by the writes
+}
+
+// Results returns what happened to every file touched by the project since
+// it was created, in file name order. A file created and then modified is
+// reported as created.
+func (p *Project) Results() []Result {
+	p.resultsMu.Lock()
+	defer p.resultsMu.Unlock()
+
+	results := make([]Result, 0, len(p.results))
+	for _, result := range p.results {
+		results = append(results, result.Result)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].File < results[j].File })
+	return results
+}
+
+// record updates the result of a file with the outcome of an operation or a
+// write
+func (p *Project) record(filename string, status FileStatus, err error) {
+	if filename == "" {
+		return
+	}
+
+	p.resultsMu.Lock()
+	defer p.resultsMu.Unlock()
+
+	_, ok := p.results[filename]
+	result := p.result(filename)
+	if ok && status == StatusUnchanged && result.Status != StatusPending {
+		// Nothing new happened to the file, unless its buffered changes
+		// were undone
+		return
+	}
+
+	switch status {
+	case StatusCreated:
+		result.created = true
+	case StatusModified:
+		if result.created {
+			status = StatusCreated
+		}
+	}
+	result.Result = Result{File: filename, Status: status, Err: err}
+}
+
+// result returns the result of a file, creating it if needed. The caller
+// must hold resultsMu.
+func (p *Project) result(filename string) *fileResult {
+	if p.results == nil {
+		p.results = make(map[string]*fileResult)
+	}
+	result, ok := p.results[filename]
+	if !ok {
+		result = &fileResult{Result: Result{File: filename}}
+		p.results[filename] = result
+	}
+	return result
+}
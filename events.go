@@ -0,0 +1,147 @@
+package gogo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+)
+
+// Kinds of declaration reported in Event.Kind
+const (
+	KindStruct   = "struct"
+	KindMethod   = "method"
+	KindFunction = "function"
+	KindVariable = "variable"
+	KindConstant = "constant"
+	KindType     = "type"
+	KindFile     = "file" // Project.File, every declaration of a file spec
+)
+
+// Event describes a Project operation
+type Event struct {
+	Kind   string // Kind of declaration, one of the Kind constants
+	Name   string // Name of the declaration, names separated by ", " for several, empty for raw Content
+	File   string // File of the declaration
+	Action string // "create" or "modify", empty for unchanged files and errors before the file is read
+	Err    error  // Error, only for OnError
+}
+
+// EventListener receives the events of a project. Calls are serialized, so
+// a listener doesn't need to be safe for concurrent use.
+//
+// Every operation that doesn't fail is planned or unchanged. A planned change
+// is later applied or rejected, right away or, with Options.Buffered, when
+// the file is flushed; an operation that fails gets OnError instead.
+type EventListener interface {
+	OnPlan(Event)      // The operation changes the file
+	OnApply(Event)     // The change was written
+	OnReject(Event)    // The change was rejected by the ConflictFunc
+	OnUnchanged(Event) // The file already had the declaration as requested
+	OnError(Event)     // The operation failed
+}
+
+// EventFuncs is an EventListener calling the functions that are set
+type EventFuncs struct {
+	Plan      func(Event)
+	Apply     func(Event)
+	Reject    func(Event)
+	Unchanged func(Event)
+	Error     func(Event)
+}
+
+func (f EventFuncs) OnPlan(e Event)      { call(f.Plan, e) }
+func (f EventFuncs) OnApply(e Event)     { call(f.Apply, e) }
+func (f EventFuncs) OnReject(e Event)    { call(f.Reject, e) }
+func (f EventFuncs) OnUnchanged(e Event) { call(f.Unchanged, e) }
+func (f EventFuncs) OnError(e Event)     { call(f.Error, e) }
+
+// call calls an optional event function
+func call(f func(Event), e Event) {
+	if f != nil {
+		f(e)
+	}
+}
+
+// names joins the names of several declarations for Event.Name
+func names[T any](items []T, name func(T) string) string {
+	list := make([]string, 0, len(items))
+	for _, item := range items {
+		list = append(list, name(item))
+	}
+	return strings.Join(list, ", ")
+}
+
+// eventKind identifies the listener method of an event
+type eventKind int
+
+const (
+	eventPlan eventKind = iota
+	eventApply
+	eventReject
+	eventUnchanged
+	eventError
+)
+
+// emit sends an event to the listener and the logger of the project, if any
+func (p *Project) emit(kind eventKind, e Event) {
+	if p.opts.Events == nil && p.opts.Logger == nil {
+		return
+	}
+
+	p.eventMu.Lock()
+	defer p.eventMu.Unlock()
+
+	if listener := p.opts.Events; listener != nil {
+		switch kind {
+		case eventPlan:
+			listener.OnPlan(e)
+		case eventApply:
+			listener.OnApply(e)
+		case eventReject:
+			listener.OnReject(e)
+		case eventUnchanged:
+			listener.OnUnchanged(e)
+		case eventError:
+			listener.OnError(e)
+		}
+	}
+
+	if logger := p.opts.Logger; logger != nil {
+		level, msg := slog.LevelDebug, "unchanged"
+		switch kind {
+		case eventPlan:
+			msg = "planned change"
+		case eventApply:
+			level, msg = slog.LevelInfo, "applied change"
+		case eventReject:
+			level, msg = slog.LevelWarn, "rejected change"
+		case eventError:
+			level, msg = slog.LevelError, "failed change"
+		}
+
+		attrs := []slog.Attr{slog.String("kind", e.Kind), slog.String("file", e.File)}
+		if e.Name != "" {
+			attrs = append(attrs, slog.String("name", e.Name))
+		}
+		if e.Action != "" {
+			attrs = append(attrs, slog.String("action", e.Action))
+		}
+		if e.Err != nil {
+			attrs = append(attrs, slog.Any("error", e.Err))
+		}
+		logger.LogAttrs(context.Background(), level, msg, attrs...)
+	}
+}
+
+// failed reports the error of an operation, if any. It's deferred by the
+// operations with a pointer to their result. Rejections are not failures,
+// they were reported when the ConflictFunc was called.
+func (p *Project) failed(e Event, err *error) {
+	if *err != nil && !errors.Is(*err, ErrRejected) {
+		e.Err = *err
+		p.record(e.File, StatusFailed, *err)
+		p.touched(e)
+		p.emit(eventError, e)
+	}
+}
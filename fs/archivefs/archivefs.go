@@ -0,0 +1,303 @@
+// Package archivefs provides filesystems that stream the generated files to
+// a zip or tar archive, like the starter project of a scaffolding service.
+//
+// An archive can't replace or move what it already holds, so the filesystems
+// are append-only (see fs.AppendOnlyFS): projects write every file once, with
+// no temp file, and a file can't be written twice. Projects on them need
+// Options.Buffered, and Flush writes each file once however many operations
+// change it. Files go to the archive as soon as they are written;
+// only their names and sizes are kept, and reading them back fails with
+// ErrWriteOnly. go.mod and go.work are the exception, so projects can still
+// resolve import paths. Empty directories are not archived.
+package archivefs
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/guillermo/gogo/fs"
+)
+
+var (
+	// ErrClosed is returned by the operations on a filesystem whose archive
+	// was already completed by Close
+	ErrClosed = errors.New("archive already written")
+	// ErrWriteOnly is returned when reading, moving or removing a file
+	// already in the archive
+	ErrWriteOnly = errors.New("archive is write-only")
+)
+
+// entry is a file or directory of the filesystem
+type entry struct {
+	dir     bool
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	data    []byte // Kept for go.mod and go.work only
+}
+
+// FS is a filesystem that starts empty and streams the files written to it
+// to an archive
+type FS struct {
+	mu      sync.Mutex
+	write   func(name string, data []byte, mode os.FileMode, modTime time.Time) error
+	finish  func() error
+	entries map[string]*entry
+	closed  bool
+
+	// Prefix is prepended to the path of every file in the archive, like
+	// "myapp/" to extract the project in its own directory. Set it before
+	// the first write.
+	Prefix string
+	// ModTime is the modification time of the files in the archive, the time
+	// of the write if zero. Set it to get reproducible archives.
+	ModTime time.Time
+}
+
+// newFS returns an empty filesystem
+func newFS() *FS {
+	return &FS{entries: map[string]*entry{".": {dir: true, mode: os.ModeDir | 0755}}}
+}
+
+// NewZip returns a filesystem that streams a zip archive to w
+func NewZip(w io.Writer) *FS {
+	f := newFS()
+	archive := zip.NewWriter(w)
+	f.write = func(name string, data []byte, mode os.FileMode, modTime time.Time) error {
+		header := &zip.FileHeader{Name: f.Prefix + name, Method: zip.Deflate, Modified: modTime}
+		header.SetMode(mode)
+		writer, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(data)
+		return err
+	}
+	f.finish = archive.Close
+	return f
+}
+
+// NewTar returns a filesystem that streams a tar archive to w. Wrap w with
+// gzip.NewWriter for a .tar.gz.
+func NewTar(w io.Writer) *FS {
+	f := newFS()
+	archive := tar.NewWriter(w)
+	f.write = func(name string, data []byte, mode os.FileMode, modTime time.Time) error {
+		header := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     f.Prefix + name,
+			Mode:     int64(mode.Perm()),
+			Size:     int64(len(data)),
+			ModTime:  modTime,
+		}
+		if err := archive.WriteHeader(header); err != nil {
+			return err
+		}
+		_, err := archive.Write(data)
+		return err
+	}
+	f.finish = archive.Close
+	return f
+}
+
+// clean checks a path and returns it cleaned, "." for the root
+func clean(name string) (string, error) {
+	if err := fs.CheckPath(name); err != nil {
+		return "", err
+	}
+	return path.Clean(filepath.ToSlash(name)), nil
+}
+
+// AppendOnly reports that files can't be replaced, see fs.AppendOnlyFS
+func (f *FS) AppendOnly() bool {
+	return true
+}
+
+// WriteFile writes a file to the archive. Every file can only be written
+// once.
+func (f *FS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	name, err := clean(name)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrClosed
+	}
+	if existing, ok := f.entries[name]; ok {
+		if existing.dir {
+			return &os.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+		}
+		return &os.PathError{Op: "write", Path: name, Err: fmt.Errorf("already in the archive: %w", os.ErrExist)}
+	}
+
+	modTime := f.ModTime
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	if err := f.write(name, data, perm.Perm(), modTime); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", name, err)
+	}
+	f.mkdirAll(path.Dir(name))
+	e := &entry{size: int64(len(data)), mode: perm.Perm(), modTime: modTime}
+	if base := path.Base(name); base == "go.mod" || base == "go.work" {
+		e.data = append([]byte(nil), data...)
+	}
+	f.entries[name] = e
+	return nil
+}
+
+// ReadFile returns the content of go.mod and go.work files. Other files are
+// in the archive already and can't be read.
+func (f *FS) ReadFile(name string) ([]byte, error) {
+	name, err := clean(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok := f.entries[name]
+	switch {
+	case !ok:
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	case e.dir:
+		return nil, &os.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	case e.data == nil:
+		return nil, &os.PathError{Op: "read", Path: name, Err: ErrWriteOnly}
+	}
+	return append([]byte(nil), e.data...), nil
+}
+
+// Stat returns the info of a file or directory written to the filesystem
+func (f *FS) Stat(name string) (os.FileInfo, error) {
+	name, err := clean(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	e, ok := f.entries[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return &fileInfo{name: path.Base(name), entry: *e}, nil
+}
+
+// MkdirAll records a directory and its parents. Directories are implied by
+// the paths in the archive.
+func (f *FS) MkdirAll(name string, perm os.FileMode) error {
+	name, err := clean(name)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if e, ok := f.entries[dir]; ok && !e.dir {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: errors.New("not a directory")}
+		}
+	}
+	f.mkdirAll(name)
+	return nil
+}
+
+// mkdirAll records a directory and its parents. Callers hold the lock.
+func (f *FS) mkdirAll(name string) {
+	for dir := name; dir != "."; dir = path.Dir(dir) {
+		if _, ok := f.entries[dir]; !ok {
+			f.entries[dir] = &entry{dir: true, mode: os.ModeDir | 0755}
+		}
+	}
+}
+
+// Remove fails: nothing can be taken out of an archive
+func (f *FS) Remove(name string) error {
+	return &os.PathError{Op: "remove", Path: name, Err: ErrWriteOnly}
+}
+
+// Rename fails: nothing can be moved in an archive
+func (f *FS) Rename(oldpath, newpath string) error {
+	return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: ErrWriteOnly}
+}
+
+// TempFile fails: projects write append-only filesystems without temp files
+func (f *FS) TempFile(dir, pattern string) (fs.File, error) {
+	return nil, &os.PathError{Op: "createtemp", Path: path.Join(dir, pattern), Err: ErrWriteOnly}
+}
+
+// ReadDir lists the files and directories written to a directory, in name
+// order
+func (f *FS) ReadDir(name string) ([]os.DirEntry, error) {
+	name, err := clean(name)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if e, ok := f.entries[name]; !ok || !e.dir {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+	var entries []os.DirEntry
+	for child, e := range f.entries {
+		if child != "." && path.Dir(child) == name {
+			entries = append(entries, dirEntry{&fileInfo{name: path.Base(child), entry: *e}})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// Close completes the archive. It doesn't close the underlying writer.
+func (f *FS) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrClosed
+	}
+	f.closed = true
+	return f.finish()
+}
+
+// fileInfo describes an entry
+type fileInfo struct {
+	name string
+	entry
+}
+
+func (fi *fileInfo) Name() string       { return fi.name }
+func (fi *fileInfo) Size() int64        { return fi.size }
+func (fi *fileInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.dir }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+// dirEntry adapts a fileInfo to os.DirEntry
+type dirEntry struct {
+	info *fileInfo
+}
+
+func (d dirEntry) Name() string               { return d.info.name }
+func (d dirEntry) IsDir() bool                { return d.info.dir }
+func (d dirEntry) Type() os.FileMode          { return d.info.mode.Type() }
+func (d dirEntry) Info() (os.FileInfo, error) { return d.info, nil }
+
+// Compile-time interface assertions
+var (
+	_ fs.FS           = new(FS)
+	_ fs.AppendOnlyFS = new(FS)
+)
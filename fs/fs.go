@@ -27,6 +27,9 @@ type FS interface {
 
 	// TempFile creates a temporary file
 	TempFile(dir, pattern string) (File, error)
+
+	// ReadDir returns the entries of the directory at path, sorted by name
+	ReadDir(path string) ([]os.DirEntry, error)
 }
 
 // File represents an open file
@@ -35,3 +38,34 @@ type File interface {
 	Name() string
 	Stat() (os.FileInfo, error)
 }
+
+// SyncFS is implemented by filesystems that can flush files to stable
+// storage. Projects sync every file they write, and its directory, before
+// reporting the write as done.
+type SyncFS interface {
+	// Sync flushes the file or directory at path to stable storage
+	Sync(path string) error
+}
+
+// ChmodFS is implemented by filesystems with file permissions. Projects keep
+// the permissions of the files they replace.
+type ChmodFS interface {
+	// Chmod changes the permissions of the file at path
+	Chmod(path string, mode os.FileMode) error
+}
+
+// ChownFS is implemented by filesystems with file owners. Projects keep the
+// owner of the files they replace when they are allowed to.
+type ChownFS interface {
+	// Chown changes the owner and group of the file at path
+	Chown(path string, uid, gid int) error
+}
+
+// AppendOnlyFS is implemented by filesystems that can only add files, like
+// archives being streamed. Projects on them must be buffered: they write the
+// final content of every file once on Flush, with WriteFile, instead of
+// through a temp file and a rename, and keep no journal or history in them.
+type AppendOnlyFS interface {
+	// AppendOnly reports whether files can't be replaced once written
+	AppendOnly() bool
+}
@@ -0,0 +1,200 @@
+// Package gitfs provides a filesystem that protects the uncommitted work of a
+// git repository from being overwritten by generators.
+//
+// It uses the local git binary. A file is dirty when its content in the
+// working tree isn't in git yet: modified or deleted and not staged, or
+// untracked.
+// Staged changes are safe in the index and can be overwritten.
+package gitfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/internal/realfs"
+)
+
+// ErrDirty is returned when writing, renaming onto or removing a file with
+// changes not in git yet
+var ErrDirty = errors.New("file has uncommitted changes")
+
+// Options configures a git filesystem
+type Options struct {
+	Force bool   // Overwrite dirty files anyway
+	Add   bool   // Run git add on the files written
+	Git   string // Path of the git binary, "git" if empty
+}
+
+// FS is a filesystem on a directory of a git repository that refuses to
+// modify dirty files. The files written by the FS itself can be written
+// again. The files projects keep for themselves, the lock, the journal, the
+// history in .gogo/ and temp files, are never checked.
+type FS struct {
+	*realfs.FS
+	dir  string
+	opts Options
+
+	mu      sync.Mutex
+	written map[string]bool // Files written, dirty because of us
+}
+
+// Open returns the filesystem for a directory inside a git work tree
+func Open(dir string, opts Options) (*FS, error) {
+	if opts.Git == "" {
+		opts.Git = "git"
+	}
+
+	base, err := realfs.Open(dir)
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fsys := &FS{FS: base.(*realfs.FS), dir: root, opts: opts, written: make(map[string]bool)}
+	out, err := fsys.git("rev-parse", "--is-inside-work-tree")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not in a git work tree: %w", dir, err)
+	}
+	if strings.TrimSpace(string(out)) != "true" {
+		return nil, fmt.Errorf("%s is not in a git work tree", dir)
+	}
+	return fsys, nil
+}
+
+// git runs a git command in the directory of the filesystem
+func (f *FS) git(args ...string) ([]byte, error) {
+	// Paths are file names, never patterns
+	cmd := exec.Command(f.opts.Git, append([]string{"-C", f.dir, "--literal-pathspecs"}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// journalFile is the file where projects record the writes in progress
+const journalFile = ".gogo-pending"
+
+// checked reports whether a path is protected. The files projects keep for
+// themselves are not.
+func checked(name string) bool {
+	name = path.Clean(filepath.ToSlash(name))
+	if name == fs.LockFile || name == journalFile || name == ".gogo" || strings.HasPrefix(name, ".gogo/") {
+		return false
+	}
+	temp, _ := path.Match(".gogo-*.tmp", path.Base(name))
+	return !temp
+}
+
+// Dirty reports whether a file has changes not in git yet
+func (f *FS) Dirty(name string) (bool, error) {
+	out, err := f.git("status", "--porcelain=v1", "-z", "--untracked-files=all", "--", filepath.FromSlash(name))
+	if err != nil {
+		return false, err
+	}
+	entries := bytes.Split(out, []byte{0})
+	for i := 0; i < len(entries); i++ {
+		// Entries are "XY path", Y being the state of the working tree
+		entry := entries[i]
+		if len(entry) < 3 {
+			continue
+		}
+		if index := entry[0]; index == 'R' || index == 'C' {
+			// Followed by the original path
+			i++
+		}
+		if worktree := entry[1]; worktree != ' ' {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// check returns an error if a file can't be modified
+func (f *FS) check(name string) error {
+	if f.opts.Force || !checked(name) {
+		return nil
+	}
+
+	f.mu.Lock()
+	written := f.written[name]
+	f.mu.Unlock()
+	if written {
+		return nil
+	}
+
+	dirty, err := f.Dirty(name)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("%s: %w", name, ErrDirty)
+	}
+	return nil
+}
+
+// wrote records a file written and adds it to the index if requested
+func (f *FS) wrote(name string) error {
+	if !checked(name) {
+		return nil
+	}
+
+	f.mu.Lock()
+	f.written[name] = true
+	f.mu.Unlock()
+
+	if f.opts.Add {
+		if _, err := f.git("add", "--", filepath.FromSlash(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteFile writes a file unless it is dirty
+func (f *FS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := f.check(name); err != nil {
+		return err
+	}
+	if err := f.FS.WriteFile(name, data, perm); err != nil {
+		return err
+	}
+	return f.wrote(name)
+}
+
+// Rename moves a file unless the target is dirty
+func (f *FS) Rename(oldpath, newpath string) error {
+	if err := f.check(newpath); err != nil {
+		return err
+	}
+	if err := f.FS.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	return f.wrote(newpath)
+}
+
+// Remove removes a file unless it is dirty
+func (f *FS) Remove(name string) error {
+	if err := f.check(name); err != nil {
+		return err
+	}
+	return f.FS.Remove(name)
+}
+
+// Compile-time interface assertions
+var _ fs.FS = new(FS)
+var _ fs.Locker = new(FS)
+var _ fs.SyncFS = new(FS)
@@ -0,0 +1,66 @@
+package fs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+)
+
+// ErrReadOnly is returned by the write operations of read-only filesystems,
+// like the ones returned by FromIOFS
+var ErrReadOnly = errors.New("read-only filesystem")
+
+// FromIOFS returns a read-only FS that reads from an io/fs.FS, like embed.FS
+// or os.DirFS. Layer an OverlayFS on top to write to it.
+func FromIOFS(fsys iofs.FS) FS {
+	return &ioFS{fsys: fsys}
+}
+
+// ioFS adapts an io/fs.FS to FS
+type ioFS struct {
+	fsys iofs.FS
+}
+
+func (f *ioFS) ReadFile(name string) ([]byte, error) {
+	name, err := clean(name)
+	if err != nil {
+		return nil, err
+	}
+	return iofs.ReadFile(f.fsys, name)
+}
+
+func (f *ioFS) Stat(name string) (os.FileInfo, error) {
+	name, err := clean(name)
+	if err != nil {
+		return nil, err
+	}
+	return iofs.Stat(f.fsys, name)
+}
+
+func (f *ioFS) ReadDir(name string) ([]os.DirEntry, error) {
+	name, err := clean(name)
+	if err != nil {
+		return nil, err
+	}
+	return iofs.ReadDir(f.fsys, name)
+}
+
+func (f *ioFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	return &os.PathError{Op: "write", Path: name, Err: ErrReadOnly}
+}
+
+func (f *ioFS) MkdirAll(name string, perm os.FileMode) error {
+	return &os.PathError{Op: "mkdir", Path: name, Err: ErrReadOnly}
+}
+
+func (f *ioFS) Remove(name string) error {
+	return &os.PathError{Op: "remove", Path: name, Err: ErrReadOnly}
+}
+
+func (f *ioFS) Rename(oldpath, newpath string) error {
+	return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: ErrReadOnly}
+}
+
+func (f *ioFS) TempFile(dir, pattern string) (File, error) {
+	return nil, &os.PathError{Op: "createtemp", Path: dir, Err: ErrReadOnly}
+}
@@ -0,0 +1,26 @@
+package fs
+
+import (
+	"errors"
+	"time"
+)
+
+// LockFile is the name of the lock file, relative to the root of the
+// filesystem, that a project holds while it writes
+const LockFile = ".gogo.lock"
+
+// ErrLocked is returned by Locker.Lock when the lock is still held by someone
+// else once the timeout expires
+var ErrLocked = errors.New("locked by another process")
+
+// Locker is implemented by filesystems that can take an advisory lock shared
+// between processes. Projects take it around every write when their
+// filesystem implements it, so two generators never write the same tree at
+// the same time.
+type Locker interface {
+	// Lock takes the exclusive lock with the given name, waiting up to timeout
+	// for another holder to release it. A zero timeout tries once and a
+	// negative timeout waits forever. Locks left by holders that are gone are
+	// taken over. It returns the function that releases the lock.
+	Lock(name string, timeout time.Duration) (unlock func() error, err error)
+}
@@ -0,0 +1,680 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemFS is an in-memory filesystem. It implements FS, and io/fs.FS with
+// ReadDirFS, ReadFileFS and StatFS, so it works with both gogo and the
+// standard library. It keeps permissions and modification times, and is safe
+// for concurrent use.
+//
+// Paths follow the rules of the real filesystem: parent directories must
+// exist, and absolute paths or ".." traversal return a *PathEscapeError.
+// Names like "a/./b" are accepted everywhere but in Open, which follows the
+// rules of io/fs.
+type MemFS struct {
+	mu      sync.RWMutex
+	entries map[string]*memEntry // Files and directories by path, "." is the root
+	temps   int                  // Temp files created, to give each one a unique name
+	locks   map[string]bool      // Locks held, see Lock
+}
+
+// memEntry is a file or directory of a MemFS
+type memEntry struct {
+	data    []byte
+	mode    os.FileMode // Includes os.ModeDir for directories
+	modTime time.Time
+}
+
+// NewMemFS returns an empty in-memory filesystem
+func NewMemFS() *MemFS {
+	return &MemFS{
+		entries: map[string]*memEntry{".": {mode: os.ModeDir | 0755, modTime: time.Now()}},
+		locks:   make(map[string]bool),
+	}
+}
+
+// path returns the canonical form of a path. Like the real filesystem, an
+// element of the path followed by more elements, even "." or "..", must be
+// a directory if it exists.
+func (m *MemFS) path(name string) (string, error) {
+	if err := CheckPath(name); err != nil {
+		return "", err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	current := "."
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if entry, ok := m.entries[current]; ok && !entry.mode.IsDir() {
+			return "", &os.PathError{Op: "lstat", Path: name, Err: errors.New("not a directory")}
+		}
+		current = path.Join(current, part)
+	}
+	return current, nil
+}
+
+// info returns the os.FileInfo of an entry
+func (e *memEntry) info(name string) os.FileInfo {
+	return &memInfo{name: path.Base(name), size: int64(len(e.data)), mode: e.mode, modTime: e.modTime}
+}
+
+// parent returns the directory of name, which must exist. Callers hold the
+// lock.
+func (m *MemFS) parent(op, name string) (*memEntry, error) {
+	dir, ok := m.entries[path.Dir(name)]
+	if !ok {
+		return nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	if !dir.mode.IsDir() {
+		return nil, &os.PathError{Op: op, Path: name, Err: errors.New("not a directory")}
+	}
+	return dir, nil
+}
+
+// create stores a new entry, updating the modification time of its
+// directory. Callers hold the lock.
+func (m *MemFS) create(op, name string, entry *memEntry) error {
+	dir, err := m.parent(op, name)
+	if err != nil {
+		return err
+	}
+	dir.modTime = entry.modTime
+	m.entries[name] = entry
+	return nil
+}
+
+// children returns the paths directly inside a directory, sorted. Callers
+// hold the lock.
+func (m *MemFS) children(dir string) []string {
+	var names []string
+	for name := range m.entries {
+		if name != "." && path.Dir(name) == dir {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ReadFile returns the content of a file
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	name, err := m.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if entry.mode.IsDir() {
+		return nil, &os.PathError{Op: "read", Path: name, Err: errors.New("is a directory")}
+	}
+	return append([]byte(nil), entry.data...), nil
+}
+
+// WriteFile writes a file, creating it with perm if it doesn't exist
+func (m *MemFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	name, err := m.path(name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if entry, ok := m.entries[name]; ok {
+		if entry.mode.IsDir() {
+			return &os.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+		}
+		entry.data = append([]byte(nil), data...)
+		entry.modTime = now
+		return nil
+	}
+	return m.create("open", name, &memEntry{data: append([]byte(nil), data...), mode: perm.Perm(), modTime: now})
+}
+
+// Stat returns the info of a file or directory
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	name, err := m.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[name]
+	if !ok {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return entry.info(name), nil
+}
+
+// MkdirAll creates a directory and its parents
+func (m *MemFS) MkdirAll(name string, perm os.FileMode) error {
+	name, err := m.path(name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current := "."
+	for _, part := range strings.Split(name, "/") {
+		current = path.Join(current, part)
+		if entry, ok := m.entries[current]; ok {
+			if !entry.mode.IsDir() {
+				return &os.PathError{Op: "mkdir", Path: current, Err: errors.New("not a directory")}
+			}
+			continue
+		}
+		if err := m.create("mkdir", current, &memEntry{mode: os.ModeDir | perm.Perm(), modTime: time.Now()}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove removes a file or an empty directory
+func (m *MemFS) Remove(name string) error {
+	name, err := m.path(name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[name]
+	if !ok || name == "." {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	if entry.mode.IsDir() && len(m.children(name)) > 0 {
+		return &os.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+	}
+
+	delete(m.entries, name)
+	m.entries[path.Dir(name)].modTime = time.Now()
+	return nil
+}
+
+// Rename moves a file or directory, replacing the target if it is a file or
+// an empty directory
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	oldpath, err := m.path(oldpath)
+	if err != nil {
+		return err
+	}
+	newpath, err = m.path(newpath)
+	if err != nil {
+		return err
+	}
+	linkErr := func(err error) error {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[oldpath]
+	if !ok || oldpath == "." {
+		return linkErr(os.ErrNotExist)
+	}
+	if oldpath == newpath {
+		return nil
+	}
+	if entry.mode.IsDir() && strings.HasPrefix(newpath, oldpath+"/") {
+		return linkErr(errors.New("cannot move a directory into itself"))
+	}
+	if target, ok := m.entries[newpath]; ok {
+		switch {
+		case target.mode.IsDir() != entry.mode.IsDir():
+			return linkErr(errors.New("file and directory mismatch"))
+		case target.mode.IsDir() && len(m.children(newpath)) > 0:
+			return linkErr(errors.New("directory not empty"))
+		}
+	}
+	if _, err := m.parent("rename", newpath); err != nil {
+		return linkErr(os.ErrNotExist)
+	}
+
+	// Directories move with everything inside
+	moved := map[string]*memEntry{newpath: entry}
+	if entry.mode.IsDir() {
+		for name, child := range m.entries {
+			if strings.HasPrefix(name, oldpath+"/") {
+				moved[newpath+strings.TrimPrefix(name, oldpath)] = child
+				delete(m.entries, name)
+			}
+		}
+	}
+	delete(m.entries, oldpath)
+	for name, child := range moved {
+		m.entries[name] = child
+	}
+
+	now := time.Now()
+	m.entries[path.Dir(oldpath)].modTime = now
+	m.entries[path.Dir(newpath)].modTime = now
+	return nil
+}
+
+// TempFile creates a new empty file with a unique name in dir, like
+// os.CreateTemp
+func (m *MemFS) TempFile(dir, pattern string) (File, error) {
+	dir, err := m.path(dir)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(pattern, "/") {
+		return nil, &os.PathError{Op: "createtemp", Path: pattern, Err: errors.New("pattern contains path separator")}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.temps++
+	random := fmt.Sprintf("%d%d", time.Now().UnixNano(), m.temps)
+	// Like os.CreateTemp, the random part replaces the last "*"
+	if idx := strings.LastIndex(pattern, "*"); idx >= 0 {
+		pattern = pattern[:idx] + random + pattern[idx+1:]
+	} else {
+		pattern += random
+	}
+	name := path.Join(dir, pattern)
+
+	entry := &memEntry{mode: 0600, modTime: time.Now()}
+	if err := m.create("createtemp", name, entry); err != nil {
+		return nil, err
+	}
+	return &memFile{fs: m, name: name, entry: entry}, nil
+}
+
+// ReadDir returns the entries of a directory, sorted by name
+func (m *MemFS) ReadDir(name string) ([]os.DirEntry, error) {
+	name, err := m.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.readDir(name)
+}
+
+// readDir implements ReadDir. Callers hold the lock.
+func (m *MemFS) readDir(name string) ([]os.DirEntry, error) {
+	entry, ok := m.entries[name]
+	if !ok {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: os.ErrNotExist}
+	}
+	if !entry.mode.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: name, Err: errors.New("not a directory")}
+	}
+
+	var list []os.DirEntry
+	for _, child := range m.children(name) {
+		list = append(list, iofs.FileInfoToDirEntry(m.entries[child].info(child)))
+	}
+	return list, nil
+}
+
+// Chmod changes the permissions of a file or directory
+func (m *MemFS) Chmod(name string, mode os.FileMode) error {
+	name, err := m.path(name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[name]
+	if !ok {
+		return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+	}
+	entry.mode = entry.mode&os.ModeDir | mode.Perm()
+	return nil
+}
+
+// Chtimes changes the modification time of a file or directory. The access
+// time isn't kept.
+func (m *MemFS) Chtimes(name string, atime, mtime time.Time) error {
+	name, err := m.path(name)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[name]
+	if !ok {
+		return &os.PathError{Op: "chtimes", Path: name, Err: os.ErrNotExist}
+	}
+	entry.modTime = mtime
+	return nil
+}
+
+// Lock takes a lock shared by everyone using this filesystem, see Locker
+func (m *MemFS) Lock(name string, timeout time.Duration) (func() error, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		m.mu.Lock()
+		if !m.locks[name] {
+			m.locks[name] = true
+			m.mu.Unlock()
+
+			return func() error {
+				m.mu.Lock()
+				defer m.mu.Unlock()
+				delete(m.locks, name)
+				return nil
+			}, nil
+		}
+		m.mu.Unlock()
+
+		if timeout >= 0 && !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("%s: %w", name, ErrLocked)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Open opens a file or directory for reading, implementing io/fs.FS
+func (m *MemFS) Open(name string) (iofs.File, error) {
+	if !iofs.ValidPath(name) {
+		return nil, &os.PathError{Op: "open", Path: name, Err: iofs.ErrInvalid}
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	entry, ok := m.entries[name]
+	if !ok {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	file := &memFile{fs: m, name: name, entry: entry, readOnly: true, info: entry.info(name)}
+	if entry.mode.IsDir() {
+		file.dir, _ = m.readDir(name)
+	} else {
+		file.data = append([]byte(nil), entry.data...)
+	}
+	return file, nil
+}
+
+// Files returns the paths of all the files, in lexical order
+func (m *MemFS) Files() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var files []string
+	for name, entry := range m.entries {
+		if !entry.mode.IsDir() {
+			files = append(files, name)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+// memFile is a file of a MemFS opened by TempFile, which writes through to
+// the filesystem, or by Open, which reads a snapshot
+type memFile struct {
+	fs       *MemFS
+	name     string
+	entry    *memEntry
+	readOnly bool
+	offset   int
+
+	// Snapshot taken by Open
+	info os.FileInfo
+	data []byte
+	dir  []os.DirEntry
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	data := f.data
+	if !f.readOnly {
+		f.fs.mu.RLock()
+		defer f.fs.mu.RUnlock()
+		data = f.entry.data
+	}
+	if f.info != nil && f.info.IsDir() {
+		return 0, &os.PathError{Op: "read", Path: f.name, Err: errors.New("is a directory")}
+	}
+	if f.offset >= len(data) {
+		return 0, io.EOF
+	}
+	n := copy(p, data[f.offset:])
+	f.offset += n
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if f.readOnly {
+		return 0, &os.PathError{Op: "write", Path: f.name, Err: ErrReadOnly}
+	}
+
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+
+	// Writes go to the file even if it was renamed, like an open file
+	f.entry.data = append(f.entry.data[:f.offset:f.offset], p...)
+	f.entry.modTime = time.Now()
+	f.offset += len(p)
+	return len(p), nil
+}
+
+func (f *memFile) Close() error {
+	return nil
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	if f.info != nil {
+		return f.info, nil
+	}
+
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	return f.entry.info(f.name), nil
+}
+
+// ReadDir implements io/fs.ReadDirFile for directories opened with Open
+func (f *memFile) ReadDir(n int) ([]iofs.DirEntry, error) {
+	if f.info == nil || !f.info.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: f.name, Err: errors.New("not a directory")}
+	}
+	entries := f.dir[f.offset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(entries) {
+			entries = entries[:n]
+		}
+	}
+	f.offset += len(entries)
+	return entries, nil
+}
+
+// memInfo is the os.FileInfo of a MemFS entry
+type memInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (fi *memInfo) Name() string       { return fi.name }
+func (fi *memInfo) Size() int64        { return fi.size }
+func (fi *memInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *memInfo) ModTime() time.Time { return fi.modTime }
+func (fi *memInfo) IsDir() bool        { return fi.mode.IsDir() }
+func (fi *memInfo) Sys() interface{}   { return nil }
+
+// LoadDir returns a MemFS with a copy of the files and directories of a
+// directory on disk, with their permissions and modification times
+func LoadDir(dir string) (*MemFS, error) {
+	m := NewMemFS()
+	err := filepath.WalkDir(dir, func(osPath string, entry os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, osPath)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case entry.IsDir():
+			if name != "." {
+				m.entries[name] = &memEntry{mode: os.ModeDir | info.Mode().Perm(), modTime: info.ModTime()}
+			}
+		case info.Mode().IsRegular():
+			data, err := os.ReadFile(osPath)
+			if err != nil {
+				return err
+			}
+			m.entries[name] = &memEntry{data: data, mode: info.Mode().Perm(), modTime: info.ModTime()}
+		}
+		// Other files, like symlinks and devices, are left out
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load %s: %w", dir, err)
+	}
+	return m, nil
+}
+
+// WriteDir writes the files and directories to a directory on disk, created
+// if needed, with their permissions and modification times
+func (m *MemFS) WriteDir(dir string) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	names := make([]string, 0, len(m.entries))
+	for name := range m.entries {
+		names = append(names, name)
+	}
+	// Parents sort before their children
+	sort.Strings(names)
+
+	for _, name := range names {
+		entry := m.entries[name]
+		osPath := filepath.Join(dir, filepath.FromSlash(name))
+		if entry.mode.IsDir() {
+			if err := os.MkdirAll(osPath, entry.mode.Perm()); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.WriteFile(osPath, entry.data, entry.mode.Perm()); err != nil {
+			return err
+		}
+		if err := os.Chmod(osPath, entry.mode.Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(osPath, entry.modTime, entry.modTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParseTxtar returns a MemFS with the files of a txtar archive, the format
+// of the Go tools' tests: each file starts with a "-- name --" line. The
+// comment before the first file is ignored. Directories are created as
+// needed.
+func ParseTxtar(data []byte) (*MemFS, error) {
+	m := NewMemFS()
+	var name string
+	var content []byte
+	flush := func() error {
+		if name == "" {
+			return nil
+		}
+		if err := m.MkdirAll(path.Dir(name), 0755); err != nil {
+			return err
+		}
+		return m.WriteFile(name, content, 0644)
+	}
+
+	for len(data) > 0 {
+		line := data
+		if idx := bytes.IndexByte(data, '\n'); idx >= 0 {
+			line, data = data[:idx+1], data[idx+1:]
+		} else {
+			data = nil
+		}
+
+		header := strings.TrimRight(string(line), "\r\n")
+		if strings.HasPrefix(header, "-- ") && strings.HasSuffix(header, " --") && len(header) > 6 {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			name, content = strings.TrimSpace(header[3:len(header)-3]), []byte{}
+			continue
+		}
+		if name != "" {
+			content = append(content, line...)
+		}
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Txtar returns the files as a txtar archive, in path order. Content without
+// a final newline gets one, as the format requires.
+func (m *MemFS) Txtar() []byte {
+	var buf bytes.Buffer
+	for _, name := range m.Files() {
+		data, _ := m.ReadFile(name)
+		fmt.Fprintf(&buf, "-- %s --\n", name)
+		buf.Write(data)
+		if len(data) > 0 && data[len(data)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+// Compile-time interface assertions
+var _ FS = new(MemFS)
+var _ ChmodFS = new(MemFS)
+var _ Locker = new(MemFS)
+var _ iofs.ReadDirFS = new(MemFS)
+var _ iofs.ReadFileFS = new(MemFS)
+var _ iofs.StatFS = new(MemFS)
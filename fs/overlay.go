@@ -0,0 +1,605 @@
+package fs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ChangeKind is what an OverlayFS change does to the base filesystem
+type ChangeKind int
+
+const (
+	ChangeCreate ChangeKind = iota // The file doesn't exist in the base
+	ChangeModify                   // The file exists in the base with other content or permissions
+	ChangeRemove                   // The file or directory is removed from the base
+)
+
+// String returns the name of the kind, like "create"
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeCreate:
+		return "create"
+	case ChangeModify:
+		return "modify"
+	case ChangeRemove:
+		return "remove"
+	}
+	return fmt.Sprintf("ChangeKind(%d)", int(k))
+}
+
+// Change is a pending change of an OverlayFS, see OverlayFS.Changes
+type Change struct {
+	Path string
+	Kind ChangeKind
+	Data []byte      // New content, empty for ChangeRemove
+	Mode os.FileMode // New permissions, zero for ChangeRemove
+}
+
+// OverlayFS is a copy-on-write filesystem. It reads through to a base
+// filesystem and keeps every write, rename and remove in memory, so the base
+// is never modified until Commit. It is safe for concurrent use.
+type OverlayFS struct {
+	base FS
+
+	mu      sync.RWMutex
+	files   map[string]*overlayFile // Files written, by path
+	dirs    map[string]bool         // Directories created
+	removed map[string]bool         // Files and directories of the base removed
+	temps   int                     // Temp files created, to give each one a unique name
+}
+
+// overlayFile is the content of a file written to an OverlayFS
+type overlayFile struct {
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+}
+
+// NewOverlayFS returns an empty overlay on top of base. Use FromIOFS to layer
+// on top of an io/fs.FS, like embed.FS.
+func NewOverlayFS(base FS) *OverlayFS {
+	return &OverlayFS{
+		base:    base,
+		files:   make(map[string]*overlayFile),
+		dirs:    make(map[string]bool),
+		removed: make(map[string]bool),
+	}
+}
+
+// clean returns the canonical form of a path: slash-separated, relative and
+// without "." or ".." elements. Paths leaving the root return a
+// *PathEscapeError, see CheckPath.
+func clean(name string) (string, error) {
+	if err := CheckPath(name); err != nil {
+		return "", err
+	}
+	return path.Clean(filepath.ToSlash(name)), nil
+}
+
+// stat returns the info of a path in the merged view. Callers hold the lock.
+func (o *OverlayFS) stat(name string) (os.FileInfo, error) {
+	if o.removed[name] {
+		return nil, os.ErrNotExist
+	}
+	if file, ok := o.files[name]; ok {
+		return &overlayInfo{name: path.Base(name), size: int64(len(file.data)), mode: file.mode, modTime: file.modTime}, nil
+	}
+	if o.dirs[name] {
+		return &overlayInfo{name: path.Base(name), mode: os.ModeDir | 0755, isDir: true}, nil
+	}
+	return o.base.Stat(name)
+}
+
+// checkParent returns an error unless the directory of name exists. Callers
+// hold the lock.
+func (o *OverlayFS) checkParent(op, name string) error {
+	dir := path.Dir(name)
+	if dir == "." {
+		return nil
+	}
+	info, err := o.stat(dir)
+	if err != nil {
+		return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	if !info.IsDir() {
+		return &os.PathError{Op: op, Path: name, Err: fmt.Errorf("%s is not a directory", dir)}
+	}
+	return nil
+}
+
+// write stores the content of a file. Callers hold the lock.
+func (o *OverlayFS) write(name string, data []byte, perm os.FileMode) error {
+	if err := o.checkParent("write", name); err != nil {
+		return err
+	}
+	// Like os.WriteFile, the permissions of an existing file are kept
+	mode := perm.Perm()
+	if info, err := o.stat(name); err == nil {
+		if info.IsDir() {
+			return &os.PathError{Op: "write", Path: name, Err: errors.New("is a directory")}
+		}
+		mode = info.Mode().Perm()
+	}
+
+	delete(o.removed, name)
+	o.files[name] = &overlayFile{data: append([]byte(nil), data...), mode: mode, modTime: time.Now()}
+	return nil
+}
+
+// remove removes a file or an empty directory. Callers hold the lock.
+func (o *OverlayFS) remove(name string) error {
+	info, err := o.stat(name)
+	if err != nil {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrNotExist}
+	}
+	if info.IsDir() {
+		entries, err := o.readDir(name)
+		if err != nil {
+			return err
+		}
+		if len(entries) > 0 {
+			return &os.PathError{Op: "remove", Path: name, Err: errors.New("directory not empty")}
+		}
+	}
+
+	delete(o.files, name)
+	delete(o.dirs, name)
+	// Only what exists in the base needs to be hidden
+	if _, err := o.base.Stat(name); err == nil {
+		o.removed[name] = true
+	}
+	return nil
+}
+
+// readDir lists a directory of the merged view. Callers hold the lock.
+func (o *OverlayFS) readDir(dir string) ([]os.DirEntry, error) {
+	info, err := o.stat(dir)
+	if err != nil {
+		return nil, &os.PathError{Op: "readdir", Path: dir, Err: os.ErrNotExist}
+	}
+	if !info.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: dir, Err: errors.New("not a directory")}
+	}
+
+	entries := make(map[string]os.DirEntry)
+	// A directory is removed only when empty, so the base entries of one
+	// created again are all hidden
+	baseEntries, err := o.base.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	for _, entry := range baseEntries {
+		entries[entry.Name()] = entry
+	}
+
+	// Entries of the overlay replace the ones of the base
+	for name := range entries {
+		child := path.Join(dir, name)
+		if o.removed[child] {
+			delete(entries, name)
+		} else if _, ok := o.files[child]; ok {
+			info, _ := o.stat(child)
+			entries[name] = iofs.FileInfoToDirEntry(info)
+		}
+	}
+	add := func(child string) {
+		if path.Dir(child) == dir && child != dir {
+			info, _ := o.stat(child)
+			entries[path.Base(child)] = iofs.FileInfoToDirEntry(info)
+		}
+	}
+	for child := range o.files {
+		add(child)
+	}
+	for child := range o.dirs {
+		add(child)
+	}
+
+	list := make([]os.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		list = append(list, entry)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list, nil
+}
+
+// ReadFile reads a file from the overlay or, if it wasn't written, the base
+func (o *OverlayFS) ReadFile(name string) ([]byte, error) {
+	name, err := clean(name)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	if o.removed[name] {
+		return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrNotExist}
+	}
+	if file, ok := o.files[name]; ok {
+		return append([]byte(nil), file.data...), nil
+	}
+	return o.base.ReadFile(name)
+}
+
+// WriteFile writes a file to the overlay. The directory of the file must
+// exist, like in the operating system.
+func (o *OverlayFS) WriteFile(name string, data []byte, perm os.FileMode) error {
+	name, err := clean(name)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.write(name, data, perm)
+}
+
+// Stat returns the info of a file or directory of the overlay or the base
+func (o *OverlayFS) Stat(name string) (os.FileInfo, error) {
+	name, err := clean(name)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	info, err := o.stat(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return info, err
+}
+
+// MkdirAll creates a directory and its parents in the overlay
+func (o *OverlayFS) MkdirAll(name string, perm os.FileMode) error {
+	name, err := clean(name)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	current := ""
+	for _, part := range strings.Split(name, "/") {
+		current = path.Join(current, part)
+		if current == "." {
+			continue
+		}
+		if info, err := o.stat(current); err == nil {
+			if !info.IsDir() {
+				return &os.PathError{Op: "mkdir", Path: current, Err: errors.New("not a directory")}
+			}
+			continue
+		}
+		if o.removed[current] {
+			// The base entries were removed one by one before the directory
+			delete(o.removed, current)
+		}
+		o.dirs[current] = true
+	}
+	return nil
+}
+
+// Remove removes a file or an empty directory from the overlay, hiding it if
+// it exists in the base
+func (o *OverlayFS) Remove(name string) error {
+	name, err := clean(name)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	return o.remove(name)
+}
+
+// Rename moves a file within the overlay. Directories can't be renamed.
+func (o *OverlayFS) Rename(oldpath, newpath string) error {
+	oldpath, err := clean(oldpath)
+	if err != nil {
+		return err
+	}
+	newpath, err = clean(newpath)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	info, err := o.stat(oldpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+	if info.IsDir() {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errors.New("renaming directories is not supported")}
+	}
+	if oldpath == newpath {
+		return nil
+	}
+
+	var data []byte
+	if file, ok := o.files[oldpath]; ok {
+		data = file.data
+	} else if data, err = o.base.ReadFile(oldpath); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+
+	// The file replaced, if any, gets the permissions of the one moved
+	if err := o.checkParent("rename", newpath); err != nil {
+		return err
+	}
+	if existing, err := o.stat(newpath); err == nil && existing.IsDir() {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: errors.New("target is a directory")}
+	}
+	delete(o.removed, newpath)
+	o.files[newpath] = &overlayFile{data: data, mode: info.Mode().Perm(), modTime: time.Now()}
+	return o.remove(oldpath)
+}
+
+// TempFile creates an empty file with a unique name in the overlay. The
+// content written to it is stored when it is closed.
+func (o *OverlayFS) TempFile(dir, pattern string) (File, error) {
+	dir, err := clean(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.temps++
+	random := fmt.Sprintf("%d%d", time.Now().UnixNano(), o.temps)
+	// Like os.CreateTemp, the random part replaces the last "*"
+	if idx := strings.LastIndex(pattern, "*"); idx >= 0 {
+		pattern = pattern[:idx] + random + pattern[idx+1:]
+	} else {
+		pattern += random
+	}
+	name := path.Join(dir, pattern)
+	if err := CheckPath(name); err != nil {
+		return nil, err
+	}
+	if err := o.write(name, nil, 0600); err != nil {
+		return nil, err
+	}
+	return &overlayTempFile{overlay: o, name: name}, nil
+}
+
+// ReadDir lists a directory, merging the entries of the overlay and the base
+func (o *OverlayFS) ReadDir(name string) ([]os.DirEntry, error) {
+	name, err := clean(name)
+	if err != nil {
+		return nil, err
+	}
+
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return o.readDir(name)
+}
+
+// Chmod changes the permissions of a file of the overlay, copying it from the
+// base if needed
+func (o *OverlayFS) Chmod(name string, mode os.FileMode) error {
+	name, err := clean(name)
+	if err != nil {
+		return err
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	file, ok := o.files[name]
+	if !ok {
+		info, err := o.stat(name)
+		if err != nil {
+			return &os.PathError{Op: "chmod", Path: name, Err: os.ErrNotExist}
+		}
+		if info.IsDir() {
+			// Directory permissions aren't tracked
+			return nil
+		}
+		data, err := o.base.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		file = &overlayFile{data: data, modTime: info.ModTime()}
+		o.files[name] = file
+	}
+	file.mode = mode.Perm()
+	return nil
+}
+
+// Changes returns the changes of the overlay to the base, in path order.
+// Files written with the content and permissions they have in the base, like
+// temp files renamed back and forth, are not changes. The data of the changes
+// are copies, safe to modify.
+func (o *OverlayFS) Changes() ([]Change, error) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	changes, err := o.changes()
+	if err != nil {
+		return nil, err
+	}
+	for i := range changes {
+		if changes[i].Data != nil {
+			changes[i].Data = append([]byte(nil), changes[i].Data...)
+		}
+	}
+	return changes, nil
+}
+
+// changes implements Changes. Callers hold the lock.
+func (o *OverlayFS) changes() ([]Change, error) {
+	var changes []Change
+	for name, file := range o.files {
+		change := Change{Path: name, Kind: ChangeCreate, Data: file.data, Mode: file.mode}
+		info, err := o.base.Stat(name)
+		if err == nil && !info.IsDir() {
+			data, err := o.base.ReadFile(name)
+			if err != nil {
+				return nil, err
+			}
+			if bytes.Equal(data, file.data) && info.Mode().Perm() == file.mode {
+				continue
+			}
+			change.Kind = ChangeModify
+		} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	for name := range o.removed {
+		changes = append(changes, Change{Path: name, Kind: ChangeRemove})
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes, nil
+}
+
+// Commit writes the changes of the overlay to the base and empties the
+// overlay. On error, the changes not committed yet stay in the overlay, so
+// Commit can be retried.
+func (o *OverlayFS) Commit() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	changes, err := o.changes()
+	if err != nil {
+		return err
+	}
+
+	// Directories first, so files can be written in them
+	dirs := make([]string, 0, len(o.dirs))
+	for dir := range o.dirs {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		if err := o.base.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to commit %s: %w", dir, err)
+		}
+		delete(o.dirs, dir)
+	}
+
+	// Removed directories go after the files in them
+	sort.SliceStable(changes, func(i, j int) bool {
+		if changes[i].Kind == ChangeRemove && changes[j].Kind == ChangeRemove {
+			return changes[i].Path > changes[j].Path
+		}
+		return changes[i].Kind != ChangeRemove && changes[j].Kind == ChangeRemove
+	})
+	for _, change := range changes {
+		if err := o.commit(change); err != nil {
+			return fmt.Errorf("failed to commit %s: %w", change.Path, err)
+		}
+	}
+
+	// What is left are files with the same content as the base
+	o.files = make(map[string]*overlayFile)
+	return nil
+}
+
+// commit applies a single change to the base and forgets it. Callers hold
+// the lock.
+func (o *OverlayFS) commit(change Change) error {
+	if change.Kind == ChangeRemove {
+		if err := o.base.Remove(change.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		delete(o.removed, change.Path)
+		return nil
+	}
+
+	if err := o.base.WriteFile(change.Path, change.Data, change.Mode); err != nil {
+		return err
+	}
+	// WriteFile keeps the permissions of existing files
+	if chmoder, ok := o.base.(ChmodFS); ok && change.Kind == ChangeModify {
+		if err := chmoder.Chmod(change.Path, change.Mode); err != nil {
+			return err
+		}
+	}
+	delete(o.files, change.Path)
+	return nil
+}
+
+// Discard drops every change of the overlay
+func (o *OverlayFS) Discard() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.files = make(map[string]*overlayFile)
+	o.dirs = make(map[string]bool)
+	o.removed = make(map[string]bool)
+}
+
+// overlayInfo is the os.FileInfo of a file or directory of the overlay
+type overlayInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	isDir   bool
+}
+
+func (fi *overlayInfo) Name() string       { return fi.name }
+func (fi *overlayInfo) Size() int64        { return fi.size }
+func (fi *overlayInfo) Mode() os.FileMode  { return fi.mode }
+func (fi *overlayInfo) ModTime() time.Time { return fi.modTime }
+func (fi *overlayInfo) IsDir() bool        { return fi.isDir }
+func (fi *overlayInfo) Sys() interface{}   { return nil }
+
+// overlayTempFile is a file created by OverlayFS.TempFile
+type overlayTempFile struct {
+	overlay *OverlayFS
+	name    string
+	content bytes.Buffer
+	written bool
+}
+
+func (f *overlayTempFile) Read(p []byte) (int, error) {
+	return 0, io.EOF
+}
+
+func (f *overlayTempFile) Write(p []byte) (int, error) {
+	f.written = true
+	return f.content.Write(p)
+}
+
+// Close stores the content written, if any
+func (f *overlayTempFile) Close() error {
+	if !f.written {
+		return nil
+	}
+	return f.overlay.WriteFile(f.name, f.content.Bytes(), 0600)
+}
+
+func (f *overlayTempFile) Name() string {
+	return f.name
+}
+
+func (f *overlayTempFile) Stat() (os.FileInfo, error) {
+	return f.overlay.Stat(f.name)
+}
+
+// Compile-time interface assertions
+var _ FS = new(OverlayFS)
+var _ ChmodFS = new(OverlayFS)
@@ -0,0 +1,33 @@
+package fs
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// PathEscapeError is returned by filesystems for paths that would read or
+// write outside their root: absolute paths, ".." traversal and symlinks
+// pointing outside
+type PathEscapeError struct {
+	Path   string // Path as given
+	Reason string // Why it escapes, e.g. "absolute path"
+}
+
+func (e *PathEscapeError) Error() string {
+	return "path " + e.Path + " escapes the root: " + e.Reason
+}
+
+// CheckPath returns a *PathEscapeError if a path relative to the root of a
+// filesystem is absolute or leaves the root with "..". Paths like "a/../b"
+// that stay inside are allowed. It doesn't look at symlinks.
+func CheckPath(name string) error {
+	slashed := filepath.ToSlash(name)
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.HasPrefix(slashed, "/") {
+		return &PathEscapeError{Path: name, Reason: "absolute path"}
+	}
+	if cleaned := path.Clean(slashed); cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return &PathEscapeError{Path: name, Reason: "parent traversal"}
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package fs
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path"
+)
+
+// Values a WalkDirFunc returns to skip part of the walk
+var (
+	SkipDir = iofs.SkipDir // Skip the directory, or the rest of the directory of a file
+	SkipAll = iofs.SkipAll // Skip everything left
+)
+
+// DirEntry is an entry of a directory listed by ReadDir
+type DirEntry = os.DirEntry
+
+// WalkDirFunc is called by WalkDir for every file and directory, see
+// io/fs.WalkDirFunc
+type WalkDirFunc = iofs.WalkDirFunc
+
+// WalkDir walks the tree at root, calling fn for every file and directory in
+// lexical order, like io/fs.WalkDir. Paths are slash-separated and relative
+// to the root of the filesystem.
+func WalkDir(fsys FS, root string, fn WalkDirFunc) error {
+	info, err := fsys.Stat(root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walkDir(fsys, root, iofs.FileInfoToDirEntry(info), fn)
+	}
+	if errors.Is(err, SkipDir) || errors.Is(err, SkipAll) {
+		return nil
+	}
+	return err
+}
+
+// walkDir walks the directory or file name
+func walkDir(fsys FS, name string, entry DirEntry, fn WalkDirFunc) error {
+	if err := fn(name, entry, nil); err != nil || !entry.IsDir() {
+		if errors.Is(err, SkipDir) && entry.IsDir() {
+			// Skipped directory
+			err = nil
+		}
+		return err
+	}
+
+	entries, err := fsys.ReadDir(name)
+	if err != nil {
+		// Second call, to report the error of ReadDir
+		if err := fn(name, entry, err); err != nil {
+			if errors.Is(err, SkipDir) {
+				err = nil
+			}
+			return err
+		}
+	}
+
+	for _, child := range entries {
+		if err := walkDir(fsys, path.Join(name, child.Name()), child, fn); err != nil {
+			if errors.Is(err, SkipDir) {
+				break
+			}
+			return err
+		}
+	}
+	return nil
+}
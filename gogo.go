@@ -27,9 +27,13 @@ package gogo
 
 import (
 	"bufio"
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/guillermo/gogo/fs"
 	"github.com/guillermo/gogo/internal/realfs"
@@ -42,17 +46,30 @@ type ChangeInfo struct {
 	OldContent []byte
 	NewContent []byte
 	Diff       string
+	Owned      bool        // The file is marked as generated ("Code generated ... DO NOT EDIT."), so gogo owns all of it
+	TypeErrors []TypeError // Errors in the new content, when Options.TypeCheck is TypeCheckMark
 }
 
 // ConflictFunc is called before applying changes
 // Returns true to apply changes, false to skip
 type ConflictFunc func(fs fs.FS, oldPath, newPath string, info ChangeInfo) bool
 
+// ConflictFuncContext is a ConflictFunc that receives the context of the
+// operation, so it can stop asking when the context is cancelled. Its answer
+// is ignored once the context is cancelled.
+type ConflictFuncContext func(ctx context.Context, fs fs.FS, oldPath, newPath string, info ChangeInfo) bool
+
 // Predefined conflict resolution strategies
 var (
 	// ConflictAsk prompts the user in the terminal to accept or reject each change.
 	// This is the default behavior and shows diffs before asking for confirmation.
 	ConflictAsk ConflictFunc = func(fs fs.FS, oldPath, newPath string, info ChangeInfo) bool {
+		return ConflictAskContext(context.Background(), fs, oldPath, newPath, info)
+	}
+
+	// ConflictAskContext is ConflictAsk that stops waiting for the answer when
+	// the context is cancelled.
+	ConflictAskContext ConflictFuncContext = func(ctx context.Context, fs fs.FS, oldPath, newPath string, info ChangeInfo) bool {
 		// Print the change information
 		fmt.Printf("\n=== File: %s ===\n", info.FileName)
 		fmt.Printf("Action: %s\n", info.Action)
@@ -73,14 +90,14 @@ var (
 		// Ask for confirmation
 		fmt.Print("\nApply these changes? [y/N]: ")
 
-		reader := bufio.NewReader(os.Stdin)
-		response, err := reader.ReadString('\n')
-		if err != nil {
+		select {
+		case response := <-stdinLines():
+			response = strings.TrimSpace(strings.ToLower(response))
+			return response == "y" || response == "yes"
+		case <-ctx.Done():
+			fmt.Println()
 			return false
 		}
-
-		response = strings.TrimSpace(strings.ToLower(response))
-		return response == "y" || response == "yes"
 	}
 
 	// ConflictAccept automatically accepts all changes without prompting.
@@ -96,11 +113,70 @@ var (
 	}
 )
 
+var (
+	stdinOnce sync.Once
+	stdinLine = make(chan string)
+)
+
+// stdinLines returns the lines read from stdin. A single reader is shared by
+// all the prompts, so a prompt cancelled while waiting doesn't take the answer
+// to the next one. The channel is closed at the end of the input.
+func stdinLines() <-chan string {
+	stdinOnce.Do(func() {
+		go func() {
+			reader := bufio.NewReader(os.Stdin)
+			for {
+				line, err := reader.ReadString('\n')
+				if err != nil {
+					close(stdinLine)
+					return
+				}
+				stdinLine <- line
+			}
+		}()
+	})
+	return stdinLine
+}
+
+// AcceptOwned returns a conflict resolution function that applies changes to
+// files owned by gogo without prompting, and defers any other change to next.
+func AcceptOwned(next ConflictFunc) ConflictFunc {
+	return func(fs fs.FS, oldPath, newPath string, info ChangeInfo) bool {
+		if info.Owned {
+			return true
+		}
+		return next(fs, oldPath, newPath, info)
+	}
+}
+
 // Options contains options for creating a project
 type Options struct {
-	InitialPackageName string       // Default package name if not set
-	ConflictFunc       ConflictFunc // Conflict resolution function (nil defaults to ConflictAccept)
-	FS                 fs.FS        // Filesystem to use (required)
+	InitialPackageName  string              // Default package name if not set
+	ConflictFunc        ConflictFunc        // Conflict resolution function (nil defaults to ConflictAccept)
+	ConflictFuncContext ConflictFuncContext // Conflict resolution function receiving the context (mutually exclusive with ConflictFunc)
+	FS                  fs.FS               // Filesystem to use (required)
+	File                FileOpts            // Preamble of the files written by the project
+	Buffered            bool                // Keep changes in memory until Flush is called
+	LockTimeout         time.Duration       // Wait for other processes writing the tree (0 uses DefaultLockTimeout, < 0 waits forever)
+	TypeCheck           TypeCheckMode       // Type-check the package of every change before applying it
+	PostProcessors      []PostProcessor     // Rewrite the source of every file before it is compared and written
+	Events              EventListener       // Receives an event for every operation and change
+	Logger              *slog.Logger        // Logs every operation and change, nil logs nothing
+	Exclude             []string            // Paths skipped when scanning the tree, like "vendor/" or "*_gen.go" (nil uses DefaultExclude)
+	History             int                 // Runs kept in .gogo/history for Undo (0 records nothing)
+}
+
+// DefaultLockTimeout is how long a project waits for the lock of another
+// process writing the same tree, see fs.Locker
+const DefaultLockTimeout = 30 * time.Second
+
+// FileOpts contains options for the preamble of a file, the part before the
+// package clause. New files get the preamble and existing files are
+// reconciled with it. Empty options keep what the file already has.
+type FileOpts struct {
+	Header     string // Comment at the top of the file, e.g. a license banner or GeneratedHeader
+	BuildTags  string // Build constraint for the //go:build line (e.g., "linux && amd64")
+	PackageDoc string // Package documentation placed right before the package clause
 }
 
 // StructOpts contains options for creating or modifying a struct
@@ -176,6 +252,26 @@ type TypeOpts struct {
 	PreserveExisting bool     // Preserve existing types not mentioned (only used with Types)
 }
 
+// FileSpec describes the declarations a file should contain. Project.File
+// reconciles all of them with a single read, parse, format and write.
+// The Filename of the nested options is ignored.
+type FileSpec struct {
+	Path      string         // File to create/modify
+	Package   string         // Package name if the file is created (Options.InitialPackageName if empty)
+	Structs   []StructOpts   // Structs to create or modify
+	Methods   []MethodOpts   // Methods to create or replace
+	Functions []FunctionOpts // Functions to create or replace
+	Types     []TypeDef      // Type definitions to create or replace
+	Vars      []Variable     // Variables to create or replace
+	Consts    []Constant     // Constants to create or replace
+	Imports   []string       // Import paths to ensure, optionally preceded by a name (e.g., "sq github.com/Masterminds/squirrel")
+
+	// Prune removes the declarations absent from the spec. Only files owned
+	// by gogo (marked as generated) are pruned; in any other file every
+	// declaration may have been written by hand.
+	Prune bool
+}
+
 // structDef represents a Go struct definition (legacy - for backward compatibility)
 type structDef struct {
 	Name             string
@@ -241,17 +337,53 @@ func NewFS(path string, opts Options) (*Project, error) {
 // New creates a new project
 func New(opts Options) (*Project, error) {
 	if opts.FS == nil {
-		return nil, fmt.Errorf("filesystem is required")
+		return nil, invalidOptions("filesystem is required")
+	}
+
+	if opts.ConflictFunc != nil && opts.ConflictFuncContext != nil {
+		return nil, invalidOptions("ConflictFunc and ConflictFuncContext are mutually exclusive - provide only one")
 	}
 
 	// Default conflict function if not provided
-	if opts.ConflictFunc == nil {
-		opts.ConflictFunc = ConflictAsk
+	conflictFunc := opts.ConflictFuncContext
+	if conflictFunc == nil {
+		if opts.ConflictFunc == nil {
+			opts.ConflictFunc = ConflictAsk
+		}
+		ask := opts.ConflictFunc
+		conflictFunc = func(_ context.Context, fs fs.FS, oldPath, newPath string, info ChangeInfo) bool {
+			return ask(fs, oldPath, newPath, info)
+		}
 	}
 
-	return &Project{
+	p := &Project{
 		opts:         opts,
 		fs:           opts.FS,
-		conflictFunc: opts.ConflictFunc,
-	}, nil
+		conflictFunc: conflictFunc,
+		cache:        newFileCache(),
+	}
+	if opts.TypeCheck != TypeCheckOff {
+		p.checker = newTypeChecker()
+	}
+
+	// Files written to append-only filesystems can't be replaced, so there
+	// are no journal and no history to keep, and every file is written once
+	// by Flush
+	if fsys, ok := opts.FS.(fs.AppendOnlyFS); ok && fsys.AppendOnly() {
+		if !opts.Buffered {
+			return nil, invalidOptions("a filesystem that can't replace files needs Buffered")
+		}
+		if opts.History > 0 {
+			return nil, invalidOptions("History needs a filesystem that can replace files")
+		}
+		p.appendOnly = true
+		return p, nil
+	}
+
+	// Complete or roll back the writes of an interrupted run
+	if err := p.recoverWrites(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
 }
@@ -7,6 +7,7 @@ import (
 	iofs "io/fs"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -19,6 +20,9 @@ type mockFileSystem struct {
 	mu    sync.RWMutex
 	files map[string][]byte
 	dirs  map[string]bool
+	modes map[string]os.FileMode // Permissions of the files, 0644 if not set
+	temps int                    // Temp files created, to give each one a unique name
+	locks map[string]bool        // Locks held, see Lock
 }
 
 // newMockFileSystem creates a new mock filesystem
@@ -26,6 +30,8 @@ func newMockFileSystem() *mockFileSystem {
 	return &mockFileSystem{
 		files: make(map[string][]byte),
 		dirs:  make(map[string]bool),
+		modes: make(map[string]os.FileMode),
+		locks: make(map[string]bool),
 	}
 }
 
@@ -136,8 +142,23 @@ func (fi *mockFileInfo) ModTime() time.Time { return fi.modTime }
 func (fi *mockFileInfo) IsDir() bool        { return fi.isDir }
 func (fi *mockFileInfo) Sys() interface{}   { return nil }
 
+// checkPaths applies the rules of the real filesystem: paths can't be
+// absolute or leave the root with ".."
+func checkPaths(paths ...string) error {
+	for _, path := range paths {
+		if err := fs.CheckPath(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // gogo.FS interface methods
 func (fs *mockFileSystem) ReadFile(path string) ([]byte, error) {
+	if err := checkPaths(path); err != nil {
+		return nil, err
+	}
+
 	files := fs.getFiles()
 	content, exists := files[path]
 	if !exists {
@@ -147,15 +168,28 @@ func (fs *mockFileSystem) ReadFile(path string) ([]byte, error) {
 }
 
 func (fs *mockFileSystem) WriteFile(path string, data []byte, perm os.FileMode) error {
+	if err := checkPaths(path); err != nil {
+		return err
+	}
+
+	fs.mu.Lock()
+	if _, exists := fs.files[path]; !exists {
+		fs.modes[path] = perm.Perm()
+	}
+	fs.mu.Unlock()
 	return fs.writeFile(path, data)
 }
 
 func (fs *mockFileSystem) Stat(path string) (os.FileInfo, error) {
+	if err := checkPaths(path); err != nil {
+		return nil, err
+	}
+
 	fs.mu.RLock()
 	defer fs.mu.RUnlock()
 
-	// Check if it's a directory
-	if fs.dirs[path] {
+	// Check if it's a directory, the root always exists
+	if path == "." || fs.dirs[path] {
 		return &mockFileInfo{
 			name:    filepath.Base(path),
 			mode:    os.ModeDir | 0755,
@@ -170,26 +204,40 @@ func (fs *mockFileSystem) Stat(path string) (os.FileInfo, error) {
 		return nil, os.ErrNotExist
 	}
 
+	mode, ok := fs.modes[path]
+	if !ok {
+		mode = 0644
+	}
+
 	return &mockFileInfo{
 		name:    filepath.Base(path),
 		size:    int64(len(content)),
-		mode:    0644,
+		mode:    mode,
 		modTime: time.Now(),
 		isDir:   false,
 	}, nil
 }
 
 func (fs *mockFileSystem) MkdirAll(path string, perm os.FileMode) error {
+	if err := checkPaths(path); err != nil {
+		return err
+	}
+
 	return fs.mkdirAll(path)
 }
 
 func (fs *mockFileSystem) Remove(path string) error {
+	if err := checkPaths(path); err != nil {
+		return err
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
 	// Check if it's a file
 	if _, ok := fs.files[path]; ok {
 		delete(fs.files, path)
+		delete(fs.modes, path)
 		return nil
 	}
 
@@ -203,12 +251,22 @@ func (fs *mockFileSystem) Remove(path string) error {
 }
 
 func (fs *mockFileSystem) Rename(oldpath, newpath string) error {
+	if err := checkPaths(oldpath, newpath); err != nil {
+		return err
+	}
+
 	fs.mu.Lock()
 	defer fs.mu.Unlock()
 
 	if content, ok := fs.files[oldpath]; ok {
 		fs.files[newpath] = content
 		delete(fs.files, oldpath)
+		if mode, ok := fs.modes[oldpath]; ok {
+			fs.modes[newpath] = mode
+			delete(fs.modes, oldpath)
+		} else {
+			delete(fs.modes, newpath)
+		}
 		return nil
 	}
 
@@ -216,8 +274,23 @@ func (fs *mockFileSystem) Rename(oldpath, newpath string) error {
 }
 
 func (fs *mockFileSystem) TempFile(dir, pattern string) (fs.File, error) {
-	name := fmt.Sprintf("%s/temp_%d.tmp", dir, time.Now().UnixNano())
-	fs.writeFile(name, []byte{})
+	if err := checkPaths(dir); err != nil {
+		return nil, err
+	}
+
+	fs.mu.Lock()
+	fs.temps++
+	random := fmt.Sprintf("%d%d", time.Now().UnixNano(), fs.temps)
+	fs.mu.Unlock()
+
+	// Like os.CreateTemp, the random part replaces the last "*"
+	if idx := strings.LastIndex(pattern, "*"); idx >= 0 {
+		pattern = pattern[:idx] + random + pattern[idx+1:]
+	} else {
+		pattern += random
+	}
+	name := filepath.Join(dir, pattern)
+	fs.WriteFile(name, []byte{}, 0600)
 
 	return &mockFile{
 		name:    name,
@@ -225,6 +298,135 @@ func (fs *mockFileSystem) TempFile(dir, pattern string) (fs.File, error) {
 	}, nil
 }
 
+// ReadDir lists the files and directories directly inside a directory
+func (mfs *mockFileSystem) ReadDir(dir string) ([]os.DirEntry, error) {
+	if err := checkPaths(dir); err != nil {
+		return nil, err
+	}
+
+	dir = filepath.Clean(dir)
+	if dir != "." && !mfs.isDir(dir) {
+		return nil, os.ErrNotExist
+	}
+
+	names := make(map[string]bool)
+	for _, name := range mfs.listPaths() {
+		rel := name
+		if dir != "." {
+			if !strings.HasPrefix(name, dir+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(name, dir+"/")
+		}
+		if idx := strings.Index(rel, "/"); idx >= 0 {
+			rel = rel[:idx]
+		}
+		if rel != "" && rel != "." {
+			names[rel] = true
+		}
+	}
+
+	entries := make([]os.DirEntry, 0, len(names))
+	for name := range names {
+		info, err := mfs.Stat(filepath.Join(dir, name))
+		if err != nil {
+			// Intermediate directory that was never created explicitly
+			info = &mockFileInfo{name: name, mode: os.ModeDir | 0755, modTime: time.Now(), isDir: true}
+		}
+		entries = append(entries, iofs.FileInfoToDirEntry(info))
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, nil
+}
+
+// isDir reports whether a directory exists, created or implied by a file
+func (mfs *mockFileSystem) isDir(dir string) bool {
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+
+	if mfs.dirs[dir] {
+		return true
+	}
+	for name := range mfs.files {
+		if strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// listPaths returns the paths of all files and directories
+func (mfs *mockFileSystem) listPaths() []string {
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+
+	paths := make([]string, 0, len(mfs.files)+len(mfs.dirs))
+	for name := range mfs.files {
+		paths = append(paths, name)
+	}
+	for name := range mfs.dirs {
+		paths = append(paths, name)
+	}
+	return paths
+}
+
+// Chmod changes the permissions of a file
+func (mfs *mockFileSystem) Chmod(path string, mode os.FileMode) error {
+	if err := checkPaths(path); err != nil {
+		return err
+	}
+
+	mfs.mu.Lock()
+	defer mfs.mu.Unlock()
+
+	if _, ok := mfs.files[path]; !ok {
+		return os.ErrNotExist
+	}
+	mfs.modes[path] = mode.Perm()
+	return nil
+}
+
+// Sync does nothing, the content of the files is always stable
+func (mfs *mockFileSystem) Sync(path string) error {
+	if err := checkPaths(path); err != nil {
+		return err
+	}
+
+	mfs.mu.RLock()
+	defer mfs.mu.RUnlock()
+
+	if _, ok := mfs.files[path]; !ok && !mfs.dirs[path] && path != "." {
+		return os.ErrNotExist
+	}
+	return nil
+}
+
+// Lock emulates the lock of a real filesystem, shared by all the projects
+// using this filesystem. No lock file is created.
+func (mfs *mockFileSystem) Lock(name string, timeout time.Duration) (func() error, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		mfs.mu.Lock()
+		if !mfs.locks[name] {
+			mfs.locks[name] = true
+			mfs.mu.Unlock()
+
+			return func() error {
+				mfs.mu.Lock()
+				defer mfs.mu.Unlock()
+				delete(mfs.locks, name)
+				return nil
+			}, nil
+		}
+		mfs.mu.Unlock()
+
+		if timeout >= 0 && !time.Now().Before(deadline) {
+			return nil, fmt.Errorf("%s: %w", name, fs.ErrLocked)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
 // GetFiles returns all files in the filesystem (for testing assertions)
 func (fs *mockFileSystem) GetFiles() map[string][]byte {
 	return fs.getFiles()
@@ -232,6 +434,10 @@ func (fs *mockFileSystem) GetFiles() map[string][]byte {
 
 // Open implements io/fs.FS by wrapping the file content in a read-only file
 func (mfs *mockFileSystem) Open(name string) (iofs.File, error) {
+	if err := checkPaths(name); err != nil {
+		return nil, err
+	}
+
 	content, err := mfs.ReadFile(name)
 	if err != nil {
 		return nil, err
@@ -102,7 +102,8 @@ func parseFiles(content string) map[string]string {
 	return files
 }
 
-// NewMemFS creates an empty mock filesystem for testing
+// NewMemFS creates an empty mock filesystem for testing. Outside of tests,
+// use fs.NewMemFS.
 func NewMemFS() *mockFileSystem {
 	return newMockFileSystem()
 }
@@ -0,0 +1,120 @@
+package gogo
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+)
+
+// GeneratedHeader returns the standard header that marks a file as generated
+// by the given tool. Files carrying it are owned by gogo (see ChangeInfo.Owned).
+func GeneratedHeader(generator string) string {
+	return fmt.Sprintf("Code generated by %s. DO NOT EDIT.", generator)
+}
+
+// isGenerated reports whether Go source code carries the generated code marker
+func isGenerated(content []byte) bool {
+	if len(content) == 0 {
+		return false
+	}
+
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", content, parser.PackageClauseOnly|parser.ParseComments)
+	if err != nil {
+		return false
+	}
+	return ast.IsGenerated(file)
+}
+
+// preamble returns the preamble of a parsed file (everything before the
+// package clause) reconciled with the file options. Options left empty keep
+// what the file already has.
+func preamble(fset *token.FileSet, file *ast.File, content []byte, opts FileOpts) string {
+	// Split the existing preamble into header, directives and package doc
+	var header, directives []string
+	doc := ""
+	for _, group := range file.Comments {
+		if group.Pos() >= file.Package {
+			break
+		}
+		if group == file.Doc {
+			doc = commentGroupText(fset, content, group)
+			continue
+		}
+
+		var headerLines []string
+		for _, comment := range group.List {
+			if strings.HasPrefix(comment.Text, "//go:") || strings.HasPrefix(comment.Text, "// +build") {
+				directives = append(directives, comment.Text)
+			} else {
+				headerLines = append(headerLines, comment.Text)
+			}
+		}
+		if len(headerLines) > 0 {
+			header = append(header, strings.Join(headerLines, "\n"))
+		}
+	}
+
+	if opts.Header != "" {
+		header = []string{commentLines(opts.Header)}
+	}
+
+	if opts.BuildTags != "" {
+		// Replace every existing build constraint with a single //go:build line
+		kept := []string{"//go:build " + opts.BuildTags}
+		for _, directive := range directives {
+			if !strings.HasPrefix(directive, "//go:build") && !strings.HasPrefix(directive, "// +build") {
+				kept = append(kept, directive)
+			}
+		}
+		directives = kept
+	}
+
+	if opts.PackageDoc != "" {
+		doc = commentLines(opts.PackageDoc)
+	}
+
+	// Rebuild the preamble
+	var buf strings.Builder
+	for _, block := range header {
+		buf.WriteString(block + "\n\n")
+	}
+	if len(directives) > 0 {
+		buf.WriteString(strings.Join(directives, "\n") + "\n\n")
+	}
+	if doc != "" {
+		buf.WriteString(doc + "\n")
+	}
+	return buf.String()
+}
+
+// commentGroupText returns the source text of a comment group
+func commentGroupText(fset *token.FileSet, content []byte, group *ast.CommentGroup) string {
+	start := fset.Position(group.Pos()).Offset
+	end := fset.Position(group.End()).Offset
+	return string(content[start:end])
+}
+
+// commentLines turns text into line comments, leaving lines that already are
+// comments untouched
+func commentLines(text string) string {
+	text = strings.TrimRight(text, "\n")
+	if strings.HasPrefix(strings.TrimSpace(text), "/*") {
+		return text
+	}
+
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "//"):
+			// Already a comment
+		case strings.TrimSpace(line) == "":
+			lines[i] = "//"
+		default:
+			lines[i] = "// " + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,265 @@
+package gogo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// historyDir holds a file per recorded run, relative to the root of the
+// filesystem, see Options.History
+const historyDir = ".gogo/history"
+
+// historyEntry is a file changed by a run
+type historyEntry struct {
+	File     string `json:"file"`
+	Previous string `json:"previous,omitempty"` // Hash of the content before the run, empty for files created
+	Content  []byte `json:"content,omitempty"`  // Content before the run
+	Current  string `json:"current"`            // Hash of the content written by the run
+}
+
+// historyRun is the record of a run: the files it changed, in the order they
+// were first written
+type historyRun struct {
+	Started time.Time      `json:"started"`
+	Files   []historyEntry `json:"files"`
+}
+
+// history is the record of the run of the project
+type history struct {
+	mu  sync.Mutex
+	id  string // Name of the file of the run, empty until the first write
+	run historyRun
+}
+
+// recordHistory adds a write to the record of the run, if history is
+// enabled. A file written several times keeps the content it had before the
+// first write.
+func (p *Project) recordHistory(filename string, oldContent, newContent []byte, fileExists bool) error {
+	if p.opts.History <= 0 {
+		return nil
+	}
+
+	p.history.mu.Lock()
+	defer p.history.mu.Unlock()
+
+	first := p.history.id == ""
+	if first {
+		p.history.run = historyRun{Started: time.Now().UTC()}
+		p.history.id = p.history.run.Started.Format("20060102T150405.000000000Z")
+	}
+
+	found := false
+	for i := range p.history.run.Files {
+		if p.history.run.Files[i].File == filename {
+			p.history.run.Files[i].Current = contentHash(newContent)
+			found = true
+		}
+	}
+	if !found {
+		entry := historyEntry{File: filename, Current: contentHash(newContent)}
+		if fileExists {
+			entry.Previous = contentHash(oldContent)
+			entry.Content = oldContent
+		}
+		p.history.run.Files = append(p.history.run.Files, entry)
+	}
+
+	if err := p.saveHistory(); err != nil {
+		return err
+	}
+	if first {
+		return p.pruneHistory()
+	}
+	return nil
+}
+
+// saveHistory writes the record of the run. The caller must hold the history
+// lock.
+func (p *Project) saveHistory() error {
+	content, err := json.MarshalIndent(p.history.run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode history: %w", err)
+	}
+	if err := p.fs.MkdirAll(historyDir, 0755); err != nil {
+		return fmt.Errorf("failed to create history: %w", err)
+	}
+
+	// Replace the record atomically, so it is never seen half written
+	filename := path.Join(historyDir, p.history.id+".json")
+	temp := filename + ".tmp"
+	if err := p.fs.WriteFile(temp, content, 0644); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	if err := p.fs.Rename(temp, filename); err != nil {
+		return fmt.Errorf("failed to write history: %w", err)
+	}
+	return nil
+}
+
+// historyRuns returns the names of the recorded runs, oldest first
+func (p *Project) historyRuns() ([]string, error) {
+	entries, err := p.fs.ReadDir(historyDir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var runs []string
+	for _, entry := range entries {
+		if name := entry.Name(); !entry.IsDir() && strings.HasSuffix(name, ".json") {
+			runs = append(runs, strings.TrimSuffix(name, ".json"))
+		}
+	}
+	// Names are timestamps, so they sort in the order of the runs
+	sort.Strings(runs)
+	return runs, nil
+}
+
+// pruneHistory removes the oldest runs beyond Options.History
+func (p *Project) pruneHistory() error {
+	runs, err := p.historyRuns()
+	if err != nil {
+		return err
+	}
+	for len(runs) > p.opts.History {
+		if err := p.fs.Remove(path.Join(historyDir, runs[0]+".json")); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to prune history: %w", err)
+		}
+		runs = runs[1:]
+	}
+	return nil
+}
+
+// Undo reverts the last run recorded in the history, see Options.History. It
+// restores the files the run modified and removes the files it created, all
+// or nothing. It refuses, with ErrEdited, if any of those files changed since
+// the run. The run is then removed from the history, so calling Undo again
+// reverts the run before it.
+func (p *Project) Undo() error {
+	return p.UndoContext(context.Background())
+}
+
+// UndoContext is Undo with a context. A cancelled context stops it before
+// any file is changed.
+func (p *Project) UndoContext(ctx context.Context) error {
+	unlock, err := p.acquireLock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	p.history.mu.Lock()
+	defer p.history.mu.Unlock()
+
+	runs, err := p.historyRuns()
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		return fmt.Errorf("no run to undo in %s: %w", historyDir, ErrNotFound)
+	}
+	runFile := path.Join(historyDir, runs[len(runs)-1]+".json")
+	runContent, err := p.fs.ReadFile(runFile)
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	var run historyRun
+	if err := json.Unmarshal(runContent, &run); err != nil {
+		return fmt.Errorf("failed to read history %s: %w", runFile, err)
+	}
+
+	// Nothing is changed unless every file is as the run left it
+	var edited []error
+	for _, entry := range run.Files {
+		content, err := p.fs.ReadFile(entry.File)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to read %s: %w", entry.File, err)
+		}
+		if err != nil || contentHash(content) != entry.Current {
+			edited = append(edited, fmt.Errorf("%s: %w", entry.File, ErrEdited))
+		}
+	}
+	if len(edited) > 0 {
+		return fmt.Errorf("cannot undo run of %s: %w", run.Started.Format(time.RFC3339), errors.Join(edited...))
+	}
+
+	// The restored content goes to temp files first. Then the journal
+	// records every change at once, so an interrupted undo is completed by
+	// the next project.
+	var changes []journalEntry
+	journaled := false
+	defer func() {
+		// Once journaled, the temp files are needed to complete the undo
+		if journaled {
+			return
+		}
+		for _, change := range changes {
+			if change.Temp != "" {
+				p.fs.Remove(change.Temp)
+			}
+		}
+	}()
+	for _, entry := range run.Files {
+		change := journalEntry{Target: entry.File, Accepted: true, Previous: entry.Current}
+		if entry.Previous == "" {
+			change.Remove = true
+			changes = append(changes, change)
+			continue
+		}
+
+		info, err := p.fs.Stat(entry.File)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", entry.File, err)
+		}
+		tempFile, err := p.fs.TempFile(filepath.Dir(entry.File), ".gogo-*.tmp")
+		if err != nil {
+			return fmt.Errorf("failed to create temp file: %w", err)
+		}
+		change.Temp = tempFile.Name()
+		tempFile.Close()
+		changes = append(changes, change)
+		if err := p.writeTemp(change.Temp, entry.Content, info.Mode().Perm(), info); err != nil {
+			return err
+		}
+	}
+	// The record of the run goes last
+	changes = append(changes, journalEntry{Target: runFile, Accepted: true, Remove: true, Previous: contentHash(runContent)})
+
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("undo interrupted: %w", err)
+	}
+
+	if err := p.journalSetAll(changes); err != nil {
+		return err
+	}
+	journaled = true
+	for _, change := range changes {
+		if change.Remove {
+			if err := p.fs.Remove(change.Target); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to remove %s: %w", change.Target, err)
+			}
+		} else if err := p.fs.Rename(change.Temp, change.Target); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", change.Target, err)
+		}
+		if err := p.sync(filepath.Dir(change.Target)); err != nil {
+			return fmt.Errorf("failed to sync directory of %s: %w", change.Target, err)
+		}
+		if err := p.journalDone(change.key()); err != nil {
+			return err
+		}
+	}
+
+	// Writes after the undo start a new run
+	if runFile == path.Join(historyDir, p.history.id+".json") {
+		p.history.id = ""
+	}
+	return nil
+}
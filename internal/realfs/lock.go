@@ -0,0 +1,61 @@
+package realfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/guillermo/gogo/fs"
+)
+
+// lockPollInterval is how often a held lock is tried again
+const lockPollInterval = 50 * time.Millisecond
+
+// Lock takes an advisory lock on the file name, created if needed. The lock
+// file records the process holding it, which is reported when the lock can't
+// be taken in time.
+func (fsys *FS) Lock(name string, timeout time.Duration) (func() error, error) {
+	path, err := fsys.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		unlock, err := fsys.tryLock(path)
+		if err == nil {
+			return unlock, nil
+		}
+		if err != errLockHeld {
+			return nil, fmt.Errorf("failed to lock %s: %w", name, err)
+		}
+
+		if timeout >= 0 && !time.Now().Before(deadline) {
+			if pid, _ := lockOwner(path); pid != 0 {
+				return nil, fmt.Errorf("%s: %w (pid %d)", name, fs.ErrLocked, pid)
+			}
+			return nil, fmt.Errorf("%s: %w", name, fs.ErrLocked)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// errLockHeld is returned by tryLock when another holder has the lock
+var errLockHeld = errors.New("lock held")
+
+// lockOwner returns the process id recorded in a lock file
+func lockOwner(path string) (int, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(bytes.TrimSpace(content)))
+}
+
+// ownerRecord returns the content of a lock file held by this process
+func ownerRecord() []byte {
+	return []byte(strconv.Itoa(os.Getpid()) + "\n")
+}
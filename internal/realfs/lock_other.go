@@ -0,0 +1,66 @@
+//go:build !unix
+
+package realfs
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// tryLock creates the lock file at path exclusively, without waiting. A lock
+// file whose holder is no longer running is stale and is removed.
+func (fsys *FS) tryLock(path string) (func() error, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if errors.Is(err, os.ErrExist) {
+		if pid, err := lockOwner(path); err == nil && !processRunning(pid) && removeStale(path) {
+			return fsys.tryLock(path)
+		}
+		return nil, errLockHeld
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := f.Write(ownerRecord()); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	f.Close()
+
+	return func() error {
+		return os.Remove(path)
+	}, nil
+}
+
+// takeoverTimeout is the age after which the takeover file of a process that
+// died while removing a stale lock is removed too
+const takeoverTimeout = 10 * time.Second
+
+// removeStale removes the lock file at path if its holder is no longer
+// running and reports whether the lock is free. Processes finding the same
+// stale lock take turns through a takeover file, so none of them removes the
+// lock another one took in its place.
+func removeStale(path string) bool {
+	takeover := path + ".takeover"
+	f, err := os.OpenFile(takeover, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if info, err := os.Stat(takeover); err == nil && time.Since(info.ModTime()) > takeoverTimeout {
+			os.Remove(takeover)
+		}
+		return false
+	}
+	f.Close()
+	defer os.Remove(takeover)
+
+	// Check the holder again, the lock may have been taken since it was read
+	pid, err := lockOwner(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return true
+	}
+	if err != nil || processRunning(pid) {
+		return false
+	}
+	return os.Remove(path) == nil
+}
@@ -0,0 +1,54 @@
+//go:build unix
+
+package realfs
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// tryLock takes a flock on the file at path without waiting. The kernel
+// releases the lock when the holder exits, so a lock file left behind by a
+// process that crashed is never stale. The holder removes the file when it
+// unlocks.
+func (fsys *FS) tryLock(path string) (func() error, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			f.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, errLockHeld
+			}
+			return nil, err
+		}
+
+		// The file may have been removed by the holder before, between the
+		// open and the flock. A lock on a removed file protects nothing.
+		opened, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if current, err := os.Stat(path); err != nil || !os.SameFile(opened, current) {
+			f.Close()
+			continue
+		}
+
+		// Record the holder for diagnostics
+		if err := f.Truncate(0); err == nil {
+			f.WriteAt(ownerRecord(), 0)
+		}
+
+		return func() error {
+			// Removed while still locked, so the next holder locks a new file
+			err := os.Remove(path)
+			syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+			return errors.Join(err, f.Close())
+		}, nil
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !unix && !windows
+
+package realfs
+
+import (
+	"os"
+	"strconv"
+)
+
+// processRunning reports whether a process with the given id is running,
+// looking for it in /proc. Without /proc there is no way to tell, so the
+// process is assumed to be running and a stale lock file has to be removed
+// by hand.
+func processRunning(pid int) bool {
+	if _, err := os.Stat("/proc"); err != nil {
+		return true
+	}
+	_, err := os.Stat("/proc/" + strconv.Itoa(pid))
+	return err == nil
+}
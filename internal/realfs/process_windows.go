@@ -0,0 +1,26 @@
+package realfs
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processRunning reports whether a process with the given id is running. A
+// process that exited is still found while handles to it are open, so its
+// exit code tells.
+func processRunning(pid int) bool {
+	const stillActive = 259
+
+	handle, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		// Running, but owned by someone else
+		return errors.Is(err, syscall.ERROR_ACCESS_DENIED)
+	}
+	defer syscall.CloseHandle(handle)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(handle, &code); err != nil {
+		return true
+	}
+	return code == stillActive
+}
@@ -3,6 +3,9 @@ package realfs
 import (
 	"io"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 
 	"github.com/guillermo/gogo/fs"
 )
@@ -17,6 +20,7 @@ type File interface {
 // FileWrapper wraps os.File to implement the File interface
 type FileWrapper struct {
 	*os.File
+	name string // path relative to the filesystem root
 }
 
 // Implement the required interface methods
@@ -33,62 +37,243 @@ func (f *FileWrapper) Close() error {
 }
 
 func (f *FileWrapper) Name() string {
-	return f.File.Name()
+	return f.name
 }
 
 func (f *FileWrapper) Stat() (os.FileInfo, error) {
 	return f.File.Stat()
 }
 
-// FS implements FileSystem using actual OS operations
-type FS struct{}
+// FS implements FileSystem using actual OS operations.
+// All paths are relative to the root directory given to Open.
+type FS struct {
+	root string
+}
+
+// maxLinks is the number of symlinks followed in a path before giving up,
+// like the limit of the operating system
+const maxLinks = 255
+
+// path returns the OS path for a path relative to the root, with its
+// symlinks resolved. Paths that are absolute, use ".." to leave the root or
+// go through a symlink pointing outside return a *fs.PathEscapeError.
+func (fsys *FS) path(name string) (string, error) {
+	return fsys.resolve(name, true)
+}
+
+// pathNoFollow is like path, but leaves the last element of the path alone
+// so Remove and Rename act on a symlink and not on its target
+func (fsys *FS) pathNoFollow(name string) (string, error) {
+	return fsys.resolve(name, false)
+}
+
+// resolve implements path and pathNoFollow. It follows the symlinks
+// component by component, so paths that don't exist yet, like the file
+// being created, are checked too.
+func (fsys *FS) resolve(name string, followLast bool) (string, error) {
+	if err := fs.CheckPath(name); err != nil {
+		return "", err
+	}
+
+	resolved := fsys.root
+	rest := splitPath(name)
+	links := 0
+	for len(rest) > 0 {
+		part := rest[0]
+		rest = rest[1:]
+		switch part {
+		case ".":
+			continue
+		case "..":
+			resolved = filepath.Dir(resolved)
+			continue
+		}
+
+		next := filepath.Join(resolved, part)
+		info, err := os.Lstat(next)
+		if err != nil || info.Mode()&os.ModeSymlink == 0 || (len(rest) == 0 && !followLast) {
+			// Missing elements can't be symlinks, so the rest is taken as is
+			resolved = next
+			if err != nil {
+				resolved = filepath.Join(append([]string{next}, rest...)...)
+				rest = nil
+			}
+			continue
+		}
+
+		links++
+		if links > maxLinks {
+			return "", &fs.PathEscapeError{Path: name, Reason: "too many symlinks"}
+		}
+		target, err := os.Readlink(next)
+		if err != nil {
+			return "", err
+		}
+		if filepath.IsAbs(target) {
+			resolved = filepath.VolumeName(target) + string(filepath.Separator)
+		}
+		rest = append(splitPath(target), rest...)
+	}
+
+	if resolved != fsys.root && !strings.HasPrefix(resolved, fsys.root+string(filepath.Separator)) {
+		return "", &fs.PathEscapeError{Path: name, Reason: "symlink pointing outside"}
+	}
+	return resolved, nil
+}
+
+// splitPath returns the elements of a path, without the volume name
+func splitPath(name string) []string {
+	name = name[len(filepath.VolumeName(name)):]
+	var parts []string
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// wrap returns a FileWrapper that reports its name relative to the root
+func (fs *FS) wrap(f *os.File) *FileWrapper {
+	name, err := filepath.Rel(fs.root, f.Name())
+	if err != nil {
+		name = f.Name()
+	}
+	return &FileWrapper{File: f, name: name}
+}
 
 func (fs *FS) ReadFile(path string) ([]byte, error) {
-	return os.ReadFile(path)
+	name, err := fs.path(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(name)
 }
 
 func (fs *FS) WriteFile(path string, data []byte, perm os.FileMode) error {
-	return os.WriteFile(path, data, perm)
+	name, err := fs.path(path)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, data, perm)
 }
 
 func (fs *FS) Stat(path string) (os.FileInfo, error) {
-	return os.Stat(path)
+	name, err := fs.path(path)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(name)
 }
 
 func (fs *FS) MkdirAll(path string, perm os.FileMode) error {
-	return os.MkdirAll(path, perm)
+	name, err := fs.path(path)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(name, perm)
 }
 
 func (fs *FS) Remove(path string) error {
-	return os.Remove(path)
+	name, err := fs.pathNoFollow(path)
+	if err != nil {
+		return err
+	}
+	return os.Remove(name)
 }
 
 func (fs *FS) Rename(oldpath, newpath string) error {
-	return os.Rename(oldpath, newpath)
+	oldname, err := fs.pathNoFollow(oldpath)
+	if err != nil {
+		return err
+	}
+	newname, err := fs.pathNoFollow(newpath)
+	if err != nil {
+		return err
+	}
+	return os.Rename(oldname, newname)
 }
 
 func (fs *FS) TempFile(dir, pattern string) (fs.File, error) {
-	f, err := os.CreateTemp(dir, pattern)
+	name, err := fs.path(dir)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.CreateTemp(name, pattern)
+	if err != nil {
+		return nil, err
+	}
+	return fs.wrap(f), nil
+}
+
+func (fs *FS) ReadDir(path string) ([]os.DirEntry, error) {
+	name, err := fs.path(path)
 	if err != nil {
 		return nil, err
 	}
-	return &FileWrapper{f}, nil
+	return os.ReadDir(name)
+}
+
+func (fs *FS) Chmod(path string, mode os.FileMode) error {
+	name, err := fs.path(path)
+	if err != nil {
+		return err
+	}
+	return os.Chmod(name, mode)
+}
+
+func (fs *FS) Chown(path string, uid, gid int) error {
+	name, err := fs.path(path)
+	if err != nil {
+		return err
+	}
+	return os.Chown(name, uid, gid)
+}
+
+// Sync flushes a file or a directory to disk. Directories can't be synced on
+// every platform; there it does nothing.
+func (fs *FS) Sync(path string) error {
+	name, err := fs.path(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := f.Sync(); err != nil {
+		if info, statErr := f.Stat(); statErr == nil && info.IsDir() && runtime.GOOS == "windows" {
+			return nil
+		}
+		return err
+	}
+	return nil
 }
 
 func (fs *FS) Open(path string) (fs.File, error) {
-	f, err := os.Open(path)
+	name, err := fs.path(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(name)
 	if err != nil {
 		return nil, err
 	}
-	return &FileWrapper{f}, nil
+	return fs.wrap(f), nil
 }
 
 func (fs *FS) Create(path string) (fs.File, error) {
-	f, err := os.Create(path)
+	name, err := fs.path(path)
 	if err != nil {
 		return nil, err
 	}
-	return &FileWrapper{f}, nil
+	f, err := os.Create(name)
+	if err != nil {
+		return nil, err
+	}
+	return fs.wrap(f), nil
 }
 
 // Open creates a new filesystem instance for the given path.
@@ -105,6 +290,16 @@ func Open(path string) (fs.FS, error) {
 		return nil, err
 	}
 
+	// Resolve the root so that relative roots survive a change of directory,
+	// and so that resolved paths can be compared with it
+	root, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return nil, err
+	}
+
 	// Return a new filesystem instance
-	return &FS{}, nil
+	return &FS{root: root}, nil
 }
@@ -0,0 +1,288 @@
+package gogo
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/guillermo/gogo/fs"
+)
+
+// journalFile records the writes in progress, relative to the root of the
+// filesystem. A run that is interrupted leaves it behind, and the next project
+// opened on the tree completes or rolls back those writes.
+const journalFile = ".gogo-pending"
+
+// journalEntry is a write in progress
+type journalEntry struct {
+	Temp     string `json:"temp"`               // Temp file with the new content
+	Target   string `json:"target"`             // File being replaced
+	Accepted bool   `json:"accepted,omitempty"` // The new content is complete and was accepted
+	Previous string `json:"previous,omitempty"` // Hash of the content being replaced, empty for new files
+	Remove   bool   `json:"remove,omitempty"`   // The target is removed instead, there is no temp file
+}
+
+// key returns the key of the entry in the journal
+func (e journalEntry) key() string {
+	if e.Remove {
+		return "remove:" + e.Target
+	}
+	return e.Temp
+}
+
+// journal is the in-memory copy of the journal file
+type journal struct {
+	mu      sync.Mutex
+	entries map[string]journalEntry // Keyed by journalEntry.key
+}
+
+// contentHash returns the hash of file content recorded in the journal
+func contentHash(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:])
+}
+
+// journalSet records a write in progress. Accepted writes are synced to disk,
+// as the next run completes them if this one is interrupted.
+func (p *Project) journalSet(entry journalEntry) error {
+	p.journal.mu.Lock()
+	defer p.journal.mu.Unlock()
+
+	if p.journal.entries == nil {
+		p.journal.entries = make(map[string]journalEntry)
+	}
+	p.journal.entries[entry.key()] = entry
+	return p.saveJournal(entry.Accepted)
+}
+
+// journalSetAll records several accepted writes at once, so an interrupted
+// run completes all of them or none
+func (p *Project) journalSetAll(entries []journalEntry) error {
+	p.journal.mu.Lock()
+	defer p.journal.mu.Unlock()
+
+	if p.journal.entries == nil {
+		p.journal.entries = make(map[string]journalEntry)
+	}
+	for _, entry := range entries {
+		p.journal.entries[entry.key()] = entry
+	}
+	return p.saveJournal(true)
+}
+
+// journalDone removes a write that is no longer in progress, by its key
+func (p *Project) journalDone(key string) error {
+	p.journal.mu.Lock()
+	defer p.journal.mu.Unlock()
+
+	delete(p.journal.entries, key)
+	return p.saveJournal(false)
+}
+
+// saveJournal writes the journal file, or removes it when there are no writes
+// in progress. The caller must hold the journal lock.
+func (p *Project) saveJournal(durable bool) error {
+	if len(p.journal.entries) == 0 {
+		if err := p.fs.Remove(journalFile); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove journal: %w", err)
+		}
+		return nil
+	}
+
+	entries := make([]journalEntry, 0, len(p.journal.entries))
+	for _, entry := range p.journal.entries {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].key() < entries[j].key() })
+
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode journal: %w", err)
+	}
+
+	// Replace the journal atomically, so it is never seen half written
+	temp := journalFile + ".tmp"
+	if err := p.fs.WriteFile(temp, content, 0644); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if durable {
+		if err := p.sync(temp); err != nil {
+			return fmt.Errorf("failed to sync journal: %w", err)
+		}
+	}
+	if err := p.fs.Rename(temp, journalFile); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	if durable {
+		if err := p.sync("."); err != nil {
+			return fmt.Errorf("failed to sync journal: %w", err)
+		}
+	}
+	return nil
+}
+
+// recoverWrites completes or rolls back the writes of an interrupted run.
+// Accepted writes are completed if the file they replace didn't change since;
+// any other temp file is removed. The leftovers of the writes of older
+// versions are cleaned up too, see legacyFile. Nothing is done while another
+// process holds the lock of the tree, as the journal is then its own.
+func (p *Project) recoverWrites() error {
+	_, err := p.fs.Stat(journalFile)
+	interrupted := err == nil
+	legacy, err := p.legacyFiles()
+	if err != nil {
+		return err
+	}
+	if !interrupted && len(legacy) == 0 {
+		// Nothing was interrupted
+		return nil
+	}
+
+	if locker, ok := p.fs.(fs.Locker); ok {
+		unlock, err := locker.Lock(fs.LockFile, 0)
+		if errors.Is(err, fs.ErrLocked) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to lock project: %w", err)
+		}
+		defer unlock()
+	}
+
+	if interrupted {
+		if err := p.recoverJournal(); err != nil {
+			return err
+		}
+	}
+	return p.removeLegacyFiles(legacy)
+}
+
+// recoverJournal completes or rolls back the writes recorded in the journal
+// and removes it
+func (p *Project) recoverJournal() error {
+	content, err := p.fs.ReadFile(journalFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	var entries []journalEntry
+	if err := json.Unmarshal(content, &entries); err != nil {
+		return fmt.Errorf("failed to read journal %s: %w", journalFile, err)
+	}
+
+	for _, entry := range entries {
+		if entry.Remove {
+			if entry.Accepted && p.unchangedSince(entry) {
+				if err := p.fs.Remove(entry.Target); err != nil {
+					return fmt.Errorf("failed to complete interrupted removal of %s: %w", entry.Target, err)
+				}
+			}
+			continue
+		}
+
+		if _, err := p.fs.Stat(entry.Temp); err != nil {
+			// Already renamed or removed
+			continue
+		}
+
+		if entry.Accepted && p.unchangedSince(entry) {
+			if err := p.fs.Rename(entry.Temp, entry.Target); err != nil {
+				return fmt.Errorf("failed to complete interrupted write of %s: %w", entry.Target, err)
+			}
+			if err := p.sync(filepath.Dir(entry.Target)); err != nil {
+				return fmt.Errorf("failed to complete interrupted write of %s: %w", entry.Target, err)
+			}
+			continue
+		}
+
+		if err := p.fs.Remove(entry.Temp); err != nil {
+			return fmt.Errorf("failed to remove temp file of interrupted write of %s: %w", entry.Target, err)
+		}
+	}
+
+	if err := p.fs.Remove(journalFile); err != nil {
+		return fmt.Errorf("failed to remove journal: %w", err)
+	}
+	return nil
+}
+
+// legacyFile reports whether a file is a leftover of older versions, which
+// wrote through a .gogo-*.go temp file and moved the Go file being replaced
+// to a .go.backup file until the temp file took its place
+func legacyFile(name string) bool {
+	base := path.Base(name)
+	temp, _ := path.Match(".gogo-*.go", base)
+	return temp || strings.HasSuffix(base, ".go.backup")
+}
+
+// legacyFiles returns the leftovers of older versions in the project, in
+// lexical order. Directories that can't be read are skipped.
+func (p *Project) legacyFiles() ([]string, error) {
+	var files []string
+	err := fs.WalkDir(p.fs, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if name != "." && p.excluded(name, entry.IsDir()) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() && legacyFile(name) {
+			files = append(files, name)
+		}
+		return nil
+	})
+	return files, err
+}
+
+// removeLegacyFiles removes the leftovers of older versions. A backup whose
+// file is missing is a write interrupted after the file was moved away, so
+// the backup is restored instead.
+func (p *Project) removeLegacyFiles(files []string) error {
+	for _, name := range files {
+		if target, ok := strings.CutSuffix(name, ".backup"); ok {
+			if _, err := p.fs.Stat(target); errors.Is(err, os.ErrNotExist) {
+				if err := p.fs.Rename(name, target); err != nil {
+					return fmt.Errorf("failed to restore %s: %w", target, err)
+				}
+				continue
+			}
+		}
+		if err := p.fs.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("failed to remove leftover %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// unchangedSince reports whether the target of a journal entry still has the
+// content it had when the write started
+func (p *Project) unchangedSince(entry journalEntry) bool {
+	content, err := p.fs.ReadFile(entry.Target)
+	if err != nil {
+		return errors.Is(err, os.ErrNotExist) && entry.Previous == ""
+	}
+	return entry.Previous != "" && contentHash(content) == entry.Previous
+}
+
+// sync flushes a file or directory to stable storage, if the filesystem
+// supports it
+func (p *Project) sync(path string) error {
+	if syncer, ok := p.fs.(fs.SyncFS); ok {
+		return syncer.Sync(path)
+	}
+	return nil
+}
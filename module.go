@@ -0,0 +1,427 @@
+package gogo
+
+import (
+	"fmt"
+	"go/parser"
+	"go/token"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// goModule is a module found in the project filesystem
+type goModule struct {
+	Dir  string // directory of the go.mod, relative to the project root
+	Path string // module path declared in go.mod
+}
+
+// ModulePath returns the module path declared in the go.mod at the root of
+// the project. When the root only holds a go.work file that uses a single
+// module, the path of that module is returned instead.
+func (p *Project) ModulePath() (string, error) {
+	mod, err := p.readModule(".")
+	if err == nil {
+		return mod.Path, nil
+	}
+
+	// Fall back to a workspace with a single module
+	uses, werr := p.readWorkspace()
+	if werr != nil {
+		return "", err
+	}
+	if len(uses) != 1 {
+		return "", fmt.Errorf("go.work uses %d modules, cannot pick a single module path", len(uses))
+	}
+
+	mod, err = p.readModule(uses[0])
+	if err != nil {
+		return "", err
+	}
+	return mod.Path, nil
+}
+
+// ImportPathFor returns the import path of the package in dir, a directory
+// relative to the project root. The nearest go.mod at or above dir decides the
+// module, so nested modules resolve to their own path. When the root holds a
+// go.work file, the module must be one of the workspace modules.
+func (p *Project) ImportPathFor(dir string) (string, error) {
+	dir = filepath.ToSlash(filepath.Clean(dir))
+	if dir == ".." || strings.HasPrefix(dir, "../") || path.IsAbs(dir) {
+		return "", fmt.Errorf("directory %s is outside the project", dir)
+	}
+
+	mod, err := p.findModule(dir)
+	if err != nil {
+		return "", err
+	}
+
+	// Check the module belongs to the workspace if there is one
+	if uses, err := p.readWorkspace(); err == nil {
+		found := false
+		for _, use := range uses {
+			if use == mod.Dir {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("directory %s is in module %s, which is not used by go.work", dir, mod.Path)
+		}
+	}
+
+	if dir == mod.Dir {
+		return mod.Path, nil
+	}
+	rel := dir
+	if mod.Dir != "." {
+		rel = strings.TrimPrefix(dir, mod.Dir+"/")
+	}
+	return mod.Path + "/" + rel, nil
+}
+
+// findModule returns the module of the nearest go.mod at or above dir
+func (p *Project) findModule(dir string) (goModule, error) {
+	for current := dir; ; current = path.Dir(current) {
+		if _, err := p.fs.Stat(path.Join(current, "go.mod")); err == nil {
+			return p.readModule(current)
+		}
+		if current == "." {
+			break
+		}
+	}
+	return goModule{}, fmt.Errorf("go.mod for directory %s: %w", dir, ErrNotFound)
+}
+
+// readModule reads the go.mod file in dir
+func (p *Project) readModule(dir string) (goModule, error) {
+	filename := path.Join(dir, "go.mod")
+	content, err := p.fs.ReadFile(filename)
+	if err != nil {
+		return goModule{}, fmt.Errorf("failed to read %s: %w", filename, err)
+	}
+
+	modPath := parseModulePath(content)
+	if modPath == "" {
+		return goModule{}, fmt.Errorf("no module directive in %s", filename)
+	}
+
+	return goModule{Dir: dir, Path: modPath}, nil
+}
+
+// readWorkspace returns the module directories used by the go.work file at
+// the project root
+func (p *Project) readWorkspace() ([]string, error) {
+	content, err := p.fs.ReadFile("go.work")
+	if err != nil {
+		return nil, err
+	}
+	return parseWorkUses(content), nil
+}
+
+// parseModulePath extracts the module path from go.mod content
+func parseModulePath(content []byte) string {
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := modFields(line)
+		if len(fields) == 2 && fields[0] == "module" {
+			return fields[1]
+		}
+	}
+	return ""
+}
+
+// parseWorkUses extracts the directories of the use directives in go.work
+// content, cleaned and relative to the workspace root
+func parseWorkUses(content []byte) []string {
+	var uses []string
+	inBlock := false
+
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := modFields(line)
+		switch {
+		case len(fields) == 0:
+			continue
+		case inBlock && fields[0] == ")":
+			inBlock = false
+		case inBlock:
+			uses = append(uses, path.Clean(fields[0]))
+		case fields[0] == "use" && len(fields) == 2 && fields[1] == "(":
+			inBlock = true
+		case fields[0] == "use" && len(fields) == 2:
+			uses = append(uses, path.Clean(fields[1]))
+		}
+	}
+
+	return uses
+}
+
+// modFields splits a go.mod or go.work line into fields, dropping comments
+// and unquoting quoted fields
+func modFields(line string) []string {
+	if idx := strings.Index(line, "//"); idx >= 0 {
+		line = line[:idx]
+	}
+
+	fields := strings.Fields(line)
+	for i, field := range fields {
+		if unquoted, err := strconv.Unquote(field); err == nil {
+			fields[i] = unquoted
+		}
+	}
+	return fields
+}
+
+// qualifyTypes rewrites package-qualified type strings so they can be used in
+// filename and returns the imports they need, as "path" or "name path".
+//
+// A qualifier with a slash is an import path, as in "encoding/json.Decoder".
+// A qualifier starting with "./" names a directory of the project, as in
+// "./internal/models.User", and is resolved with ImportPathFor. Types from the
+// package of filename lose their qualifier and need no import.
+//
+// Packages of the project are qualified with the name in their package
+// clause, other packages with the name assumed from their path (see
+// packageNameFor). Imports are named when the name can't be told from the
+// path, or when two packages have the same name.
+func (p *Project) qualifyTypes(filename string, types ...*string) ([]string, error) {
+	var imports []string
+	ownPath := ""
+	ownResolved := false
+	qualifiers := make(map[string]string) // Names by import path
+	paths := make(map[string]string)      // Import paths by name
+
+	for _, typ := range types {
+		var rewriteErr error
+		*typ = rewriteQualifiers(*typ, func(qualifier string) string {
+			if rewriteErr != nil {
+				return qualifier
+			}
+
+			importPath, dir := qualifier, ""
+			if strings.HasPrefix(qualifier, "./") {
+				resolved, err := p.ImportPathFor(qualifier)
+				if err != nil {
+					rewriteErr = fmt.Errorf("failed to resolve package %s: %w", qualifier, err)
+					return qualifier
+				}
+				importPath, dir = resolved, path.Clean(qualifier)
+			}
+
+			// Resolve the import path of the file's own package once
+			if !ownResolved {
+				ownResolved = true
+				ownPath, _ = p.ImportPathFor(filepath.Dir(filename))
+			}
+			if importPath == ownPath {
+				return ""
+			}
+			if name, ok := qualifiers[importPath]; ok {
+				return name + "."
+			}
+
+			// The name of the package, named in the import if it is a guess
+			// that may be wrong
+			name, known := p.packageName(importPath, dir)
+			named := name != packageNameFor(importPath) || !known && name != path.Base(importPath)
+
+			// Packages with the same name get a number
+			base := name
+			for i := 2; paths[name] != ""; i++ {
+				name, named = base+strconv.Itoa(i), true
+			}
+			qualifiers[importPath], paths[name] = name, importPath
+
+			if named {
+				imports = appendUnique(imports, name+" "+importPath)
+			} else {
+				imports = appendUnique(imports, importPath)
+			}
+			return name + "."
+		})
+		if rewriteErr != nil {
+			return nil, rewriteErr
+		}
+	}
+
+	return imports, nil
+}
+
+// packageName returns the name of the package with an import path and
+// whether it is known. The package clause of the packages of the project is
+// read, from dir if given. The name of other packages is assumed from the
+// path, see packageNameFor.
+func (p *Project) packageName(importPath, dir string) (string, bool) {
+	if dir == "" {
+		dir = p.packageDir(importPath)
+	}
+	if dir != "" {
+		if name := p.readPackageName(dir); name != "" {
+			return name, true
+		}
+	}
+	return packageNameFor(importPath), false
+}
+
+// importName returns the name declared by an import of a path without alias,
+// "" if it can't be told: the package clause of the packages of the project,
+// the last element of the path for the standard library
+func (p *Project) importName(importPath string) string {
+	if name, known := p.packageName(importPath, ""); known {
+		return name
+	}
+	if first, _, _ := strings.Cut(importPath, "/"); !strings.Contains(first, ".") {
+		return packageNameFor(importPath)
+	}
+	return ""
+}
+
+// packageDir returns the directory of the package with an import path if it
+// is in the module at the root of the project or in a module of its
+// workspace, "" otherwise
+func (p *Project) packageDir(importPath string) string {
+	dirs := []string{"."}
+	if uses, err := p.readWorkspace(); err == nil {
+		dirs = append(dirs, uses...)
+	}
+	for _, dir := range dirs {
+		mod, err := p.readModule(dir)
+		if err != nil {
+			continue
+		}
+		rel, ok := strings.CutPrefix(importPath, mod.Path)
+		if !ok || rel != "" && rel[0] != '/' {
+			continue
+		}
+		// Nested modules and modules outside the workspace have other paths
+		pkgDir := path.Join(mod.Dir, rel)
+		if resolved, err := p.ImportPathFor(pkgDir); err == nil && resolved == importPath {
+			return pkgDir
+		}
+	}
+	return ""
+}
+
+// readPackageName returns the name in the package clause of the first Go
+// file of dir that has one, including files about to be created. Test files
+// are skipped. It returns "" if there is none.
+func (p *Project) readPackageName(dir string) string {
+	sources := p.cache.overlay(dir)
+	filenames := make([]string, 0, len(sources))
+	for filename := range sources {
+		filenames = append(filenames, filename)
+	}
+	if entries, err := p.fs.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			if filename := path.Join(dir, entry.Name()); !entry.IsDir() && sources[filename] == nil {
+				filenames = append(filenames, filename)
+			}
+		}
+	}
+	sort.Strings(filenames)
+
+	for _, filename := range filenames {
+		if !strings.HasSuffix(filename, ".go") || strings.HasSuffix(filename, "_test.go") {
+			continue
+		}
+		content := sources[filename]
+		if content == nil {
+			var err error
+			if content, err = p.fs.ReadFile(filename); err != nil {
+				continue
+			}
+		}
+		if file, err := parser.ParseFile(token.NewFileSet(), filename, content, parser.PackageClauseOnly); err == nil {
+			return file.Name.Name
+		}
+	}
+	return ""
+}
+
+// rewriteQualifiers calls replace for each slash-containing package qualifier
+// in typ and substitutes the qualifier and its trailing dot with the result
+func rewriteQualifiers(typ string, replace func(qualifier string) string) string {
+	var buf strings.Builder
+
+	isPathChar := func(c byte) bool {
+		return c == '_' || c == '.' || c == '/' || c == '-' || c == '~' ||
+			('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+	}
+
+	for i := 0; i < len(typ); {
+		if !isPathChar(typ[i]) {
+			buf.WriteByte(typ[i])
+			i++
+			continue
+		}
+
+		// Collect a run of path characters
+		start := i
+		for i < len(typ) && isPathChar(typ[i]) {
+			i++
+		}
+		run := typ[start:i]
+
+		// Keep the ellipsis of variadic parameters out of the qualifier
+		if strings.HasPrefix(run, "...") {
+			buf.WriteString("...")
+			run = run[3:]
+		}
+
+		dot := strings.LastIndex(run, ".")
+		if !strings.Contains(run, "/") || dot <= 0 || dot == len(run)-1 {
+			buf.WriteString(run)
+			continue
+		}
+
+		buf.WriteString(replace(run[:dot]))
+		buf.WriteString(run[dot+1:])
+	}
+
+	return buf.String()
+}
+
+// packageNameFor returns the package name assumed for an import path, using
+// the same conventions as goimports: a trailing major version element, a
+// ".vN" suffix and a "go-" prefix are dropped.
+func packageNameFor(importPath string) string {
+	elems := strings.Split(importPath, "/")
+	name := elems[len(elems)-1]
+
+	// Skip major version suffixes like /v2
+	if len(elems) > 1 && isMajorVersion(name) {
+		name = elems[len(elems)-2]
+	}
+
+	name = strings.TrimPrefix(name, "go-")
+	if idx := strings.Index(name, ".v"); idx > 0 && isMajorVersion(name[idx+1:]) {
+		name = name[:idx]
+	}
+
+	// Keep the leading identifier characters
+	for i, c := range name {
+		if !(c == '_' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || (i > 0 && '0' <= c && c <= '9')) {
+			return name[:i]
+		}
+	}
+	return name
+}
+
+// isMajorVersion reports whether s looks like "v2", "v3", ...
+func isMajorVersion(s string) bool {
+	if len(s) < 2 || s[0] != 'v' {
+		return false
+	}
+	_, err := strconv.Atoi(s[1:])
+	return err == nil
+}
+
+// appendUnique appends s to list unless it is already present
+func appendUnique(list []string, s string) []string {
+	for _, existing := range list {
+		if existing == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package gogo
+
+import "os"
+
+// fileOwner returns the owner and group of a file, if the filesystem has them
+func fileOwner(info os.FileInfo) (uid, gid int, ok bool) {
+	return 0, 0, false
+}
@@ -4,8 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"go/ast"
-	"go/format"
-	"go/parser"
 	"go/token"
 	"strings"
 )
@@ -45,72 +43,6 @@ func parseFieldsString(fields string) ([]StructField, error) {
 	return result, nil
 }
 
-// createNewFileWithStruct creates a new Go file with the specified struct
-func createNewFileWithStruct(s structDef, packageName string) ([]byte, error) {
-	if packageName == "" {
-		packageName = "main"
-	}
-
-	var buf bytes.Buffer
-
-	// Write package declaration
-	buf.WriteString(fmt.Sprintf("package %s\n\n", packageName))
-
-	// Write struct
-	buf.WriteString(fmt.Sprintf("type %s struct {\n", s.Name))
-
-	for _, field := range s.EnsureFields {
-		buf.WriteString(fmt.Sprintf("\t%s %s", field.Name, field.Type))
-		if field.Annotation != "" {
-			// Ensure the annotation has backticks if not already present
-			annotation := field.Annotation
-			if !strings.HasPrefix(annotation, "`") {
-				annotation = "`" + annotation + "`"
-			}
-			buf.WriteString(" " + annotation)
-		}
-		buf.WriteString("\n")
-	}
-
-	buf.WriteString("}\n")
-
-	// Format the code
-	formatted, err := format.Source(buf.Bytes())
-	if err != nil {
-		return buf.Bytes(), nil // Return unformatted if format fails
-	}
-
-	return formatted, nil
-}
-
-// modifyExistingFile modifies an existing Go file to ensure/delete struct fields
-func modifyExistingFile(content []byte, s structDef) ([]byte, error) {
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse Go file: %w", err)
-	}
-
-	// Find or create the struct
-	structType := findOrCreateStruct(file, s.Name)
-	if structType == nil {
-		// Add new struct to file
-		structType = createStructDecl(s)
-		file.Decls = append(file.Decls, structType)
-	} else {
-		// Modify existing struct
-		modifyStruct(structType, s)
-	}
-
-	// Format and return the modified code
-	var buf bytes.Buffer
-	if err := format.Node(&buf, fset, file); err != nil {
-		return nil, fmt.Errorf("failed to format Go code: %w", err)
-	}
-
-	return buf.Bytes(), nil
-}
-
 // findOrCreateStruct finds an existing struct or returns nil if not found
 func findOrCreateStruct(file *ast.File, name string) *ast.GenDecl {
 	for _, decl := range file.Decls {
@@ -326,534 +258,49 @@ func parseTypeExpr(typeStr string) ast.Expr {
 	return &ast.Ident{Name: typeStr}
 }
 
-// createNewFileWithMethod creates a new Go file with the specified method
-func createNewFileWithMethod(opts MethodOpts, packageName string) ([]byte, error) {
-	if packageName == "" {
-		packageName = "main"
+// receiverName returns the receiver name of a method, defaulting to the
+// lowercased first letter of the receiver type
+func receiverName(opts MethodOpts) string {
+	if opts.ReceiverName != "" {
+		return opts.ReceiverName
 	}
 
-	var buf bytes.Buffer
-
-	// Write package declaration
-	buf.WriteString(fmt.Sprintf("package %s\n\n", packageName))
-
-	// If Content is provided, use it directly
-	if opts.Content != "" {
-		// Determine receiver name
-		receiverName := opts.ReceiverName
-		if receiverName == "" {
-			// Default receiver name (first letter of type, skipping pointer)
-			typeWithoutPointer := strings.TrimPrefix(opts.ReceiverType, "*")
-			if len(typeWithoutPointer) > 0 {
-				receiverName = strings.ToLower(string(typeWithoutPointer[0]))
-			} else {
-				receiverName = "r"
-			}
-		}
-		buf.WriteString(fmt.Sprintf("func (%s %s) %s%s\n", receiverName, opts.ReceiverType, opts.Name, opts.Content))
-	} else {
-		// Write method
-		receiverName := opts.ReceiverName
-		if receiverName == "" {
-			// Default receiver name (first letter of type, skipping pointer)
-			typeWithoutPointer := strings.TrimPrefix(opts.ReceiverType, "*")
-			if len(typeWithoutPointer) > 0 {
-				receiverName = strings.ToLower(string(typeWithoutPointer[0]))
-			} else {
-				receiverName = "r"
-			}
-		}
-
-		buf.WriteString(fmt.Sprintf("func (%s %s) %s(", receiverName, opts.ReceiverType, opts.Name))
-
-		// Add parameters
-		for i, param := range opts.Parameters {
-			if i > 0 {
-				buf.WriteString(", ")
-			}
-			buf.WriteString(fmt.Sprintf("%s %s", param.Name, param.Type))
-		}
-
-		buf.WriteString(")")
-
-		// Add return type
-		if opts.ReturnType != "" {
-			buf.WriteString(" " + opts.ReturnType)
-		}
-
-		buf.WriteString(" {\n")
-
-		// Add body
-		if opts.Body != "" {
-			// Split body into lines and indent
-			lines := strings.Split(opts.Body, "\n")
-			for _, line := range lines {
-				if strings.TrimSpace(line) != "" {
-					buf.WriteString("\t" + line + "\n")
-				} else {
-					buf.WriteString("\n")
-				}
-			}
-		}
-
-		buf.WriteString("}\n")
+	// Default receiver name (first letter of type, skipping pointer)
+	typeWithoutPointer := strings.TrimPrefix(opts.ReceiverType, "*")
+	if len(typeWithoutPointer) > 0 {
+		return strings.ToLower(string(typeWithoutPointer[0]))
 	}
-
-	// Format the code
-	formatted, err := format.Source(buf.Bytes())
-	if err != nil {
-		return buf.Bytes(), nil // Return unformatted if format fails
-	}
-
-	return formatted, nil
+	return "r"
 }
 
-// createNewFileWithFunction creates a new Go file with the specified function
-func createNewFileWithFunction(opts FunctionOpts, packageName string) ([]byte, error) {
-	if packageName == "" {
-		packageName = "main"
-	}
-
-	var buf bytes.Buffer
-
-	// Write package declaration
-	buf.WriteString(fmt.Sprintf("package %s\n\n", packageName))
+// methodSource creates the Go code for a method
+func methodSource(opts MethodOpts) string {
+	signature := fmt.Sprintf("func (%s %s) %s", receiverName(opts), opts.ReceiverType, opts.Name)
 
 	// If Content is provided, use it directly
 	if opts.Content != "" {
-		buf.WriteString(fmt.Sprintf("func %s%s\n", opts.Name, opts.Content))
-	} else {
-		// Write function
-		buf.WriteString(fmt.Sprintf("func %s(", opts.Name))
-
-		// Add parameters
-		for i, param := range opts.Parameters {
-			if i > 0 {
-				buf.WriteString(", ")
-			}
-			buf.WriteString(fmt.Sprintf("%s %s", param.Name, param.Type))
-		}
-
-		buf.WriteString(")")
-
-		// Add return type
-		if opts.ReturnType != "" {
-			buf.WriteString(" " + opts.ReturnType)
-		}
-
-		buf.WriteString(" {\n")
-
-		// Add body
-		if opts.Body != "" {
-			// Split body into lines and indent
-			lines := strings.Split(opts.Body, "\n")
-			for _, line := range lines {
-				if strings.TrimSpace(line) != "" {
-					buf.WriteString("\t" + line + "\n")
-				} else {
-					buf.WriteString("\n")
-				}
-			}
-		}
-
-		buf.WriteString("}\n")
-	}
-
-	// Format the code
-	formatted, err := format.Source(buf.Bytes())
-	if err != nil {
-		return buf.Bytes(), nil // Return unformatted if format fails
+		return signature + opts.Content
 	}
-
-	return formatted, nil
+	return signature + funcRest(opts.Parameters, opts.ReturnType, opts.Body)
 }
 
-// createNewFileWithVariable creates a new Go file with the specified variables
-func createNewFileWithVariable(opts VariableOpts, packageName string) ([]byte, error) {
-	if packageName == "" {
-		packageName = "main"
-	}
-
-	var buf bytes.Buffer
-
-	// Write package declaration
-	buf.WriteString(fmt.Sprintf("package %s\n\n", packageName))
-
+// functionSource creates the Go code for a function
+func functionSource(opts FunctionOpts) string {
 	// If Content is provided, use it directly
 	if opts.Content != "" {
-		buf.WriteString(opts.Content)
-	} else {
-		// Write variables
-		for _, variable := range opts.Variables {
-			buf.WriteString("var ")
-			buf.WriteString(variable.Name)
-			if variable.Type != "" {
-				buf.WriteString(" " + variable.Type)
-			}
-			if variable.Value != "" {
-				buf.WriteString(" = " + variable.Value)
-			}
-			buf.WriteString("\n")
-		}
-	}
-
-	// Format the code
-	formatted, err := format.Source(buf.Bytes())
-	if err != nil {
-		return buf.Bytes(), nil // Return unformatted if format fails
-	}
-
-	return formatted, nil
-}
-
-// createNewFileWithConstant creates a new Go file with the specified constants
-func createNewFileWithConstant(opts ConstantOpts, packageName string) ([]byte, error) {
-	if packageName == "" {
-		packageName = "main"
-	}
-
-	var buf bytes.Buffer
-
-	// Write package declaration
-	buf.WriteString(fmt.Sprintf("package %s\n\n", packageName))
-
-	// If Content is provided, use it directly
-	if opts.Content != "" {
-		buf.WriteString(opts.Content)
-	} else {
-		// Write constants
-		for _, constant := range opts.Constants {
-			buf.WriteString("const ")
-			buf.WriteString(constant.Name)
-			if constant.Type != "" {
-				buf.WriteString(" " + constant.Type)
-			}
-			if constant.Value != "" {
-				buf.WriteString(" = " + constant.Value)
-			}
-			buf.WriteString("\n")
-		}
-	}
-
-	// Format the code
-	formatted, err := format.Source(buf.Bytes())
-	if err != nil {
-		return buf.Bytes(), nil // Return unformatted if format fails
+		return "func " + opts.Name + opts.Content
 	}
-
-	return formatted, nil
+	return "func " + opts.Name + funcRest(opts.Parameters, opts.ReturnType, opts.Body)
 }
 
-// createNewFileWithType creates a new Go file with the specified type definitions
-func createNewFileWithType(opts TypeOpts, packageName string) ([]byte, error) {
-	if packageName == "" {
-		packageName = "main"
-	}
-
+// funcRest creates the parameters, return type and body of a function
+func funcRest(parameters []Parameter, returnType, body string) string {
 	var buf bytes.Buffer
 
-	// Write package declaration
-	buf.WriteString(fmt.Sprintf("package %s\n\n", packageName))
-
-	// If Content is provided, use it directly
-	if opts.Content != "" {
-		buf.WriteString(opts.Content)
-	} else {
-		// Write types
-		for _, typeDef := range opts.Types {
-			buf.WriteString("type ")
-			buf.WriteString(typeDef.Name)
-			buf.WriteString(" ")
-			buf.WriteString(typeDef.Definition)
-			buf.WriteString("\n")
-		}
-	}
-
-	// Format the code
-	formatted, err := format.Source(buf.Bytes())
-	if err != nil {
-		return buf.Bytes(), nil // Return unformatted if format fails
-	}
-
-	return formatted, nil
-}
-
-// modifyExistingFileForMethod modifies an existing Go file to ensure/modify methods
-func modifyExistingFileForMethod(content []byte, opts MethodOpts) ([]byte, error) {
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse Go file: %w", err)
-	}
-
-	var methodCode []byte
-	if opts.Content != "" {
-		// Use Content directly with receiver and method name
-		receiverName := opts.ReceiverName
-		if receiverName == "" {
-			// Default receiver name (first letter of type, skipping pointer)
-			typeWithoutPointer := strings.TrimPrefix(opts.ReceiverType, "*")
-			if len(typeWithoutPointer) > 0 {
-				receiverName = strings.ToLower(string(typeWithoutPointer[0]))
-			} else {
-				receiverName = "r"
-			}
-		}
-		methodCode = []byte(fmt.Sprintf("package tmp\n\nfunc (%s %s) %s%s", receiverName, opts.ReceiverType, opts.Name, opts.Content))
-	} else {
-		// For now, just append the method - this is a basic implementation
-		// A full implementation would parse existing methods and merge/replace them
-		var err error
-		methodCode, err = createMethodDeclaration(opts)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create method: %w", err)
-		}
-	}
-
-	// Parse the method code and add to the file
-	methodFile, err := parser.ParseFile(fset, "", methodCode, parser.ParseComments)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse method code: %w", err)
-	}
-
-	// Append the method declaration to the file
-	for _, decl := range methodFile.Decls {
-		if funcDecl, ok := decl.(*ast.FuncDecl); ok {
-			file.Decls = append(file.Decls, funcDecl)
-		}
-	}
-
-	// Format and return the modified code
-	var buf bytes.Buffer
-	if err := format.Node(&buf, fset, file); err != nil {
-		return nil, fmt.Errorf("failed to format Go code: %w", err)
-	}
-
-	return buf.Bytes(), nil
-}
-
-// modifyExistingFileForFunction modifies an existing Go file to ensure/modify functions
-func modifyExistingFileForFunction(content []byte, opts FunctionOpts) ([]byte, error) {
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse Go file: %w", err)
-	}
-
-	var functionCode []byte
-	if opts.Content != "" {
-		// Use Content directly with function name
-		functionCode = []byte(fmt.Sprintf("package tmp\n\nfunc %s%s", opts.Name, opts.Content))
-	} else {
-		// For now, just append the function - this is a basic implementation
-		var err error
-		functionCode, err = createFunctionDeclaration(opts)
-		if err != nil {
-			return nil, fmt.Errorf("failed to create function: %w", err)
-		}
-	}
-
-	// Parse the function code and add to the file
-	functionFile, err := parser.ParseFile(fset, "", functionCode, parser.ParseComments)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse function code: %w", err)
-	}
-
-	// Append the function declaration to the file
-	for _, decl := range functionFile.Decls {
-		if funcDecl, ok := decl.(*ast.FuncDecl); ok {
-			file.Decls = append(file.Decls, funcDecl)
-		}
-	}
-
-	// Format and return the modified code
-	var buf bytes.Buffer
-	if err := format.Node(&buf, fset, file); err != nil {
-		return nil, fmt.Errorf("failed to format Go code: %w", err)
-	}
-
-	return buf.Bytes(), nil
-}
-
-// modifyExistingFileForVariable modifies an existing Go file to ensure/modify variables
-func modifyExistingFileForVariable(content []byte, opts VariableOpts) ([]byte, error) {
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse Go file: %w", err)
-	}
-
-	// If Content is provided, parse and append it
-	if opts.Content != "" {
-		// Parse the content as Go code
-		contentWithPackage := fmt.Sprintf("package tmp\n\n%s", opts.Content)
-		contentFile, err := parser.ParseFile(fset, "", contentWithPackage, parser.ParseComments)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse content: %w", err)
-		}
-		// Add the declarations from content
-		for _, decl := range contentFile.Decls {
-			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.VAR {
-				file.Decls = append(file.Decls, genDecl)
-			}
-		}
-	} else {
-		// For now, just append variables - this is a basic implementation
-		for _, variable := range opts.Variables {
-			varDecl := createVariableDeclaration(variable)
-			file.Decls = append(file.Decls, varDecl)
-		}
-	}
-
-	// Format and return the modified code
-	var buf bytes.Buffer
-	if err := format.Node(&buf, fset, file); err != nil {
-		return nil, fmt.Errorf("failed to format Go code: %w", err)
-	}
-
-	return buf.Bytes(), nil
-}
-
-// modifyExistingFileForConstant modifies an existing Go file to ensure/modify constants
-func modifyExistingFileForConstant(content []byte, opts ConstantOpts) ([]byte, error) {
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse Go file: %w", err)
-	}
-
-	// If Content is provided, parse and append it
-	if opts.Content != "" {
-		// Parse the content as Go code
-		contentWithPackage := fmt.Sprintf("package tmp\n\n%s", opts.Content)
-		contentFile, err := parser.ParseFile(fset, "", contentWithPackage, parser.ParseComments)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse content: %w", err)
-		}
-		// Add the declarations from content
-		for _, decl := range contentFile.Decls {
-			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.CONST {
-				file.Decls = append(file.Decls, genDecl)
-			}
-		}
-	} else {
-		// For now, just append constants - this is a basic implementation
-		for _, constant := range opts.Constants {
-			constDecl := createConstantDeclaration(constant)
-			file.Decls = append(file.Decls, constDecl)
-		}
-	}
-
-	// Format and return the modified code
-	var buf bytes.Buffer
-	if err := format.Node(&buf, fset, file); err != nil {
-		return nil, fmt.Errorf("failed to format Go code: %w", err)
-	}
-
-	return buf.Bytes(), nil
-}
-
-// modifyExistingFileForType modifies an existing Go file to ensure/modify type definitions
-func modifyExistingFileForType(content []byte, opts TypeOpts) ([]byte, error) {
-	fset := token.NewFileSet()
-	file, err := parser.ParseFile(fset, "", content, parser.ParseComments)
-	if err != nil {
-		return nil, fmt.Errorf("failed to parse Go file: %w", err)
-	}
-
-	// If Content is provided, parse and append it
-	if opts.Content != "" {
-		// Parse the content as Go code
-		contentWithPackage := fmt.Sprintf("package tmp\n\n%s", opts.Content)
-		contentFile, err := parser.ParseFile(fset, "", contentWithPackage, parser.ParseComments)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse content: %w", err)
-		}
-		// Add the declarations from content
-		for _, decl := range contentFile.Decls {
-			if genDecl, ok := decl.(*ast.GenDecl); ok && genDecl.Tok == token.TYPE {
-				file.Decls = append(file.Decls, genDecl)
-			}
-		}
-	} else {
-		// For now, just append types - this is a basic implementation
-		for _, typeDef := range opts.Types {
-			typeDecl := createTypeDeclaration(typeDef)
-			file.Decls = append(file.Decls, typeDecl)
-		}
-	}
-
-	// Format and return the modified code
-	var buf bytes.Buffer
-	if err := format.Node(&buf, fset, file); err != nil {
-		return nil, fmt.Errorf("failed to format Go code: %w", err)
-	}
-
-	return buf.Bytes(), nil
-}
-
-// Helper functions to create AST declarations
-
-// createMethodDeclaration creates the Go code for a method
-func createMethodDeclaration(opts MethodOpts) ([]byte, error) {
-	var buf bytes.Buffer
-
-	receiverName := opts.ReceiverName
-	if receiverName == "" {
-		// Default receiver name (first letter of type, skipping pointer)
-		typeWithoutPointer := strings.TrimPrefix(opts.ReceiverType, "*")
-		if len(typeWithoutPointer) > 0 {
-			receiverName = strings.ToLower(string(typeWithoutPointer[0]))
-		} else {
-			receiverName = "r"
-		}
-	}
-
-	buf.WriteString(fmt.Sprintf("package tmp\n\nfunc (%s %s) %s(", receiverName, opts.ReceiverType, opts.Name))
-
-	// Add parameters
-	for i, param := range opts.Parameters {
-		if i > 0 {
-			buf.WriteString(", ")
-		}
-		buf.WriteString(fmt.Sprintf("%s %s", param.Name, param.Type))
-	}
-
-	buf.WriteString(")")
-
-	// Add return type
-	if opts.ReturnType != "" {
-		buf.WriteString(" " + opts.ReturnType)
-	}
-
-	buf.WriteString(" {\n")
-
-	// Add body
-	if opts.Body != "" {
-		// Split body into lines and indent
-		lines := strings.Split(opts.Body, "\n")
-		for _, line := range lines {
-			if strings.TrimSpace(line) != "" {
-				buf.WriteString("\t" + line + "\n")
-			} else {
-				buf.WriteString("\n")
-			}
-		}
-	}
-
-	buf.WriteString("}\n")
-
-	return buf.Bytes(), nil
-}
-
-// createFunctionDeclaration creates the Go code for a function
-func createFunctionDeclaration(opts FunctionOpts) ([]byte, error) {
-	var buf bytes.Buffer
-
-	buf.WriteString(fmt.Sprintf("package tmp\n\nfunc %s(", opts.Name))
+	buf.WriteString("(")
 
 	// Add parameters
-	for i, param := range opts.Parameters {
+	for i, param := range parameters {
 		if i > 0 {
 			buf.WriteString(", ")
 		}
@@ -863,16 +310,16 @@ func createFunctionDeclaration(opts FunctionOpts) ([]byte, error) {
 	buf.WriteString(")")
 
 	// Add return type
-	if opts.ReturnType != "" {
-		buf.WriteString(" " + opts.ReturnType)
+	if returnType != "" {
+		buf.WriteString(" " + returnType)
 	}
 
 	buf.WriteString(" {\n")
 
 	// Add body
-	if opts.Body != "" {
+	if body != "" {
 		// Split body into lines and indent
-		lines := strings.Split(opts.Body, "\n")
+		lines := strings.Split(body, "\n")
 		for _, line := range lines {
 			if strings.TrimSpace(line) != "" {
 				buf.WriteString("\t" + line + "\n")
@@ -882,74 +329,43 @@ func createFunctionDeclaration(opts FunctionOpts) ([]byte, error) {
 		}
 	}
 
-	buf.WriteString("}\n")
+	buf.WriteString("}")
 
-	return buf.Bytes(), nil
+	return buf.String()
 }
 
-// createVariableDeclaration creates an AST variable declaration
-func createVariableDeclaration(variable Variable) *ast.GenDecl {
-	var specs []ast.Spec
-
-	spec := &ast.ValueSpec{
-		Names: []*ast.Ident{{Name: variable.Name}},
+// valueSpecSource creates the Go code for a variable or constant spec,
+// without the var or const keyword
+func valueSpecSource(name, typ, value string) string {
+	source := name
+	if typ != "" {
+		source += " " + typ
 	}
-
-	if variable.Type != "" {
-		spec.Type = parseTypeExpr(variable.Type)
-	}
-
-	if variable.Value != "" {
-		// This is a simplified value parsing - in reality we would need to parse expressions properly
-		spec.Values = []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: variable.Value}}
-	}
-
-	specs = append(specs, spec)
-
-	return &ast.GenDecl{
-		Tok:   token.VAR,
-		Specs: specs,
+	if value != "" {
+		source += " = " + value
 	}
+	return source
 }
 
-// createConstantDeclaration creates an AST constant declaration
-func createConstantDeclaration(constant Constant) *ast.GenDecl {
-	var specs []ast.Spec
-
-	spec := &ast.ValueSpec{
-		Names: []*ast.Ident{{Name: constant.Name}},
-	}
-
-	if constant.Type != "" {
-		spec.Type = parseTypeExpr(constant.Type)
-	}
-
-	if constant.Value != "" {
-		// This is a simplified value parsing - in reality we would need to parse expressions properly
-		spec.Values = []ast.Expr{&ast.BasicLit{Kind: token.STRING, Value: constant.Value}}
-	}
-
-	specs = append(specs, spec)
-
-	return &ast.GenDecl{
-		Tok:   token.CONST,
-		Specs: specs,
-	}
+// typeSpecSource creates the Go code for a type spec, without the type keyword
+func typeSpecSource(typeDef TypeDef) string {
+	return typeDef.Name + " " + typeDef.Definition
 }
 
-// createTypeDeclaration creates an AST type declaration
-func createTypeDeclaration(typeDef TypeDef) *ast.GenDecl {
-	var specs []ast.Spec
-
-	spec := &ast.TypeSpec{
-		Name: &ast.Ident{Name: typeDef.Name},
-		Type: parseTypeExpr(typeDef.Definition),
-	}
-
-	specs = append(specs, spec)
-
-	return &ast.GenDecl{
-		Tok:   token.TYPE,
-		Specs: specs,
-	}
+// receiverTypeName returns the name of the type of a method receiver,
+// without pointers or type parameters
+func receiverTypeName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return receiverTypeName(t.X)
+	case *ast.ParenExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexExpr:
+		return receiverTypeName(t.X)
+	case *ast.IndexListExpr:
+		return receiverTypeName(t.X)
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
 }
@@ -0,0 +1,248 @@
+package gogo
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// PostProcessor rewrites the formatted source of a file before it is
+// compared with the file on disk. Processors run in order, each one on the
+// output of the previous one, and must return valid Go source. They should be
+// idempotent, otherwise every run changes the file.
+type PostProcessor func(filename string, src []byte) ([]byte, error)
+
+// postProcess runs the post-processors of the project on the source of a file
+func (p *Project) postProcess(filename string, src []byte) ([]byte, error) {
+	if len(p.opts.PostProcessors) == 0 {
+		return src, nil
+	}
+
+	for i, process := range p.opts.PostProcessors {
+		out, err := process(filename, src)
+		if err != nil {
+			return nil, fmt.Errorf("post-processor %d failed on %s: %w", i, filename, err)
+		}
+		src = out
+	}
+
+	// Don't let a broken processor write a broken file
+	if _, err := parser.ParseFile(token.NewFileSet(), filename, src, parser.SkipObjectResolution); err != nil {
+		return nil, fmt.Errorf("post-processors returned invalid Go for %s: %w", filename, err)
+	}
+	return src, nil
+}
+
+// GroupImports returns a post-processor that sorts imports into groups
+// separated by a blank line: the standard library first, then other packages,
+// then the packages under any of the local prefixes (e.g. the module path).
+// Import blocks with comments not attached to an import are left as they are.
+func GroupImports(localPrefixes ...string) PostProcessor {
+	return func(filename string, src []byte) ([]byte, error) {
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, filename, src, parser.ParseComments|parser.ImportsOnly)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Go file: %w", err)
+		}
+
+		offset := func(pos token.Pos) int { return fset.Position(pos).Offset }
+
+		var buf bytes.Buffer
+		last := 0
+		for _, decl := range file.Decls {
+			genDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genDecl.Tok != token.IMPORT || !genDecl.Lparen.IsValid() {
+				continue
+			}
+
+			groups := make([][]importText, 3)
+			attached := 0
+			for _, spec := range genDecl.Specs {
+				importSpec := spec.(*ast.ImportSpec)
+				start, end := specRange(importSpec)
+				path, _ := strconv.Unquote(importSpec.Path.Value)
+				group := importGroup(path, localPrefixes)
+				groups[group] = append(groups[group], importText{path: path, text: string(src[offset(start):offset(end)])})
+				if importSpec.Doc != nil {
+					attached++
+				}
+				if importSpec.Comment != nil {
+					attached++
+				}
+			}
+			if commentsIn(file, genDecl) != attached {
+				continue
+			}
+
+			var block strings.Builder
+			block.WriteString("(\n")
+			written := false
+			for _, group := range groups {
+				if len(group) == 0 {
+					continue
+				}
+				if written {
+					block.WriteString("\n")
+				}
+				sort.SliceStable(group, func(i, j int) bool { return group[i].path < group[j].path })
+				for _, spec := range group {
+					block.WriteString("\t" + spec.text + "\n")
+				}
+				written = true
+			}
+			block.WriteString(")")
+
+			buf.Write(src[last:offset(genDecl.Lparen)])
+			buf.WriteString(block.String())
+			last = offset(genDecl.Rparen) + 1
+		}
+		buf.Write(src[last:])
+
+		return format.Source(buf.Bytes())
+	}
+}
+
+// importText is an import spec with its comments, as written in the source
+type importText struct {
+	path string
+	text string
+}
+
+// importGroup returns the group of an import path for GroupImports
+func importGroup(path string, localPrefixes []string) int {
+	for _, prefix := range localPrefixes {
+		prefix = strings.TrimSuffix(prefix, "/")
+		if path == prefix || strings.HasPrefix(path, prefix+"/") {
+			return 2
+		}
+	}
+
+	// Standard library paths have no dot in their first element
+	first, _, _ := strings.Cut(path, "/")
+	if !strings.Contains(first, ".") {
+		return 0
+	}
+	return 1
+}
+
+// commentsIn counts the comment groups inside a declaration
+func commentsIn(file *ast.File, decl ast.Decl) int {
+	count := 0
+	for _, group := range file.Comments {
+		if group.Pos() > decl.Pos() && group.End() < decl.End() {
+			count++
+		}
+	}
+	return count
+}
+
+// Simplify is a post-processor that simplifies code like gofmt -s:
+//   - composite literals drop element types that are implied by the
+//     enclosing literal: []T{T{}} becomes []T{{}}, []*T{&T{}} becomes []*T{{}}
+//   - slice expressions drop a redundant high bound: s[a:len(s)] becomes s[a:]
+//   - range statements drop blank variables: for x, _ = range v becomes
+//     for x = range v, and for _ = range v becomes for range v
+func Simplify(filename string, src []byte) ([]byte, error) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, filename, src, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse Go file: %w", err)
+	}
+
+	ast.Inspect(file, func(node ast.Node) bool {
+		switch n := node.(type) {
+		case *ast.CompositeLit:
+			simplifyCompositeLit(n)
+		case *ast.SliceExpr:
+			// Only for identifiers, as evaluating other expressions twice may
+			// matter
+			if call, ok := n.High.(*ast.CallExpr); ok && len(call.Args) == 1 && !call.Ellipsis.IsValid() && !n.Slice3 {
+				fun, isIdent := call.Fun.(*ast.Ident)
+				arg, argIsIdent := call.Args[0].(*ast.Ident)
+				sliced, slicedIsIdent := n.X.(*ast.Ident)
+				if isIdent && fun.Name == "len" && fun.Obj == nil && argIsIdent && slicedIsIdent && arg.Name == sliced.Name {
+					n.High = nil
+				}
+			}
+		case *ast.RangeStmt:
+			if isBlank(n.Value) {
+				n.Value = nil
+			}
+			if isBlank(n.Key) && n.Value == nil {
+				n.Key = nil
+			}
+		}
+		return true
+	})
+
+	var buf bytes.Buffer
+	if err := format.Node(&buf, fset, file); err != nil {
+		return nil, fmt.Errorf("failed to format Go code: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// simplifyCompositeLit drops the element types implied by a composite literal
+func simplifyCompositeLit(lit *ast.CompositeLit) {
+	var keyType, eltType ast.Expr
+	switch t := lit.Type.(type) {
+	case *ast.ArrayType:
+		eltType = t.Elt
+	case *ast.MapType:
+		keyType, eltType = t.Key, t.Value
+	default:
+		return
+	}
+
+	for i, elt := range lit.Elts {
+		if kv, ok := elt.(*ast.KeyValueExpr); ok {
+			if keyType != nil {
+				kv.Key = simplifyElement(kv.Key, keyType)
+			}
+			kv.Value = simplifyElement(kv.Value, eltType)
+			continue
+		}
+		lit.Elts[i] = simplifyElement(elt, eltType)
+	}
+}
+
+// simplifyElement drops the type of an element of a composite literal when
+// it is the given type, or its address when the type is a pointer to it
+func simplifyElement(elt, typ ast.Expr) ast.Expr {
+	if inner, ok := elt.(*ast.CompositeLit); ok && inner.Type != nil && sameType(inner.Type, typ) {
+		inner.Type = nil
+		return inner
+	}
+
+	star, ok := typ.(*ast.StarExpr)
+	if !ok {
+		return elt
+	}
+	addr, ok := elt.(*ast.UnaryExpr)
+	if !ok || addr.Op != token.AND {
+		return elt
+	}
+	if inner, ok := addr.X.(*ast.CompositeLit); ok && inner.Type != nil && sameType(inner.Type, star.X) {
+		inner.Type = nil
+		return inner
+	}
+	return elt
+}
+
+// sameType reports whether two type expressions are written the same way
+func sameType(a, b ast.Expr) bool {
+	return types.ExprString(a) == types.ExprString(b)
+}
+
+// isBlank reports whether an expression is the blank identifier
+func isBlank(expr ast.Expr) bool {
+	ident, ok := expr.(*ast.Ident)
+	return ok && ident.Name == "_"
+}
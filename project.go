@@ -1,477 +1,1095 @@
 package gogo
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"go/token"
+	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
 
 	"github.com/guillermo/gogo/fs"
 )
 
-// Project represents a Go project being modified
+// Project represents a Go project being modified. It is safe for concurrent
+// use.
 type Project struct {
 	opts         Options
 	fs           fs.FS
-	conflictFunc ConflictFunc
+	conflictFunc ConflictFuncContext
+	cache        *fileCache
+	conflictMu   sync.Mutex // Serializes ConflictFunc calls
+	appendOnly   bool       // Files are written once, with no temp file, see fs.AppendOnlyFS
+
+	// Lock of the filesystem, held while any write is in progress
+	lockMu   sync.Mutex
+	lockRefs int
+	unlock   func() error
+
+	journal journal      // Writes in progress
+	history history      // Record of the run, if Options.History is set
+	checker *typeChecker // Type checker, if Options.TypeCheck is set
+	eventMu sync.Mutex   // Serializes the events sent to Options.Events
+
+	resultsMu sync.Mutex
+	results   map[string]*fileResult // What happened to every file, see Results
 }
 
-// modifyStructInFile modifies or creates a struct in Go source code
-func (p *Project) modifyStructInFile(content []byte, s structDef, defaultPackage string) ([]byte, error) {
-	// This will be implemented with AST parsing
-	// For now, return a placeholder
-	if len(content) == 0 {
-		// Create new file
-		return createNewFileWithStruct(s, defaultPackage)
+// Struct creates or modifies a struct using the unified API
+func (p *Project) Struct(opts StructOpts) error {
+	return p.StructContext(context.Background(), opts)
+}
+
+// StructContext is Struct with a context. A cancelled context stops the
+// operation before the file is written.
+func (p *Project) StructContext(ctx context.Context, opts StructOpts) (err error) {
+	event := Event{Kind: KindStruct, Name: opts.Name, File: opts.Filename}
+	defer p.failed(event, &err)
+
+	s, err := opts.structDef()
+	if err != nil {
+		return err
+	}
+
+	// Validation: Required fields
+	if opts.Filename == "" {
+		return invalidOptions("Filename is required")
+	}
+
+	// Resolve package-qualified field types and collect their imports
+	imports, err := p.qualifyTypes(opts.Filename, s.typeRefs()...)
+	if err != nil {
+		return err
+	}
+
+	// Parse and modify the content
+	return p.updateFile(ctx, event, func(ed *editor) error {
+		if err := ed.ensureStruct(s); err != nil {
+			return fmt.Errorf("failed to modify struct: %w", err)
+		}
+		ed.ensureImports(imports)
+		return nil
+	})
+}
+
+// Method creates or modifies a method using the unified API
+func (p *Project) Method(opts MethodOpts) error {
+	return p.MethodContext(context.Background(), opts)
+}
+
+// MethodContext is Method with a context. A cancelled context stops the
+// operation before the file is written.
+func (p *Project) MethodContext(ctx context.Context, opts MethodOpts) (err error) {
+	event := Event{Kind: KindMethod, Name: methodName(opts.ReceiverType, opts.Name), File: opts.Filename}
+	defer p.failed(event, &err)
+
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	// Validation: Required fields
+	if opts.Filename == "" {
+		return invalidOptions("Filename is required")
 	}
 
-	// Modify existing file
-	return modifyExistingFile(content, s)
+	// Resolve package-qualified types and collect their imports
+	imports, err := p.qualifyTypes(opts.Filename, opts.typeRefs()...)
+	if err != nil {
+		return err
+	}
+
+	// Parse and modify the content
+	return p.updateFile(ctx, event, func(ed *editor) error {
+		if err := ed.ensureMethod(opts); err != nil {
+			return fmt.Errorf("failed to modify method: %w", err)
+		}
+		ed.ensureImports(imports)
+		return nil
+	})
 }
 
-// modifyMethodInFile modifies or creates methods in Go source code
-func (p *Project) modifyMethodInFile(content []byte, opts MethodOpts, defaultPackage string) ([]byte, error) {
-	// This will be implemented with AST parsing
-	// For now, return a placeholder
-	if len(content) == 0 {
-		// Create new file
-		return createNewFileWithMethod(opts, defaultPackage)
+// Function creates or modifies a function using the unified API
+func (p *Project) Function(opts FunctionOpts) error {
+	return p.FunctionContext(context.Background(), opts)
+}
+
+// FunctionContext is Function with a context. A cancelled context stops the
+// operation before the file is written.
+func (p *Project) FunctionContext(ctx context.Context, opts FunctionOpts) (err error) {
+	event := Event{Kind: KindFunction, Name: opts.Name, File: opts.Filename}
+	defer p.failed(event, &err)
+
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	// Validation: Required fields
+	if opts.Filename == "" {
+		return invalidOptions("Filename is required")
+	}
+
+	// Resolve package-qualified types and collect their imports
+	imports, err := p.qualifyTypes(opts.Filename, opts.typeRefs()...)
+	if err != nil {
+		return err
+	}
+
+	// Parse and modify the content
+	return p.updateFile(ctx, event, func(ed *editor) error {
+		if err := ed.ensureFunction(opts); err != nil {
+			return fmt.Errorf("failed to modify function: %w", err)
+		}
+		ed.ensureImports(imports)
+		return nil
+	})
+}
+
+// Variable creates or modifies variables using the unified API
+func (p *Project) Variable(opts VariableOpts) error {
+	return p.VariableContext(context.Background(), opts)
+}
+
+// VariableContext is Variable with a context. A cancelled context stops the
+// operation before the file is written.
+func (p *Project) VariableContext(ctx context.Context, opts VariableOpts) (err error) {
+	event := Event{Kind: KindVariable, Name: names(opts.Variables, func(v Variable) string { return v.Name }), File: opts.Filename}
+	defer p.failed(event, &err)
+
+	// Validation: Variables and Content are mutually exclusive
+	if len(opts.Variables) > 0 && opts.Content != "" {
+		return invalidOptions("Variables and Content are mutually exclusive - provide only one")
+	}
+
+	// Validation: Must provide either Variables or Content
+	if len(opts.Variables) == 0 && opts.Content == "" {
+		return invalidOptions("must provide either Variables or Content")
+	}
+
+	// Validation: Required fields
+	if opts.Filename == "" {
+		return invalidOptions("Filename is required")
+	}
+
+	// Resolve package-qualified types and collect their imports
+	opts.Variables = append([]Variable(nil), opts.Variables...)
+	var types []*string
+	for i := range opts.Variables {
+		types = append(types, &opts.Variables[i].Type)
+	}
+	imports, err := p.qualifyTypes(opts.Filename, types...)
+	if err != nil {
+		return err
+	}
+
+	// Parse and modify the content
+	return p.updateFile(ctx, event, func(ed *editor) error {
+		if err := ensureValues(ed, token.VAR, opts.Content, opts.Variables, opts.DeleteVariables); err != nil {
+			return fmt.Errorf("failed to modify variables: %w", err)
+		}
+		ed.ensureImports(imports)
+		return nil
+	})
+}
+
+// Constant creates or modifies constants using the unified API
+func (p *Project) Constant(opts ConstantOpts) error {
+	return p.ConstantContext(context.Background(), opts)
+}
+
+// ConstantContext is Constant with a context. A cancelled context stops the
+// operation before the file is written.
+func (p *Project) ConstantContext(ctx context.Context, opts ConstantOpts) (err error) {
+	event := Event{Kind: KindConstant, Name: names(opts.Constants, func(c Constant) string { return c.Name }), File: opts.Filename}
+	defer p.failed(event, &err)
+
+	// Validation: Constants and Content are mutually exclusive
+	if len(opts.Constants) > 0 && opts.Content != "" {
+		return invalidOptions("Constants and Content are mutually exclusive - provide only one")
+	}
+
+	// Validation: Must provide either Constants or Content
+	if len(opts.Constants) == 0 && opts.Content == "" {
+		return invalidOptions("must provide either Constants or Content")
+	}
+
+	// Validation: Required fields
+	if opts.Filename == "" {
+		return invalidOptions("Filename is required")
+	}
+
+	// Resolve package-qualified types and collect their imports
+	values := make([]Variable, 0, len(opts.Constants))
+	for _, constant := range opts.Constants {
+		values = append(values, Variable(constant))
+	}
+	var types []*string
+	for i := range values {
+		types = append(types, &values[i].Type)
+	}
+	imports, err := p.qualifyTypes(opts.Filename, types...)
+	if err != nil {
+		return err
 	}
 
-	// Modify existing file
-	return modifyExistingFileForMethod(content, opts)
+	// Parse and modify the content
+	return p.updateFile(ctx, event, func(ed *editor) error {
+		if err := ensureValues(ed, token.CONST, opts.Content, values, opts.DeleteConstants); err != nil {
+			return fmt.Errorf("failed to modify constants: %w", err)
+		}
+		ed.ensureImports(imports)
+		return nil
+	})
 }
 
-// modifyFunctionInFile modifies or creates functions in Go source code
-func (p *Project) modifyFunctionInFile(content []byte, opts FunctionOpts, defaultPackage string) ([]byte, error) {
-	// This will be implemented with AST parsing
-	// For now, return a placeholder
-	if len(content) == 0 {
-		// Create new file
-		return createNewFileWithFunction(opts, defaultPackage)
+// Type creates or modifies type definitions using the unified API
+func (p *Project) Type(opts TypeOpts) error {
+	return p.TypeContext(context.Background(), opts)
+}
+
+// TypeContext is Type with a context. A cancelled context stops the
+// operation before the file is written.
+func (p *Project) TypeContext(ctx context.Context, opts TypeOpts) (err error) {
+	event := Event{Kind: KindType, Name: names(opts.Types, func(t TypeDef) string { return t.Name }), File: opts.Filename}
+	defer p.failed(event, &err)
+
+	// Validation: Types and Content are mutually exclusive
+	if len(opts.Types) > 0 && opts.Content != "" {
+		return invalidOptions("Types and Content are mutually exclusive - provide only one")
+	}
+
+	// Validation: Must provide either Types or Content
+	if len(opts.Types) == 0 && opts.Content == "" {
+		return invalidOptions("must provide either Types or Content")
+	}
+
+	// Validation: Required fields
+	if opts.Filename == "" {
+		return invalidOptions("Filename is required")
+	}
+
+	// Resolve package-qualified types and collect their imports
+	opts.Types = append([]TypeDef(nil), opts.Types...)
+	var types []*string
+	for i := range opts.Types {
+		types = append(types, &opts.Types[i].Definition)
+	}
+	imports, err := p.qualifyTypes(opts.Filename, types...)
+	if err != nil {
+		return err
 	}
 
-	// Modify existing file
-	return modifyExistingFileForFunction(content, opts)
+	// Parse and modify the content
+	return p.updateFile(ctx, event, func(ed *editor) error {
+		if err := ensureTypes(ed, opts.Content, opts.Types, opts.DeleteTypes); err != nil {
+			return fmt.Errorf("failed to modify types: %w", err)
+		}
+		ed.ensureImports(imports)
+		return nil
+	})
 }
 
-// modifyVariableInFile modifies or creates variables in Go source code
-func (p *Project) modifyVariableInFile(content []byte, opts VariableOpts, defaultPackage string) ([]byte, error) {
-	// This will be implemented with AST parsing
-	// For now, return a placeholder
-	if len(content) == 0 {
-		// Create new file
-		return createNewFileWithVariable(opts, defaultPackage)
+// File creates or modifies all the declarations of a file spec in one pass,
+// producing a single change for the file
+func (p *Project) File(spec FileSpec) error {
+	return p.FileContext(context.Background(), spec)
+}
+
+// FileContext is File with a context. A cancelled context stops the
+// operation before the file is written.
+func (p *Project) FileContext(ctx context.Context, spec FileSpec) (err error) {
+	event := Event{Kind: KindFile, Name: spec.names(), File: spec.Path}
+	defer p.failed(event, &err)
+
+	// Validate every declaration and resolve package-qualified types
+	decls, err := spec.decls()
+	if err != nil {
+		return err
+	}
+	imports, err := p.qualifyTypes(spec.Path, decls.types...)
+	if err != nil {
+		return err
 	}
 
-	// Modify existing file
-	return modifyExistingFileForVariable(content, opts)
+	// Parse and modify the content
+	return p.updatePackageFile(ctx, event, spec.Package, func(ed *editor) error {
+		for _, s := range decls.structs {
+			if err := ed.ensureStruct(s); err != nil {
+				return fmt.Errorf("failed to modify struct %s: %w", s.Name, err)
+			}
+		}
+		for _, typeDef := range decls.typeDefs {
+			if err := ed.ensureType(typeDef); err != nil {
+				return fmt.Errorf("failed to modify type %s: %w", typeDef.Name, err)
+			}
+		}
+		for _, constant := range decls.consts {
+			if err := ed.ensureValue(token.CONST, constant.Name, constant.Type, constant.Value); err != nil {
+				return fmt.Errorf("failed to modify constant %s: %w", constant.Name, err)
+			}
+		}
+		for _, variable := range decls.vars {
+			if err := ed.ensureValue(token.VAR, variable.Name, variable.Type, variable.Value); err != nil {
+				return fmt.Errorf("failed to modify variable %s: %w", variable.Name, err)
+			}
+		}
+		for _, function := range decls.functions {
+			if err := ed.ensureFunction(function); err != nil {
+				return fmt.Errorf("failed to modify function %s: %w", function.Name, err)
+			}
+		}
+		for _, method := range decls.methods {
+			if err := ed.ensureMethod(method); err != nil {
+				return fmt.Errorf("failed to modify method %s: %w", method.Name, err)
+			}
+		}
+
+		ed.ensureImports(spec.Imports)
+		ed.ensureImports(imports)
+
+		if spec.Prune && ed.owned() {
+			ed.prune(decls.keep)
+		}
+		return nil
+	})
+}
+
+// fileDecls are the validated declarations of a FileSpec
+type fileDecls struct {
+	structs   []structDef
+	methods   []MethodOpts
+	functions []FunctionOpts
+	typeDefs  []TypeDef
+	vars      []Variable
+	consts    []Constant
+	types     []*string       // Types to qualify, pointing into the declarations
+	keep      map[string]bool // Declarations kept by Prune
 }
 
-// modifyConstantInFile modifies or creates constants in Go source code
-func (p *Project) modifyConstantInFile(content []byte, opts ConstantOpts, defaultPackage string) ([]byte, error) {
-	// This will be implemented with AST parsing
-	// For now, return a placeholder
-	if len(content) == 0 {
-		// Create new file
-		return createNewFileWithConstant(opts, defaultPackage)
+// decls validates the spec and returns copies of its declarations, so the
+// types can be qualified without changing the spec
+func (spec FileSpec) decls() (*fileDecls, error) {
+	// Validation: Required fields
+	if spec.Path == "" {
+		return nil, invalidOptions("Path is required")
+	}
+	if spec.Package != "" && !token.IsIdentifier(spec.Package) {
+		return nil, invalidOptions("invalid Package %q", spec.Package)
+	}
+
+	d := &fileDecls{keep: make(map[string]bool)}
+	for _, opts := range spec.Structs {
+		s, err := opts.structDef()
+		if err != nil {
+			return nil, fmt.Errorf("struct %s: %w", opts.Name, err)
+		}
+		d.structs = append(d.structs, s)
+		if err := d.declare(declKey(token.TYPE, s.Name)); err != nil {
+			return nil, err
+		}
+	}
+	for i := range d.structs {
+		d.types = append(d.types, d.structs[i].typeRefs()...)
+	}
+
+	d.methods = append([]MethodOpts(nil), spec.Methods...)
+	for i := range d.methods {
+		if err := d.methods[i].validate(); err != nil {
+			return nil, fmt.Errorf("method %s: %w", d.methods[i].Name, err)
+		}
+		d.types = append(d.types, d.methods[i].typeRefs()...)
+		if err := d.declare(methodKey(d.methods[i].ReceiverType, d.methods[i].Name)); err != nil {
+			return nil, err
+		}
+	}
+
+	d.functions = append([]FunctionOpts(nil), spec.Functions...)
+	for i := range d.functions {
+		if err := d.functions[i].validate(); err != nil {
+			return nil, fmt.Errorf("function %s: %w", d.functions[i].Name, err)
+		}
+		d.types = append(d.types, d.functions[i].typeRefs()...)
+		if err := d.declare(declKey(token.FUNC, d.functions[i].Name)); err != nil {
+			return nil, err
+		}
 	}
 
-	// Modify existing file
-	return modifyExistingFileForConstant(content, opts)
+	d.typeDefs = append([]TypeDef(nil), spec.Types...)
+	for i := range d.typeDefs {
+		if d.typeDefs[i].Name == "" {
+			return nil, invalidOptions("type Name is required")
+		}
+		d.types = append(d.types, &d.typeDefs[i].Definition)
+		if err := d.declare(declKey(token.TYPE, d.typeDefs[i].Name)); err != nil {
+			return nil, err
+		}
+	}
+
+	d.vars = append([]Variable(nil), spec.Vars...)
+	for i := range d.vars {
+		if d.vars[i].Name == "" {
+			return nil, invalidOptions("variable Name is required")
+		}
+		d.types = append(d.types, &d.vars[i].Type)
+		if err := d.declare(declKey(token.VAR, d.vars[i].Name)); err != nil {
+			return nil, err
+		}
+	}
+
+	d.consts = append([]Constant(nil), spec.Consts...)
+	for i := range d.consts {
+		if d.consts[i].Name == "" {
+			return nil, invalidOptions("constant Name is required")
+		}
+		d.types = append(d.types, &d.consts[i].Type)
+		if err := d.declare(declKey(token.CONST, d.consts[i].Name)); err != nil {
+			return nil, err
+		}
+	}
+	return d, nil
 }
 
-// modifyTypeInFile modifies or creates type definitions in Go source code
-func (p *Project) modifyTypeInFile(content []byte, opts TypeOpts, defaultPackage string) ([]byte, error) {
-	// This will be implemented with AST parsing
-	// For now, return a placeholder
-	if len(content) == 0 {
-		// Create new file
-		return createNewFileWithType(opts, defaultPackage)
+// declare records the key of a declaration of the spec. A name declared twice
+// is an error, as only one of the declarations could be written.
+func (d *fileDecls) declare(key string) error {
+	if d.keep[key] {
+		return invalidOptions("duplicate declaration %s", key)
 	}
+	d.keep[key] = true
+	return nil
+}
 
-	// Modify existing file
-	return modifyExistingFileForType(content, opts)
+// names returns the names of the declarations of the spec, for Event.Name
+func (spec FileSpec) names() string {
+	var list []string
+	for _, opts := range spec.Structs {
+		list = append(list, opts.Name)
+	}
+	for _, typeDef := range spec.Types {
+		list = append(list, typeDef.Name)
+	}
+	for _, constant := range spec.Consts {
+		list = append(list, constant.Name)
+	}
+	for _, variable := range spec.Vars {
+		list = append(list, variable.Name)
+	}
+	for _, function := range spec.Functions {
+		list = append(list, function.Name)
+	}
+	for _, method := range spec.Methods {
+		list = append(list, methodName(method.ReceiverType, method.Name))
+	}
+	return strings.Join(list, ", ")
 }
 
-// Struct creates or modifies a struct using the unified API
-func (p *Project) Struct(opts StructOpts) error {
+// structDef validates the options and prepares the struct definition
+func (opts StructOpts) structDef() (structDef, error) {
 	// Validation: Fields and Content are mutually exclusive
 	if len(opts.Fields) > 0 && opts.Content != "" {
-		return fmt.Errorf("Fields and Content are mutually exclusive - provide only one")
+		return structDef{}, invalidOptions("Fields and Content are mutually exclusive - provide only one")
 	}
 
 	// Validation: Must provide either Fields or Content
 	if len(opts.Fields) == 0 && opts.Content == "" {
-		return fmt.Errorf("must provide either Fields or Content")
+		return structDef{}, invalidOptions("must provide either Fields or Content")
 	}
 
 	// Validation: Required fields
-	if opts.Filename == "" {
-		return fmt.Errorf("Filename is required")
-	}
 	if opts.Name == "" {
-		return fmt.Errorf("struct Name is required")
+		return structDef{}, invalidOptions("struct Name is required")
 	}
 
-	// Prepare the struct definition
-	var s structDef
 	if opts.Content != "" {
-		// Parse the content string to create StructField slice (like CreateOrSetMethodS)
+		// Parse the content string to create StructField slice
 		parsedFields, err := parseFieldsString(opts.Content)
 		if err != nil {
-			return fmt.Errorf("failed to parse fields: %w", err)
+			return structDef{}, fmt.Errorf("failed to parse fields: %w", err)
 		}
-		s = structDef{
+		return structDef{
 			Name:             opts.Name,
 			EnsureFields:     parsedFields,
 			PreserveExisting: true,
-		}
-	} else {
-		// Use Fields-based approach (like CreateOrSetStruct)
-		s = structDef{
-			Name:             opts.Name,
-			EnsureFields:     opts.Fields,
-			DeleteFields:     opts.DeleteFields,
-			PreserveExisting: opts.PreserveExisting,
-		}
-	}
-
-	// Read existing file content if it exists
-	var oldContent []byte
-	_, err := p.fs.Stat(opts.Filename)
-	fileExists := err == nil
-
-	if fileExists {
-		oldContent, err = p.fs.ReadFile(opts.Filename)
-		if err != nil {
-			return fmt.Errorf("failed to read existing file: %w", err)
-		}
+		}, nil
 	}
 
-	// Parse and modify the content
-	newContent, err := p.modifyStructInFile(oldContent, s, p.opts.InitialPackageName)
-	if err != nil {
-		return fmt.Errorf("failed to modify struct: %w", err)
-	}
+	// Use Fields-based approach
+	return structDef{
+		Name:             opts.Name,
+		EnsureFields:     append([]StructField(nil), opts.Fields...),
+		DeleteFields:     opts.DeleteFields,
+		PreserveExisting: opts.PreserveExisting,
+	}, nil
+}
 
-	// Check if there are actual changes
-	if fileExists && string(oldContent) == string(newContent) {
-		// No changes needed
-		return nil
+// typeRefs returns pointers to the field types of the struct
+func (s *structDef) typeRefs() []*string {
+	types := make([]*string, 0, len(s.EnsureFields))
+	for i := range s.EnsureFields {
+		types = append(types, &s.EnsureFields[i].Type)
 	}
-
-	// Apply the changes
-	return p.applyChanges(opts.Filename, oldContent, newContent, fileExists)
+	return types
 }
 
-// Method creates or modifies a method using the unified API
-func (p *Project) Method(opts MethodOpts) error {
+// validate checks the method options, except for the filename
+func (opts MethodOpts) validate() error {
 	// Validation: Parameters/ReturnType/Body and Content are mutually exclusive
 	hasStructuredParams := len(opts.Parameters) > 0 || opts.ReturnType != "" || opts.Body != ""
 	if hasStructuredParams && opts.Content != "" {
-		return fmt.Errorf("Parameters/ReturnType/Body and Content are mutually exclusive - provide only one approach")
+		return invalidOptions("Parameters/ReturnType/Body and Content are mutually exclusive - provide only one approach")
 	}
 
 	// Validation: Must provide either structured params or content
 	if !hasStructuredParams && opts.Content == "" {
-		return fmt.Errorf("must provide either Parameters/ReturnType/Body or Content")
+		return invalidOptions("must provide either Parameters/ReturnType/Body or Content")
 	}
 
 	// Validation: Required fields
-	if opts.Filename == "" {
-		return fmt.Errorf("Filename is required")
-	}
 	if opts.Name == "" {
-		return fmt.Errorf("method Name is required")
+		return invalidOptions("method Name is required")
 	}
 	if opts.ReceiverType == "" {
-		return fmt.Errorf("ReceiverType is required for methods")
+		return invalidOptions("ReceiverType is required for methods")
 	}
 
-	// Read existing file content if it exists
-	var oldContent []byte
-	_, err := p.fs.Stat(opts.Filename)
-	fileExists := err == nil
-
-	if fileExists {
-		oldContent, err = p.fs.ReadFile(opts.Filename)
-		if err != nil {
-			return fmt.Errorf("failed to read existing file: %w", err)
-		}
-	}
+	return nil
+}
 
-	// Parse and modify the content
-	newContent, err := p.modifyMethodInFile(oldContent, opts, p.opts.InitialPackageName)
-	if err != nil {
-		return fmt.Errorf("failed to modify method: %w", err)
+// typeRefs copies the parameters and returns pointers to the parameter and
+// return types, so they can be qualified without touching the caller's slice
+func (opts *MethodOpts) typeRefs() []*string {
+	opts.Parameters = append([]Parameter(nil), opts.Parameters...)
+	types := []*string{&opts.ReturnType}
+	for i := range opts.Parameters {
+		types = append(types, &opts.Parameters[i].Type)
 	}
+	return types
+}
 
-	// Check if there are actual changes
-	if fileExists && string(oldContent) == string(newContent) {
-		// No changes needed
-		return nil
+// methodName returns the name of a method with its receiver type, e.g.
+// User.Name
+func methodName(receiverType, name string) string {
+	receiverType = strings.TrimPrefix(strings.TrimSpace(receiverType), "*")
+	if receiverType == "" {
+		return name
 	}
-
-	// Apply the changes using the same pattern as Struct
-	return p.applyChanges(opts.Filename, oldContent, newContent, fileExists)
+	return receiverType + "." + name
 }
 
-// Function creates or modifies a function using the unified API
-func (p *Project) Function(opts FunctionOpts) error {
+// validate checks the function options, except for the filename
+func (opts FunctionOpts) validate() error {
 	// Validation: Parameters/ReturnType/Body and Content are mutually exclusive
 	hasStructuredParams := len(opts.Parameters) > 0 || opts.ReturnType != "" || opts.Body != ""
 	if hasStructuredParams && opts.Content != "" {
-		return fmt.Errorf("Parameters/ReturnType/Body and Content are mutually exclusive - provide only one approach")
+		return invalidOptions("Parameters/ReturnType/Body and Content are mutually exclusive - provide only one approach")
 	}
 
 	// Validation: Must provide either structured params or content
 	if !hasStructuredParams && opts.Content == "" {
-		return fmt.Errorf("must provide either Parameters/ReturnType/Body or Content")
+		return invalidOptions("must provide either Parameters/ReturnType/Body or Content")
 	}
 
 	// Validation: Required fields
-	if opts.Filename == "" {
-		return fmt.Errorf("Filename is required")
-	}
 	if opts.Name == "" {
-		return fmt.Errorf("function Name is required")
+		return invalidOptions("function Name is required")
 	}
 
-	// Read existing file content if it exists
-	var oldContent []byte
-	_, err := p.fs.Stat(opts.Filename)
-	fileExists := err == nil
+	return nil
+}
 
-	if fileExists {
-		oldContent, err = p.fs.ReadFile(opts.Filename)
-		if err != nil {
-			return fmt.Errorf("failed to read existing file: %w", err)
+// typeRefs copies the parameters and returns pointers to the parameter and
+// return types, so they can be qualified without touching the caller's slice
+func (opts *FunctionOpts) typeRefs() []*string {
+	opts.Parameters = append([]Parameter(nil), opts.Parameters...)
+	types := []*string{&opts.ReturnType}
+	for i := range opts.Parameters {
+		types = append(types, &opts.Parameters[i].Type)
+	}
+	return types
+}
+
+// ensureValues adds variables or constants to the editor, either from raw
+// content or from the given values, and removes the deleted names
+func ensureValues(ed *editor, tok token.Token, content string, values []Variable, deleteNames []string) error {
+	if content != "" {
+		return ed.ensureSource(tok, content)
+	}
+
+	for _, value := range values {
+		if err := ed.ensureValue(tok, value.Name, value.Type, value.Value); err != nil {
+			return err
 		}
 	}
+	for _, name := range deleteNames {
+		ed.deleteSpec(tok, name)
+	}
+	return nil
+}
 
-	// Parse and modify the content
-	newContent, err := p.modifyFunctionInFile(oldContent, opts, p.opts.InitialPackageName)
-	if err != nil {
-		return fmt.Errorf("failed to modify function: %w", err)
+// ensureTypes adds type definitions to the editor, either from raw content or
+// from the given definitions, and removes the deleted names
+func ensureTypes(ed *editor, content string, typeDefs []TypeDef, deleteNames []string) error {
+	if content != "" {
+		return ed.ensureSource(token.TYPE, content)
 	}
 
-	// Check if there are actual changes
-	if fileExists && string(oldContent) == string(newContent) {
-		// No changes needed
-		return nil
+	for _, typeDef := range typeDefs {
+		if err := ed.ensureType(typeDef); err != nil {
+			return err
+		}
+	}
+	for _, name := range deleteNames {
+		ed.deleteSpec(token.TYPE, name)
 	}
+	return nil
+}
 
-	// Apply the changes
-	return p.applyChanges(opts.Filename, oldContent, newContent, fileExists)
+// updateFile reads the file of an event, lets edit change its declarations
+// and applies the result if anything changed
+func (p *Project) updateFile(ctx context.Context, event Event, edit func(ed *editor) error) error {
+	return p.updatePackageFile(ctx, event, "", edit)
 }
 
-// Variable creates or modifies variables using the unified API
-func (p *Project) Variable(opts VariableOpts) error {
-	// Validation: Variables and Content are mutually exclusive
-	if len(opts.Variables) > 0 && opts.Content != "" {
-		return fmt.Errorf("Variables and Content are mutually exclusive - provide only one")
+// updatePackageFile is updateFile with the package name of the file if it is
+// created, Options.InitialPackageName if empty
+func (p *Project) updatePackageFile(ctx context.Context, event Event, packageName string, edit func(ed *editor) error) error {
+	filename := event.File
+
+	// Operations on the same file are serialized
+	defer p.cache.lock(filename)()
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s not modified: %w", filename, err)
 	}
 
-	// Validation: Must provide either Variables or Content
-	if len(opts.Variables) == 0 && opts.Content == "" {
-		return fmt.Errorf("must provide either Variables or Content")
+	// Get the current content, read again only if it changed on disk
+	entry, err := p.cache.load(p.fs, filename)
+	if err != nil {
+		return err
 	}
 
-	// Validation: Required fields
-	if opts.Filename == "" {
-		return fmt.Errorf("Filename is required")
+	// Parse the file, apply the changes and format it once
+	if packageName == "" {
+		packageName = p.opts.InitialPackageName
+	}
+	ed, err := p.cache.editor(filename, entry, packageName)
+	if err != nil {
+		return err
+	}
+	ed.importName = p.importName
+	if err := edit(ed); err != nil {
+		p.cache.forget(entry)
+		return err
 	}
 
-	// Read existing file content if it exists
-	var oldContent []byte
-	_, err := p.fs.Stat(opts.Filename)
-	fileExists := err == nil
+	// Reconcile the header, build constraints and package doc
+	ed.setPreamble(p.opts.File)
 
-	if fileExists {
-		oldContent, err = p.fs.ReadFile(opts.Filename)
-		if err != nil {
-			return fmt.Errorf("failed to read existing file: %w", err)
-		}
+	newContent, err := ed.bytes()
+	if err == nil {
+		newContent, err = p.postProcess(filename, newContent)
 	}
-
-	// Parse and modify the content
-	newContent, err := p.modifyVariableInFile(oldContent, opts, p.opts.InitialPackageName)
 	if err != nil {
-		return fmt.Errorf("failed to modify variables: %w", err)
+		p.cache.forget(entry)
+		return err
 	}
+	// Existing files keep their line endings, byte order mark and final
+	// newline
+	newContent = entry.style.apply(newContent)
+
+	// Report what the operation does, the change itself is reported when it
+	// is written
+	if bytes.Equal(newContent, entry.content) {
+		p.emit(eventUnchanged, event)
+	} else {
+		event.Action = "modify"
+		if !entry.exists {
+			event.Action = "create"
+		}
+		p.emit(eventPlan, event)
+		p.touched(event)
+		entry.events = append(entry.events, event)
+	}
+
+	p.cache.update(entry, ed, newContent)
 
-	// Check if there are actual changes
-	if fileExists && string(oldContent) == string(newContent) {
-		// No changes needed
+	// Check if there are actual changes. Buffered changes are written by Flush.
+	if !entry.dirty() {
+		entry.events = nil
+		p.record(filename, StatusUnchanged, nil)
+		return nil
+	}
+	p.record(filename, StatusPending, nil)
+	if p.opts.Buffered {
 		return nil
 	}
+	return p.flushFile(ctx, filename, entry)
+}
 
-	// Apply the changes
-	return p.applyChanges(opts.Filename, oldContent, newContent, fileExists)
+// Flush writes the changes buffered since the last flush, with a single write
+// and a single ConflictFunc call per file. Files are written in name order;
+// an error in one file does not stop the others.
+func (p *Project) Flush() error {
+	return p.FlushContext(context.Background())
 }
 
-// Constant creates or modifies constants using the unified API
-func (p *Project) Constant(opts ConstantOpts) error {
-	// Validation: Constants and Content are mutually exclusive
-	if len(opts.Constants) > 0 && opts.Content != "" {
-		return fmt.Errorf("Constants and Content are mutually exclusive - provide only one")
+// FlushContext is Flush with a context. A cancelled context stops the flush
+// between files: the files written so far stay written, the changes of the
+// file being written are dropped and the others keep their buffered changes.
+func (p *Project) FlushContext(ctx context.Context) error {
+	// Buffered changes are written as a whole, under a single lock
+	if p.opts.Buffered {
+		release, err := p.acquireLock()
+		if err != nil {
+			return err
+		}
+		defer release()
 	}
 
-	// Validation: Must provide either Constants or Content
-	if len(opts.Constants) == 0 && opts.Content == "" {
-		return fmt.Errorf("must provide either Constants or Content")
+	var errs []error
+	for _, filename := range p.cache.filenames() {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, fmt.Errorf("flush interrupted: %w", err))
+			break
+		}
+		if err := p.flush(ctx, filename); err != nil {
+			errs = append(errs, err)
+		}
 	}
+	return errors.Join(errs...)
+}
 
-	// Validation: Required fields
-	if opts.Filename == "" {
-		return fmt.Errorf("Filename is required")
-	}
+// flush writes the buffered changes of a single file, if any
+func (p *Project) flush(ctx context.Context, filename string) error {
+	defer p.cache.lock(filename)()
 
-	// Read existing file content if it exists
-	var oldContent []byte
-	_, err := p.fs.Stat(opts.Filename)
-	fileExists := err == nil
+	entry := p.cache.get(filename)
+	if entry == nil || !entry.dirty() {
+		return nil
+	}
 
-	if fileExists {
-		oldContent, err = p.fs.ReadFile(opts.Filename)
-		if err != nil {
-			return fmt.Errorf("failed to read existing file: %w", err)
+	// Errors of buffered changes are reported here, as no operation returns
+	// them
+	events := entry.events
+	err := p.flushFile(ctx, filename, entry)
+	if err != nil && !errors.Is(err, ErrRejected) {
+		p.record(filename, StatusFailed, err)
+		for _, event := range events {
+			event.Err = err
+			p.emit(eventError, event)
 		}
 	}
+	return err
+}
 
-	// Parse and modify the content
-	newContent, err := p.modifyConstantInFile(oldContent, opts, p.opts.InitialPackageName)
-	if err != nil {
-		return fmt.Errorf("failed to modify constants: %w", err)
+// acquireLock takes the lock of the filesystem, if it supports locking, and
+// returns the function that releases it. The lock is shared by all the writes
+// of the project in progress and released when the last one is done.
+func (p *Project) acquireLock() (func() error, error) {
+	locker, ok := p.fs.(fs.Locker)
+	if !ok {
+		return func() error { return nil }, nil
 	}
 
-	// Check if there are actual changes
-	if fileExists && string(oldContent) == string(newContent) {
-		// No changes needed
-		return nil
+	p.lockMu.Lock()
+	defer p.lockMu.Unlock()
+
+	if p.lockRefs == 0 {
+		timeout := p.opts.LockTimeout
+		if timeout == 0 {
+			timeout = DefaultLockTimeout
+		}
+		unlock, err := locker.Lock(fs.LockFile, timeout)
+		if err != nil {
+			return nil, fmt.Errorf("failed to lock project: %w", err)
+		}
+		p.unlock = unlock
 	}
+	p.lockRefs++
 
-	// Apply the changes
-	return p.applyChanges(opts.Filename, oldContent, newContent, fileExists)
+	return p.releaseLock, nil
 }
 
-// Type creates or modifies type definitions using the unified API
-func (p *Project) Type(opts TypeOpts) error {
-	// Validation: Types and Content are mutually exclusive
-	if len(opts.Types) > 0 && opts.Content != "" {
-		return fmt.Errorf("Types and Content are mutually exclusive - provide only one")
+// releaseLock releases a reference to the lock taken by acquireLock
+func (p *Project) releaseLock() error {
+	p.lockMu.Lock()
+	defer p.lockMu.Unlock()
+
+	p.lockRefs--
+	if p.lockRefs > 0 {
+		return nil
 	}
+	unlock := p.unlock
+	p.unlock = nil
+	return unlock()
+}
 
-	// Validation: Must provide either Types or Content
-	if len(opts.Types) == 0 && opts.Content == "" {
-		return fmt.Errorf("must provide either Types or Content")
+// Parallel runs tasks with at most n of them at the same time and returns the
+// errors of all of them. A task usually generates code for a group of files;
+// operations on different files run in parallel and operations on the same
+// file are serialized. With n <= 0, runs up to GOMAXPROCS tasks at a time.
+func (p *Project) Parallel(n int, tasks ...func() error) error {
+	if n <= 0 {
+		n = runtime.GOMAXPROCS(0)
 	}
 
-	// Validation: Required fields
-	if opts.Filename == "" {
-		return fmt.Errorf("Filename is required")
+	var wg sync.WaitGroup
+	errs := make([]error, len(tasks))
+	sem := make(chan struct{}, n)
+	for i, task := range tasks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			errs[i] = task()
+		}()
 	}
+	wg.Wait()
 
-	// Read existing file content if it exists
-	var oldContent []byte
-	_, err := p.fs.Stat(opts.Filename)
-	fileExists := err == nil
+	return errors.Join(errs...)
+}
 
-	if fileExists {
-		oldContent, err = p.fs.ReadFile(opts.Filename)
+// flushFile writes the current content of a cached file
+func (p *Project) flushFile(ctx context.Context, filename string, entry *cachedFile) (err error) {
+	// Changes that fail to be written are dropped like rejected ones, the
+	// next operation starts from disk
+	defer func() {
 		if err != nil {
-			return fmt.Errorf("failed to read existing file: %w", err)
+			entry.events = nil
+			p.cache.discard(entry)
 		}
-	}
+	}()
 
-	// Parse and modify the content
-	newContent, err := p.modifyTypeInFile(oldContent, opts, p.opts.InitialPackageName)
+	release, err := p.acquireLock()
 	if err != nil {
-		return fmt.Errorf("failed to modify types: %w", err)
+		return err
 	}
+	defer release()
 
-	// Check if there are actual changes
-	if fileExists && string(oldContent) == string(newContent) {
-		// No changes needed
-		return nil
+	// Make sure the file didn't change on disk since it was read
+	if _, err := p.cache.load(p.fs, filename); err != nil {
+		return err
 	}
 
-	// Apply the changes
-	return p.applyChanges(opts.Filename, oldContent, newContent, fileExists)
+	applied, err := p.applyChanges(ctx, filename, entry.disk, entry.content, entry.exists)
+	if err != nil {
+		return err
+	}
+	events := entry.events
+	entry.events = nil
+	if !applied {
+		p.record(filename, StatusRejected, nil)
+		for _, event := range events {
+			p.emit(eventReject, event)
+		}
+		return fmt.Errorf("%s: %w", filename, ErrRejected)
+	}
+
+	status := StatusModified
+	if !entry.exists {
+		status = StatusCreated
+	}
+	p.counted(filename, entry.disk, entry.content)
+	p.cache.written(p.fs, filename, entry)
+	p.record(filename, status, nil)
+	for _, event := range events {
+		p.emit(eventApply, event)
+	}
+	return nil
 }
 
-// applyChanges applies the changes to a file using the common pattern
-func (p *Project) applyChanges(filename string, oldContent, newContent []byte, fileExists bool) error {
+// applyChanges applies the changes to a file using the common pattern. It
+// reports whether the changes were accepted.
+//
+// The new content is written to a temp file that is renamed over the target,
+// so the file is always either complete or untouched. The temp file is synced
+// before the rename and the directory after it, and the temp file takes the
+// permissions and owner of the file it replaces. Writes in progress are
+// recorded in the journal so an interrupted run can be recovered.
+//
+// A cancelled context stops the write at any point before the rename, and
+// the temp file is removed.
+func (p *Project) applyChanges(ctx context.Context, filename string, oldContent, newContent []byte, fileExists bool) (bool, error) {
 	// Ensure the directory exists
 	dir := filepath.Dir(filename)
 	if dir != "" && dir != "." {
 		if err := p.fs.MkdirAll(dir, 0755); err != nil {
-			return fmt.Errorf("failed to create directory %s: %w", dir, err)
+			return false, fmt.Errorf("failed to create directory %s: %w", dir, err)
 		}
 	}
 
-	// Create temp file
-	tempFile, err := p.fs.TempFile(dir, ".gogo-*.go")
+	// Type-check the package with the new content, before anyone is asked
+	var typeErrors []TypeError
+	if p.checker != nil {
+		var err error
+		if typeErrors, err = p.typeCheck(filename, newContent); err != nil {
+			return false, err
+		}
+		if len(typeErrors) > 0 && p.opts.TypeCheck == TypeCheckRefuse {
+			errs := make([]error, len(typeErrors))
+			for i, typeErr := range typeErrors {
+				errs[i] = typeErr
+			}
+			return false, fmt.Errorf("changes to %s do not type-check: %w", filename, errors.Join(errs...))
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return false, fmt.Errorf("%s not written: %w", filename, err)
+	}
+
+	// Keep the permissions and owner of the file being replaced
+	mode := os.FileMode(0644)
+	var info os.FileInfo
+	if fileExists {
+		var err error
+		if info, err = p.fs.Stat(filename); err != nil {
+			return false, fmt.Errorf("failed to stat existing file: %w", err)
+		}
+		mode = info.Mode().Perm()
+	}
+
+	changeInfo := newChangeInfo(filename, oldContent, newContent, fileExists, typeErrors)
+
+	// Append-only filesystems get the final content once, see fs.AppendOnlyFS
+	if p.appendOnly {
+		if accepted, err := p.ask(ctx, filename, "", changeInfo); err != nil || !accepted {
+			return false, err
+		}
+		if err := p.fs.WriteFile(filename, newContent, mode); err != nil {
+			return false, fmt.Errorf("failed to apply changes: %w", err)
+		}
+		return true, nil
+	}
+
+	// Create temp file. The name is ignored by the Go toolchain, so a leftover
+	// doesn't break the build of the package.
+	tempFile, err := p.fs.TempFile(dir, ".gogo-*.tmp")
 	if err != nil {
-		return fmt.Errorf("failed to create temp file: %w", err)
+		return false, fmt.Errorf("failed to create temp file: %w", err)
 	}
 	tempPath := tempFile.Name()
 	tempFile.Close()
 
+	entry := journalEntry{Temp: tempPath, Target: filename}
+	if fileExists {
+		entry.Previous = contentHash(oldContent)
+	}
+	if err := p.journalSet(entry); err != nil {
+		return false, errors.Join(err, p.removeTemp(tempPath))
+	}
+
 	// Write new content to temp file
-	if err := p.fs.WriteFile(tempPath, newContent, 0644); err != nil {
-		p.fs.Remove(tempPath)
-		return fmt.Errorf("failed to write temp file: %w", err)
+	if err := p.writeTemp(tempPath, newContent, mode, info); err != nil {
+		return false, errors.Join(err, p.removeTemp(tempPath))
+	}
+
+	// Ask for confirmation if needed
+	if accepted, err := p.ask(ctx, filename, tempPath, changeInfo); err != nil || !accepted {
+		return false, errors.Join(err, p.removeTemp(tempPath))
+	}
+
+	// From here on, an interrupted run is completed by the next one
+	entry.Accepted = true
+	if err := p.journalSet(entry); err != nil {
+		return false, errors.Join(err, p.removeTemp(tempPath))
+	}
+
+	// Apply changes by moving temp file to target, replacing it atomically
+	if err := p.fs.Rename(tempPath, filename); err != nil {
+		return false, errors.Join(fmt.Errorf("failed to apply changes: %w", err), p.removeTemp(tempPath))
+	}
+	if err := p.sync(dir); err != nil {
+		return true, fmt.Errorf("failed to sync directory %s: %w", dir, err)
+	}
+	if err := p.recordHistory(filename, oldContent, newContent, fileExists); err != nil {
+		return true, err
 	}
 
-	// Prepare change info
+	return true, p.journalDone(tempPath)
+}
+
+// newChangeInfo describes a change for the ConflictFunc
+func newChangeInfo(filename string, oldContent, newContent []byte, fileExists bool, typeErrors []TypeError) ChangeInfo {
 	action := "modify"
 	if !fileExists {
 		action = "create"
 	}
 
-	changeInfo := ChangeInfo{
+	// Files marked as generated are owned by gogo. An existing file keeps the
+	// ownership it had before this change.
+	owned := isGenerated(newContent)
+	if fileExists {
+		owned = isGenerated(oldContent)
+	}
+
+	return ChangeInfo{
 		Action:     action,
 		FileName:   filename,
 		OldContent: oldContent,
 		NewContent: newContent,
-		Diff:       generateDiff(oldContent, newContent, filename),
+		Diff:       generateDiff(normalizeText(oldContent), normalizeText(newContent), filename),
+		Owned:      owned,
+		TypeErrors: typeErrors,
 	}
+}
 
-	// Ask for confirmation if needed
-	if p.conflictFunc != nil {
-		if !p.conflictFunc(p.fs, filename, tempPath, changeInfo) {
-			// User rejected changes
-			p.fs.Remove(tempPath)
-			return nil
-		}
+// ask asks the ConflictFunc whether to apply a change, one file at a time.
+// newPath is the temp file with the new content, empty if there is none.
+func (p *Project) ask(ctx context.Context, filename, newPath string, info ChangeInfo) (bool, error) {
+	if p.conflictFunc == nil {
+		return true, nil
 	}
 
-	// Apply changes by moving temp file to target
-	if fileExists {
-		// Backup existing file temporarily
-		backupPath := filename + ".backup"
-		if err := p.fs.Rename(filename, backupPath); err != nil {
-			p.fs.Remove(tempPath)
-			return fmt.Errorf("failed to backup existing file: %w", err)
-		}
+	p.conflictMu.Lock()
+	accepted := p.conflictFunc(ctx, p.fs, filename, newPath, info)
+	p.conflictMu.Unlock()
+
+	// An answer after the cancellation (e.g. Ctrl-C while asking) doesn't
+	// count
+	if err := ctx.Err(); err != nil {
+		return false, fmt.Errorf("%s not written: %w", filename, err)
+	}
+	return accepted, nil
+}
+
+// writeTemp writes the content of a temp file with the given permissions and
+// the owner of the file it replaces, if any, and syncs it to stable storage
+func (p *Project) writeTemp(tempPath string, content []byte, mode os.FileMode, replaced os.FileInfo) error {
+	if err := p.fs.WriteFile(tempPath, content, mode); err != nil {
+		return fmt.Errorf("failed to write temp file: %w", err)
+	}
 
-		// Move temp file to target
-		if err := p.fs.Rename(tempPath, filename); err != nil {
-			// Restore backup
-			p.fs.Rename(backupPath, filename)
-			p.fs.Remove(tempPath)
-			return fmt.Errorf("failed to apply changes: %w", err)
+	// Temp files are created private; set the permissions explicitly
+	if chmoder, ok := p.fs.(fs.ChmodFS); ok {
+		if err := chmoder.Chmod(tempPath, mode); err != nil {
+			return fmt.Errorf("failed to set permissions of temp file: %w", err)
 		}
+	}
 
-		// Remove backup
-		p.fs.Remove(backupPath)
-	} else {
-		// Just move temp file to target
-		if err := p.fs.Rename(tempPath, filename); err != nil {
-			p.fs.Remove(tempPath)
-			return fmt.Errorf("failed to create file: %w", err)
+	// Keeping the owner is best effort: only privileged processes can give
+	// files to other users
+	if chowner, ok := p.fs.(fs.ChownFS); ok && replaced != nil {
+		if uid, gid, ok := fileOwner(replaced); ok {
+			if err := chowner.Chown(tempPath, uid, gid); err != nil && !errors.Is(err, os.ErrPermission) {
+				return fmt.Errorf("failed to set owner of temp file: %w", err)
+			}
 		}
 	}
 
+	if err := p.sync(tempPath); err != nil {
+		return fmt.Errorf("failed to sync temp file: %w", err)
+	}
 	return nil
 }
+
+// removeTemp removes a temp file that won't be renamed
+func (p *Project) removeTemp(tempPath string) error {
+	if err := p.fs.Remove(tempPath); err != nil {
+		return fmt.Errorf("failed to remove temp file: %w", err)
+	}
+	return p.journalDone(tempPath)
+}
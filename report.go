@@ -0,0 +1,247 @@
+package gogo
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Report summarizes what a project did to every file it touched, see
+// Project.Report
+type Report struct {
+	Files []FileReport `json:"files"`
+}
+
+// FileReport is the summary of a single file in a Report
+type FileReport struct {
+	File         string     `json:"file"`
+	Status       FileStatus `json:"status"`
+	Declarations []string   `json:"declarations,omitempty"` // Declarations changed, e.g. "struct User"
+	Added        int        `json:"added"`                  // Lines added by the changes written
+	Removed      int        `json:"removed"`                // Lines removed by the changes written
+	Error        string     `json:"error,omitempty"`        // Error, for StatusFailed
+}
+
+// Report returns the summary of the files touched by the project since it
+// was created, in file name order
+func (p *Project) Report() Report {
+	p.resultsMu.Lock()
+	defer p.resultsMu.Unlock()
+
+	report := Report{Files: make([]FileReport, 0, len(p.results))}
+	for _, result := range p.results {
+		file := FileReport{
+			File:         result.File,
+			Status:       result.Status,
+			Declarations: append([]string(nil), result.decls...),
+			Added:        result.added,
+			Removed:      result.removed,
+		}
+		if result.Err != nil {
+			file.Error = result.Err.Error()
+		}
+		report.Files = append(report.Files, file)
+	}
+	sort.Slice(report.Files, func(i, j int) bool { return report.Files[i].File < report.Files[j].File })
+	return report
+}
+
+// count returns the number of files with the given status
+func (r Report) count(status FileStatus) int {
+	count := 0
+	for _, file := range r.Files {
+		if file.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// WriteText writes the report as a table for humans, one file per line and a
+// summary at the end
+func (r Report) WriteText(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, file := range r.Files {
+		detail := strings.Join(file.Declarations, ", ")
+		if file.Error != "" {
+			detail = file.Error
+		}
+		fmt.Fprintf(table, "%s\t%s\t+%d -%d\t%s\n", file.Status, file.File, file.Added, file.Removed, detail)
+	}
+	if err := table.Flush(); err != nil {
+		return err
+	}
+
+	var summary []string
+	for _, status := range []FileStatus{StatusCreated, StatusModified, StatusUnchanged, StatusPending, StatusRejected, StatusFailed} {
+		if count := r.count(status); count > 0 {
+			summary = append(summary, fmt.Sprintf("%d %s", count, status))
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d files: %s\n", len(r.Files), strings.Join(summary, ", "))
+	return err
+}
+
+// WriteJSON writes the report as indented JSON
+func (r Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// junitSuite is the JUnit XML test suite of a report: a test case per file,
+// failed files fail and rejected files are skipped
+type junitSuite struct {
+	XMLName  xml.Name    `xml:"testsuite"`
+	Name     string      `xml:"name,attr"`
+	Tests    int         `xml:"tests,attr"`
+	Failures int         `xml:"failures,attr"`
+	Skipped  int         `xml:"skipped,attr"`
+	Cases    []junitCase `xml:"testcase"`
+}
+
+type junitCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+}
+
+// WriteJUnit writes the report as a JUnit XML test suite, so CI systems can
+// publish it
+func (r Report) WriteJUnit(w io.Writer) error {
+	suite := junitSuite{Name: "gogo", Tests: len(r.Files)}
+	for _, file := range r.Files {
+		testCase := junitCase{
+			Name:      file.File,
+			ClassName: "gogo",
+			SystemOut: fmt.Sprintf("%s +%d -%d %s", file.Status, file.Added, file.Removed, strings.Join(file.Declarations, ", ")),
+		}
+		switch file.Status {
+		case StatusFailed:
+			testCase.Failure = &junitMessage{Message: file.Error}
+			suite.Failures++
+		case StatusRejected:
+			testCase.Skipped = &junitMessage{Message: ErrRejected.Error()}
+			suite.Skipped++
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suite); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// MarshalText implements encoding.TextMarshaler, so statuses are written by
+// name
+func (s FileStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler
+func (s *FileStatus) UnmarshalText(text []byte) error {
+	for status := StatusUnchanged; status <= StatusFailed; status++ {
+		if status.String() == string(text) {
+			*s = status
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown file status %q", text)
+}
+
+// touched records a declaration changed or failed in a file
+func (p *Project) touched(e Event) {
+	if e.File == "" {
+		return
+	}
+	decl := e.Kind
+	if e.Name != "" {
+		decl += " " + e.Name
+	}
+
+	p.resultsMu.Lock()
+	defer p.resultsMu.Unlock()
+
+	result := p.result(e.File)
+	for _, existing := range result.decls {
+		if existing == decl {
+			return
+		}
+	}
+	result.decls = append(result.decls, decl)
+}
+
+// counted records the lines added and removed by a write
+func (p *Project) counted(filename string, oldContent, newContent []byte) {
+	added, removed := countLines(oldContent, newContent)
+
+	p.resultsMu.Lock()
+	defer p.resultsMu.Unlock()
+
+	result := p.result(filename)
+	result.added += added
+	result.removed += removed
+}
+
+// countLines returns the number of lines added and removed between two
+// versions of a file
+func countLines(oldContent, newContent []byte) (added, removed int) {
+	split := func(content []byte) []string {
+		lines := strings.SplitAfter(string(content), "\n")
+		if lines[len(lines)-1] == "" {
+			lines = lines[:len(lines)-1]
+		}
+		return lines
+	}
+	a, b := split(oldContent), split(newContent)
+
+	// Every line not in the longest common subsequence is added or removed
+	d := editDistance(a, b)
+	return (d + len(b) - len(a)) / 2, (d - len(b) + len(a)) / 2
+}
+
+// editDistance returns the number of lines to insert and delete to turn a
+// into b, with the greedy algorithm of Myers' "An O(ND) Difference Algorithm"
+func editDistance(a, b []string) int {
+	n, m := len(a), len(b)
+	limit := n + m
+	v := make([]int, 2*limit+3)
+	offset := limit + 1
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return d
+			}
+		}
+	}
+	return limit
+}
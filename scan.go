@@ -0,0 +1,78 @@
+package gogo
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/guillermo/gogo/fs"
+)
+
+// DefaultExclude are the paths a project skips when it scans the tree, used
+// when Options.Exclude is nil
+var DefaultExclude = []string{"vendor/", "testdata/", ".git/"}
+
+// Files returns the Go files of the project in lexical order, leaving out
+// the paths matching Options.Exclude
+func (p *Project) Files() ([]string, error) {
+	var files []string
+	err := fs.WalkDir(p.fs, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("failed to scan %s: %w", name, err)
+		}
+		if name != "." && p.excluded(name, entry.IsDir()) {
+			if entry.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if !entry.IsDir() && strings.HasSuffix(name, ".go") {
+			files = append(files, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+// excluded reports whether a path matches any of the exclusion patterns of
+// the project. Patterns ending in "/" match directories with that name at
+// any depth, like "vendor/". Other patterns match the whole path or, without
+// a "/", the base name, like "*_gen.go".
+func (p *Project) excluded(name string, isDir bool) bool {
+	patterns := p.opts.Exclude
+	if patterns == nil {
+		patterns = DefaultExclude
+	}
+
+	base := path.Base(name)
+	for _, pattern := range patterns {
+		if dirPattern, ok := strings.CutSuffix(pattern, "/"); ok {
+			if !isDir {
+				continue
+			}
+			if strings.Contains(dirPattern, "/") {
+				if match, _ := path.Match(dirPattern, name); match {
+					return true
+				}
+				continue
+			}
+			if match, _ := path.Match(dirPattern, base); match {
+				return true
+			}
+			continue
+		}
+
+		if match, _ := path.Match(pattern, name); match {
+			return true
+		}
+		if !strings.Contains(pattern, "/") {
+			if match, _ := path.Match(pattern, base); match {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,342 @@
+package gogo
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	iofs "io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// SpecVersion is the version of the spec format understood by ApplySpec
+const SpecVersion = 1
+
+// SpecSchema is the JSON Schema of the spec format. Specs are validated
+// against it before they are applied.
+//
+//go:embed spec.schema.json
+var SpecSchema string
+
+// Spec describes the declarations a project should contain, as data. It is
+// the Go form of the JSON documents read by ApplySpec, see SpecSchema.
+type Spec struct {
+	Version int        `json:"version"`
+	Include []string   `json:"include,omitempty"` // Other spec files, relative to this one, applied before it
+	Files   []SpecFile `json:"files,omitempty"`
+}
+
+// SpecFile is the spec of a single file, applied with Project.File
+type SpecFile struct {
+	Path      string         `json:"path"`
+	Package   string         `json:"package,omitempty"` // See FileSpec.Package
+	Imports   []string       `json:"imports,omitempty"`
+	Structs   []SpecStruct   `json:"structs,omitempty"`
+	Methods   []SpecMethod   `json:"methods,omitempty"`
+	Functions []SpecFunction `json:"functions,omitempty"`
+	Types     []SpecType     `json:"types,omitempty"`
+	Vars      []SpecValue    `json:"vars,omitempty"`
+	Consts    []SpecValue    `json:"consts,omitempty"`
+	Enums     []SpecEnum     `json:"enums,omitempty"`
+	Prune     bool           `json:"prune,omitempty"` // See FileSpec.Prune
+}
+
+// SpecStruct is a struct and its fields
+type SpecStruct struct {
+	Name     string      `json:"name"`
+	Fields   []SpecField `json:"fields,omitempty"`
+	Preserve bool        `json:"preserve,omitempty"` // Keep existing fields absent from the spec
+}
+
+// SpecField is a field of a struct
+type SpecField struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Tag  string `json:"tag,omitempty"` // Without backquotes, e.g. json:"id"
+}
+
+// SpecParam is a parameter or a receiver
+type SpecParam struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// SpecMethod is a method
+type SpecMethod struct {
+	Name     string      `json:"name"`
+	Receiver SpecParam   `json:"receiver"`
+	Params   []SpecParam `json:"params,omitempty"`
+	Returns  string      `json:"returns,omitempty"`
+	Body     string      `json:"body,omitempty"`
+}
+
+// SpecFunction is a function
+type SpecFunction struct {
+	Name    string      `json:"name"`
+	Params  []SpecParam `json:"params,omitempty"`
+	Returns string      `json:"returns,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+// SpecType is a type definition
+type SpecType struct {
+	Name       string `json:"name"`
+	Definition string `json:"definition"`
+}
+
+// SpecValue is a variable or a constant
+type SpecValue struct {
+	Name  string `json:"name"`
+	Type  string `json:"type,omitempty"`
+	Value string `json:"value"` // Go expression
+}
+
+// SpecEnum is a named type with a constant per value. String enums (the
+// default) get the value itself, integer enums its position.
+type SpecEnum struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type,omitempty"` // Underlying type, "string" if empty
+	Values []string `json:"values"`
+}
+
+// SpecError is a problem in a spec, located by a JSON pointer (RFC 6901)
+type SpecError struct {
+	File    string // Spec file, empty for the document given to ApplySpec
+	Pointer string // Location of the problem, e.g. "/files/0/structs/1/name"
+	Message string
+}
+
+func (e *SpecError) Error() string {
+	source := e.File
+	if source == "" {
+		source = "spec"
+	}
+	return fmt.Sprintf("%s#%s: %s", source, e.Pointer, e.Message)
+}
+
+// ApplySpec reconciles the project with the JSON spec read from r. Included
+// specs are read from the filesystem of the project, relative to its root.
+// An invalid spec changes nothing: it returns an error wrapping a SpecError
+// for every problem against the schema, or ErrInvalidOptions for the
+// declarations Project.File would reject, like a struct without fields.
+// Errors writing a file, like a failed type check, don't stop the others.
+func ApplySpec(p *Project, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("failed to read spec: %w", err)
+	}
+	loader := newSpecLoader(p.fs.ReadFile)
+	if err := loader.load("", data); err != nil {
+		return err
+	}
+	return loader.apply(p)
+}
+
+// ApplySpecFS is ApplySpec for the spec file name in fsys. Included specs are
+// read from fsys too, relative to the spec that includes them, and can't be
+// outside of fsys.
+func ApplySpecFS(p *Project, fsys iofs.FS, name string) error {
+	loader := newSpecLoader(func(name string) ([]byte, error) { return iofs.ReadFile(fsys, name) })
+	data, err := loader.read(name)
+	if err != nil {
+		return fmt.Errorf("failed to read spec: %w", err)
+	}
+	if err := loader.load(name, data); err != nil {
+		return err
+	}
+	return loader.apply(p)
+}
+
+// specLoader reads a spec and its includes into a single list of files
+type specLoader struct {
+	read    func(name string) ([]byte, error)
+	loading map[string]bool // Specs being loaded, to detect include cycles
+	loaded  map[string]bool // Specs already loaded, included only once
+	files   []SpecFile      // Files of every spec, merged by path
+}
+
+// newSpecLoader creates a loader that reads includes with read
+func newSpecLoader(read func(name string) ([]byte, error)) *specLoader {
+	return &specLoader{read: read, loading: make(map[string]bool), loaded: make(map[string]bool)}
+}
+
+// load validates a spec, loads its includes and then adds its files
+func (l *specLoader) load(name string, data []byte) error {
+	var document any
+	if err := json.Unmarshal(data, &document); err != nil {
+		return fmt.Errorf("invalid spec: %w", &SpecError{File: name, Message: err.Error()})
+	}
+	if errs := validateSchema(document, name); len(errs) > 0 {
+		return fmt.Errorf("invalid spec: %w", errors.Join(errs...))
+	}
+	var spec Spec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return fmt.Errorf("invalid spec: %w", &SpecError{File: name, Message: err.Error()})
+	}
+
+	l.loading[name] = true
+	defer delete(l.loading, name)
+	for i, include := range spec.Include {
+		included := path.Join(path.Dir(name), include)
+		if included == ".." || strings.HasPrefix(included, "../") {
+			return fmt.Errorf("invalid spec: %w", &SpecError{File: name, Pointer: fmt.Sprintf("/include/%d", i), Message: include + " is outside of the root"})
+		}
+		if l.loading[included] {
+			return fmt.Errorf("invalid spec: %w", &SpecError{File: name, Pointer: fmt.Sprintf("/include/%d", i), Message: "include cycle through " + included})
+		}
+		if l.loaded[included] {
+			continue
+		}
+		data, err := l.read(included)
+		if err != nil {
+			return fmt.Errorf("invalid spec: %w", &SpecError{File: name, Pointer: fmt.Sprintf("/include/%d", i), Message: err.Error()})
+		}
+		if err := l.load(included, data); err != nil {
+			return err
+		}
+	}
+	l.loaded[name] = true
+
+	for _, file := range spec.Files {
+		l.add(file)
+	}
+	return nil
+}
+
+// add adds the spec of a file, merging it with an earlier spec of the same
+// file
+func (l *specLoader) add(file SpecFile) {
+	file.Path = path.Clean(file.Path)
+	for i := range l.files {
+		existing := &l.files[i]
+		if existing.Path != file.Path {
+			continue
+		}
+		if existing.Package == "" {
+			existing.Package = file.Package
+		}
+		existing.Imports = append(existing.Imports, file.Imports...)
+		existing.Structs = append(existing.Structs, file.Structs...)
+		existing.Methods = append(existing.Methods, file.Methods...)
+		existing.Functions = append(existing.Functions, file.Functions...)
+		existing.Types = append(existing.Types, file.Types...)
+		existing.Vars = append(existing.Vars, file.Vars...)
+		existing.Consts = append(existing.Consts, file.Consts...)
+		existing.Enums = append(existing.Enums, file.Enums...)
+		existing.Prune = existing.Prune || file.Prune
+		return
+	}
+	l.files = append(l.files, file)
+}
+
+// apply reconciles the project with every file, in the order of the specs.
+// Every file is validated before any is written. Once writing, an error in
+// one file does not stop the others.
+func (l *specLoader) apply(p *Project) error {
+	specs := make([]FileSpec, 0, len(l.files))
+	var errs []error
+	for _, file := range l.files {
+		spec := file.fileSpec()
+		if _, err := spec.decls(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid spec: %s: %w", spec.Path, err))
+		}
+		specs = append(specs, spec)
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	for _, spec := range specs {
+		if err := p.File(spec); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fileSpec converts the spec of a file to the options of Project.File
+func (f SpecFile) fileSpec() FileSpec {
+	spec := FileSpec{Path: f.Path, Package: f.Package, Imports: f.Imports, Prune: f.Prune}
+	for _, s := range f.Structs {
+		opts := StructOpts{Name: s.Name, PreserveExisting: s.Preserve}
+		for _, field := range s.Fields {
+			opts.Fields = append(opts.Fields, StructField{Name: field.Name, Type: field.Type, Annotation: field.Tag})
+		}
+		spec.Structs = append(spec.Structs, opts)
+	}
+	for _, m := range f.Methods {
+		spec.Methods = append(spec.Methods, MethodOpts{
+			Name:         m.Name,
+			ReceiverName: m.Receiver.Name,
+			ReceiverType: m.Receiver.Type,
+			Parameters:   specParams(m.Params),
+			ReturnType:   m.Returns,
+			Body:         m.Body,
+		})
+	}
+	for _, fn := range f.Functions {
+		spec.Functions = append(spec.Functions, FunctionOpts{
+			Name:       fn.Name,
+			Parameters: specParams(fn.Params),
+			ReturnType: fn.Returns,
+			Body:       fn.Body,
+		})
+	}
+	for _, t := range f.Types {
+		spec.Types = append(spec.Types, TypeDef{Name: t.Name, Definition: t.Definition})
+	}
+	for _, v := range f.Vars {
+		spec.Vars = append(spec.Vars, Variable{Name: v.Name, Type: v.Type, Value: v.Value})
+	}
+	for _, c := range f.Consts {
+		spec.Consts = append(spec.Consts, Constant{Name: c.Name, Type: c.Type, Value: c.Value})
+	}
+	for _, e := range f.Enums {
+		typeDef, consts := e.decls()
+		spec.Types = append(spec.Types, typeDef)
+		spec.Consts = append(spec.Consts, consts...)
+	}
+	return spec
+}
+
+// specParams converts spec parameters
+func specParams(params []SpecParam) []Parameter {
+	var converted []Parameter
+	for _, param := range params {
+		converted = append(converted, Parameter{Name: param.Name, Type: param.Type})
+	}
+	return converted
+}
+
+// decls returns the type of an enum and its constants, named after the type
+// and the value: Status and "in_progress" make StatusInProgress
+func (e SpecEnum) decls() (TypeDef, []Constant) {
+	underlying := e.Type
+	if underlying == "" {
+		underlying = "string"
+	}
+
+	consts := make([]Constant, 0, len(e.Values))
+	for i, value := range e.Values {
+		constant := Constant{Name: e.Name + exportedName(value), Type: e.Name, Value: strconv.Itoa(i)}
+		if underlying == "string" {
+			constant.Value = strconv.Quote(value)
+		}
+		consts = append(consts, constant)
+	}
+	return TypeDef{Name: e.Name, Definition: underlying}, consts
+}
+
+// exportedName converts a snake_case value to CamelCase
+func exportedName(value string) string {
+	var name strings.Builder
+	for _, part := range strings.Split(value, "_") {
+		if part != "" {
+			name.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return name.String()
+}
@@ -0,0 +1,161 @@
+package gogo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// compiledSchema is SpecSchema decoded, with its patterns compiled
+type compiledSchema struct {
+	root     map[string]any
+	patterns map[string]*regexp.Regexp // By source
+}
+
+// specSchema is SpecSchema compiled, see validateSchema
+var specSchema = sync.OnceValue(func() *compiledSchema {
+	var root map[string]any
+	if err := json.Unmarshal([]byte(SpecSchema), &root); err != nil {
+		panic(fmt.Sprintf("gogo: invalid spec schema: %v", err))
+	}
+	schema := &compiledSchema{root: root, patterns: make(map[string]*regexp.Regexp)}
+	schema.compile(root)
+	return schema
+})
+
+// compile compiles the patterns of a schema and its subschemas
+func (s *compiledSchema) compile(value any) {
+	switch value := value.(type) {
+	case map[string]any:
+		if pattern, ok := value["pattern"].(string); ok {
+			s.patterns[pattern] = regexp.MustCompile(pattern)
+		}
+		for _, child := range value {
+			s.compile(child)
+		}
+	case []any:
+		for _, child := range value {
+			s.compile(child)
+		}
+	}
+}
+
+// validateSchema checks a decoded JSON document against SpecSchema and
+// returns a SpecError for every problem. It understands the keywords the
+// schema uses: $ref to $defs, type, const, required, properties,
+// additionalProperties, items, minItems, minLength and pattern.
+func validateSchema(document any, file string) []error {
+	v := schemaValidator{schema: specSchema(), file: file}
+	v.validate(v.schema.root, document, "")
+	return v.errs
+}
+
+// schemaValidator collects the problems of a document
+type schemaValidator struct {
+	schema *compiledSchema
+	file   string
+	errs   []error
+}
+
+// fail records a problem at pointer
+func (v *schemaValidator) fail(pointer, format string, args ...any) {
+	v.errs = append(v.errs, &SpecError{File: v.file, Pointer: pointer, Message: fmt.Sprintf(format, args...)})
+}
+
+// validate checks value, found at pointer, against schema
+func (v *schemaValidator) validate(schema map[string]any, value any, pointer string) {
+	if ref, ok := schema["$ref"].(string); ok {
+		name := strings.TrimPrefix(ref, "#/$defs/")
+		schema = v.schema.root["$defs"].(map[string]any)[name].(map[string]any)
+	}
+
+	if want, ok := schema["const"]; ok && value != want {
+		v.fail(pointer, "must be %v", want)
+		return
+	}
+	if want, ok := schema["type"].(string); ok && !schemaType(value, want) {
+		v.fail(pointer, "must be %s %s", article(want), want)
+		return
+	}
+
+	switch value := value.(type) {
+	case string:
+		if min, ok := schema["minLength"].(float64); ok && utf8.RuneCountInString(value) < int(min) {
+			v.fail(pointer, "must not be empty")
+		}
+		if pattern, ok := schema["pattern"].(string); ok && !v.schema.patterns[pattern].MatchString(value) {
+			v.fail(pointer, "%q does not match %s", value, pattern)
+		}
+
+	case []any:
+		if min, ok := schema["minItems"].(float64); ok && len(value) < int(min) {
+			v.fail(pointer, "must have at least %d items", int(min))
+		}
+		if items, ok := schema["items"].(map[string]any); ok {
+			for i, item := range value {
+				v.validate(items, item, fmt.Sprintf("%s/%d", pointer, i))
+			}
+		}
+
+	case map[string]any:
+		properties, _ := schema["properties"].(map[string]any)
+		required, _ := schema["required"].([]any)
+		for _, name := range required {
+			if _, ok := value[name.(string)]; !ok {
+				v.fail(pointer+"/"+escapePointer(name.(string)), "is required")
+			}
+		}
+
+		// Keys in order, so the errors are too
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			property, ok := properties[key].(map[string]any)
+			if !ok {
+				if schema["additionalProperties"] == false {
+					v.fail(pointer+"/"+escapePointer(key), "unknown property")
+				}
+				continue
+			}
+			v.validate(property, value[key], pointer+"/"+escapePointer(key))
+		}
+	}
+}
+
+// schemaType reports whether a decoded JSON value has a JSON Schema type
+func schemaType(value any, want string) bool {
+	switch value := value.(type) {
+	case string:
+		return want == "string"
+	case bool:
+		return want == "boolean"
+	case float64:
+		return want == "number" || want == "integer" && value == math.Trunc(value)
+	case []any:
+		return want == "array"
+	case map[string]any:
+		return want == "object"
+	}
+	return want == "null"
+}
+
+// article returns the indefinite article of a type name
+func article(name string) string {
+	if strings.ContainsAny(name[:1], "aeiou") {
+		return "an"
+	}
+	return "a"
+}
+
+// escapePointer escapes a key for a JSON pointer
+func escapePointer(key string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(key)
+}
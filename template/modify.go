@@ -13,7 +13,7 @@ func (t *Template) AddStructField(structName string, field gogo.StructField) (*T
 	// Check if the struct exists
 	genDecl, typeSpec := t.findStructGenDecl(structName)
 	if genDecl == nil || typeSpec == nil {
-		return nil, fmt.Errorf("struct %s not found", structName)
+		return nil, fmt.Errorf("struct %s: %w", structName, gogo.ErrNotFound)
 	}
 
 	structType, ok := typeSpec.Type.(*ast.StructType)
@@ -74,7 +74,7 @@ func (t *Template) RemoveStructField(structName string, field gogo.StructField)
 	// Check if the struct exists
 	genDecl, typeSpec := t.findStructGenDecl(structName)
 	if genDecl == nil || typeSpec == nil {
-		return nil, fmt.Errorf("struct %s not found", structName)
+		return nil, fmt.Errorf("struct %s: %w", structName, gogo.ErrNotFound)
 	}
 
 	structType, ok := typeSpec.Type.(*ast.StructType)
@@ -97,7 +97,7 @@ func (t *Template) RemoveStructField(structName string, field gogo.StructField)
 	}
 
 	if !fieldFound {
-		return nil, fmt.Errorf("field %s not found in struct %s", field.Name, structName)
+		return nil, fmt.Errorf("field %s of struct %s: %w", field.Name, structName, gogo.ErrNotFound)
 	}
 
 	// Create a new template with the changes
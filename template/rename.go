@@ -12,7 +12,7 @@ import (
 func (t *Template) RenameStruct(oldName, newName string) (*Template, error) {
 	// Check if the struct exists
 	if t.findStruct(oldName) == nil {
-		return nil, fmt.Errorf("struct %s not found", oldName)
+		return nil, fmt.Errorf("struct %s: %w", oldName, gogo.ErrNotFound)
 	}
 
 	// Create a new template with the changes
@@ -30,7 +30,7 @@ func (t *Template) RenameStructField(structName, oldFieldName string, newField g
 	// Check if the struct exists
 	structType := t.findStruct(structName)
 	if structType == nil {
-		return nil, fmt.Errorf("struct %s not found", structName)
+		return nil, fmt.Errorf("struct %s: %w", structName, gogo.ErrNotFound)
 	}
 
 	// Check if the old field exists in the struct
@@ -48,7 +48,7 @@ func (t *Template) RenameStructField(structName, oldFieldName string, newField g
 	}
 
 	if !fieldFound {
-		return nil, fmt.Errorf("field %s not found in struct %s", oldFieldName, structName)
+		return nil, fmt.Errorf("field %s of struct %s: %w", oldFieldName, structName, gogo.ErrNotFound)
 	}
 
 	// Create a new template with the changes
@@ -93,7 +93,7 @@ func (t *Template) RenameStructField(structName, oldFieldName string, newField g
 func (t *Template) RenameVariable(oldName, newName string) (*Template, error) {
 	// Check if the variable exists
 	if t.findVariable(oldName) == nil {
-		return nil, fmt.Errorf("variable %s not found", oldName)
+		return nil, fmt.Errorf("variable %s: %w", oldName, gogo.ErrNotFound)
 	}
 
 	// Create a new template with the changes
@@ -109,7 +109,7 @@ func (t *Template) RenameVariable(oldName, newName string) (*Template, error) {
 func (t *Template) RenameFunction(oldName, newName string) (*Template, error) {
 	// Check if the function exists
 	if t.findFunction(oldName) == nil {
-		return nil, fmt.Errorf("function %s not found", oldName)
+		return nil, fmt.Errorf("function %s: %w", oldName, gogo.ErrNotFound)
 	}
 
 	// Create a new template with the changes
@@ -125,7 +125,7 @@ func (t *Template) RenameFunction(oldName, newName string) (*Template, error) {
 func (t *Template) RenameType(oldName, newName string) (*Template, error) {
 	// Check if the type exists
 	if t.findType(oldName) == nil {
-		return nil, fmt.Errorf("type %s not found", oldName)
+		return nil, fmt.Errorf("type %s: %w", oldName, gogo.ErrNotFound)
 	}
 
 	// Create a new template with the changes
@@ -141,7 +141,7 @@ func (t *Template) RenameType(oldName, newName string) (*Template, error) {
 func (t *Template) RenameConstant(oldName, newName string) (*Template, error) {
 	// Check if the constant exists
 	if t.findConstant(oldName) == nil {
-		return nil, fmt.Errorf("constant %s not found", oldName)
+		return nil, fmt.Errorf("constant %s: %w", oldName, gogo.ErrNotFound)
 	}
 
 	// Create a new template with the changes
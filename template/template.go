@@ -93,7 +93,7 @@ func New(filesystem fs.ReadFileFS) (*Template, error) {
 func (t *Template) ExtractStruct(name string) (gogo.StructOpts, error) {
 	structNode := t.findStruct(name)
 	if structNode == nil {
-		return gogo.StructOpts{}, fmt.Errorf("struct %s not found", name)
+		return gogo.StructOpts{}, fmt.Errorf("struct %s: %w", name, gogo.ErrNotFound)
 	}
 
 	// Convert AST struct to gogo.StructOpts
@@ -125,7 +125,7 @@ func (t *Template) ExtractStruct(name string) (gogo.StructOpts, error) {
 func (t *Template) ExtractFunction(name string) (gogo.FunctionOpts, error) {
 	funcNode := t.findFunction(name)
 	if funcNode == nil {
-		return gogo.FunctionOpts{}, fmt.Errorf("function %s not found", name)
+		return gogo.FunctionOpts{}, fmt.Errorf("function %s: %w", name, gogo.ErrNotFound)
 	}
 
 	// Convert AST function to gogo.FunctionOpts
@@ -163,7 +163,7 @@ func (t *Template) ExtractFunction(name string) (gogo.FunctionOpts, error) {
 func (t *Template) ExtractMethod(receiverType, methodName string) (gogo.MethodOpts, error) {
 	methodNode := t.findMethod(receiverType, methodName)
 	if methodNode == nil {
-		return gogo.MethodOpts{}, fmt.Errorf("method %s.%s not found", receiverType, methodName)
+		return gogo.MethodOpts{}, fmt.Errorf("method %s.%s: %w", receiverType, methodName, gogo.ErrNotFound)
 	}
 
 	// Extract receiver info
@@ -212,7 +212,7 @@ func (t *Template) ExtractMethod(receiverType, methodName string) (gogo.MethodOp
 func (t *Template) ExtractVariable(name string) (gogo.VariableOpts, error) {
 	varNode := t.findVariable(name)
 	if varNode == nil {
-		return gogo.VariableOpts{}, fmt.Errorf("variable %s not found", name)
+		return gogo.VariableOpts{}, fmt.Errorf("variable %s: %w", name, gogo.ErrNotFound)
 	}
 
 	variables := make([]gogo.Variable, 0)
@@ -253,7 +253,7 @@ func (t *Template) ExtractVariable(name string) (gogo.VariableOpts, error) {
 func (t *Template) ExtractConstant(name string) (gogo.ConstantOpts, error) {
 	constNode := t.findConstant(name)
 	if constNode == nil {
-		return gogo.ConstantOpts{}, fmt.Errorf("constant %s not found", name)
+		return gogo.ConstantOpts{}, fmt.Errorf("constant %s: %w", name, gogo.ErrNotFound)
 	}
 
 	constants := make([]gogo.Constant, 0)
@@ -294,7 +294,7 @@ func (t *Template) ExtractConstant(name string) (gogo.ConstantOpts, error) {
 func (t *Template) ExtractType(name string) (gogo.TypeOpts, error) {
 	typeNode := t.findType(name)
 	if typeNode == nil {
-		return gogo.TypeOpts{}, fmt.Errorf("type %s not found", name)
+		return gogo.TypeOpts{}, fmt.Errorf("type %s: %w", name, gogo.ErrNotFound)
 	}
 
 	types := make([]gogo.TypeDef, 0)
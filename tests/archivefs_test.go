@@ -0,0 +1,124 @@
+package tests
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guillermo/gogo"
+	"github.com/guillermo/gogo/fs/archivefs"
+)
+
+// scaffold generates a small project into an archive filesystem
+func scaffold(t *testing.T, fsys *archivefs.FS) {
+	t.Helper()
+
+	fsys.Prefix = "starter/"
+	fsys.ModTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := fsys.WriteFile("go.mod", []byte("module example.com/starter\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	project, err := gogo.New(gogo.Options{FS: fsys, InitialPackageName: "models", ConflictFunc: gogo.ConflictAccept, Buffered: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := project.Struct(gogo.StructOpts{Filename: "models/user.go", Name: "User", Fields: []gogo.StructField{{Name: "ID", Type: "int"}}}); err != nil {
+		t.Fatal(err)
+	}
+	// Buffered changes reach the archive once, in their last version
+	if err := project.Struct(gogo.StructOpts{Filename: "models/user.go", Name: "User", Fields: []gogo.StructField{{Name: "ID", Type: "int64"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := project.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Archived files can't be read back or replaced
+	if _, err := fsys.ReadFile("models/user.go"); !errors.Is(err, archivefs.ErrWriteOnly) {
+		t.Errorf("Expected ErrWriteOnly, got %v", err)
+	}
+	if err := fsys.WriteFile("models/user.go", nil, 0644); !errors.Is(err, os.ErrExist) {
+		t.Errorf("Expected os.ErrExist, got %v", err)
+	}
+
+	if err := fsys.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Close(); !errors.Is(err, archivefs.ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+	if err := fsys.WriteFile("late.go", nil, 0644); !errors.Is(err, archivefs.ErrClosed) {
+		t.Errorf("Expected ErrClosed, got %v", err)
+	}
+}
+
+func TestArchiveFSOptions(t *testing.T) {
+	for name, opts := range map[string]gogo.Options{
+		"History":    {Buffered: true, History: 5},
+		"Unbuffered": {},
+	} {
+		var buf bytes.Buffer
+		opts.FS = archivefs.NewZip(&buf)
+		if _, err := gogo.New(opts); !errors.Is(err, gogo.ErrInvalidOptions) {
+			t.Errorf("%s: expected ErrInvalidOptions, got %v", name, err)
+		}
+	}
+}
+
+func TestArchiveFS(t *testing.T) {
+	t.Run("Zip", func(t *testing.T) {
+		var buf bytes.Buffer
+		scaffold(t, archivefs.NewZip(&buf))
+
+		archive, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, file := range archive.File {
+			names = append(names, file.Name)
+			if file.Mode().Perm() != 0644 {
+				t.Errorf("Expected %s with mode 0644, got %v", file.Name, file.Mode())
+			}
+		}
+		if got := strings.Join(names, " "); got != "starter/go.mod starter/models/user.go" {
+			t.Fatalf("Unexpected files %s", got)
+		}
+
+		reader, _ := archive.File[1].Open()
+		content, _ := io.ReadAll(reader)
+		if !strings.Contains(string(content), "ID int64") {
+			t.Errorf("Expected the last version of user.go, got:\n%s", content)
+		}
+	})
+
+	t.Run("Tar", func(t *testing.T) {
+		var buf bytes.Buffer
+		scaffold(t, archivefs.NewTar(&buf))
+
+		archive := tar.NewReader(&buf)
+		var names []string
+		for {
+			header, err := archive.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, header.Name)
+			if !header.ModTime.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+				t.Errorf("Unexpected time %v for %s", header.ModTime, header.Name)
+			}
+		}
+		if got := strings.Join(names, " "); got != "starter/go.mod starter/models/user.go" {
+			t.Errorf("Unexpected files %s", got)
+		}
+	})
+}
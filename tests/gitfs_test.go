@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	"github.com/guillermo/gogo/fs/gitfs"
+)
+
+// gitRepo creates a repository with a committed user.go
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "user.go"), []byte("package models\n\ntype User struct {\n\tID int\n}\n"), 0644)
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "user.go"},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", "initial"},
+	} {
+		if out, err := exec.Command("git", append([]string{"-C", dir}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %s: %v\n%s", args[0], err, out)
+		}
+	}
+	return dir
+}
+
+// gitStatus returns the short status of the repository
+func gitStatus(t *testing.T, dir string) string {
+	t.Helper()
+	out, err := exec.Command("git", "-C", dir, "status", "--short", "--", "*.go").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func gitProject(t *testing.T, dir string, opts gitfs.Options) *gogo.Project {
+	t.Helper()
+	fsys, err := gitfs.Open(dir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := gogo.New(gogo.Options{FS: fsys, ConflictFunc: gogo.ConflictAccept})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return project
+}
+
+var gitUser = gogo.StructOpts{Filename: "user.go", Name: "User", Fields: []gogo.StructField{{Name: "ID", Type: "int64"}}}
+
+func TestGitFS(t *testing.T) {
+	t.Run("Clean", func(t *testing.T) {
+		dir := gitRepo(t)
+		project := gitProject(t, dir, gitfs.Options{})
+
+		if err := project.Struct(gitUser); err != nil {
+			t.Fatal(err)
+		}
+		// Files written by the project can be written again
+		if err := project.Struct(gogo.StructOpts{Filename: "user.go", Name: "Admin", Fields: []gogo.StructField{{Name: "ID", Type: "int64"}}}); err != nil {
+			t.Fatal(err)
+		}
+		if status := gitStatus(t, dir); status != "M user.go" {
+			t.Errorf("Expected user.go modified, got %q", status)
+		}
+	})
+
+	t.Run("Dirty", func(t *testing.T) {
+		dir := gitRepo(t)
+		local := "package models\n\n// Local work\ntype User struct {\n\tID int\n}\n"
+		os.WriteFile(filepath.Join(dir, "user.go"), []byte(local), 0644)
+
+		err := gitProject(t, dir, gitfs.Options{}).Struct(gitUser)
+		if !errors.Is(err, gitfs.ErrDirty) {
+			t.Fatalf("Expected ErrDirty, got %v", err)
+		}
+		if content, _ := os.ReadFile(filepath.Join(dir, "user.go")); string(content) != local {
+			t.Errorf("Dirty file modified:\n%s", content)
+		}
+		if entries, _ := os.ReadDir(dir); len(entries) != 2 {
+			t.Errorf("Expected only .git and user.go, got %v", entries)
+		}
+
+		if err := gitProject(t, dir, gitfs.Options{Force: true}).Struct(gitUser); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Untracked", func(t *testing.T) {
+		dir := gitRepo(t)
+		os.WriteFile(filepath.Join(dir, "ids.go"), []byte("package models\n"), 0644)
+
+		err := gitProject(t, dir, gitfs.Options{}).Type(gogo.TypeOpts{Filename: "ids.go", Types: []gogo.TypeDef{{Name: "ID", Definition: "int64"}}})
+		if !errors.Is(err, gitfs.ErrDirty) {
+			t.Errorf("Expected ErrDirty, got %v", err)
+		}
+	})
+
+	t.Run("DirtyPaths", func(t *testing.T) {
+		dir := gitRepo(t)
+		fsys, err := gitfs.Open(dir, gitfs.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Paths are not patterns
+		os.WriteFile(filepath.Join(dir, "user.go"), []byte("package models\n"), 0644)
+		if dirty, err := fsys.Dirty("*.go"); err != nil || dirty {
+			t.Errorf("Expected *.go to match no file, got %v, %v", dirty, err)
+		}
+
+		// A deleted file is local work too
+		os.Remove(filepath.Join(dir, "user.go"))
+		if dirty, err := fsys.Dirty("user.go"); err != nil || !dirty {
+			t.Errorf("Expected the deleted file to be dirty, got %v, %v", dirty, err)
+		}
+
+		// Only the files of projects are skipped, not every name starting
+		// with .gogo
+		os.WriteFile(filepath.Join(dir, ".gogo-models.go"), []byte("package models\n"), 0644)
+		if err := fsys.WriteFile(".gogo-models.go", []byte("package models\n\n// Generated\n"), 0644); !errors.Is(err, gitfs.ErrDirty) {
+			t.Errorf("Expected ErrDirty, got %v", err)
+		}
+		os.WriteFile(filepath.Join(dir, ".gogo-pending"), []byte("{}"), 0644)
+		if err := fsys.WriteFile(".gogo-pending", []byte("{}"), 0644); err != nil {
+			t.Errorf("Expected the journal to be writable, got %v", err)
+		}
+	})
+
+	t.Run("Add", func(t *testing.T) {
+		dir := gitRepo(t)
+		project := gitProject(t, dir, gitfs.Options{Add: true})
+
+		if err := project.Struct(gitUser); err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Type(gogo.TypeOpts{Filename: "ids.go", Types: []gogo.TypeDef{{Name: "ID", Definition: "int64"}}}); err != nil {
+			t.Fatal(err)
+		}
+		if status := gitStatus(t, dir); status != "A  ids.go\nM  user.go" {
+			t.Errorf("Expected the files staged, got %q", status)
+		}
+
+		// Staged changes are safe, so another run can overwrite them
+		if err := gitProject(t, dir, gitfs.Options{}).Struct(gogo.StructOpts{Filename: "user.go", Name: "Admin", Fields: []gogo.StructField{{Name: "ID", Type: "int64"}}}); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("NotARepository", func(t *testing.T) {
+		if _, err := exec.LookPath("git"); err != nil {
+			t.Skip("git not installed")
+		}
+		if _, err := gitfs.Open(t.TempDir(), gitfs.Options{}); err == nil {
+			t.Error("Expected an error outside a repository")
+		}
+	})
+}
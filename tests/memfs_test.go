@@ -0,0 +1,145 @@
+package tests
+
+import (
+	"errors"
+	iofs "io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+)
+
+// ioView hides MemFS.ReadFile from fstest.TestFS, which expects it to reject
+// names like "models/./user.go" that the real filesystem accepts
+type ioView struct {
+	fsys *gogofs.MemFS
+}
+
+func (v ioView) Open(name string) (iofs.File, error)          { return v.fsys.Open(name) }
+func (v ioView) ReadDir(name string) ([]iofs.DirEntry, error) { return v.fsys.ReadDir(name) }
+func (v ioView) Stat(name string) (iofs.FileInfo, error)      { return v.fsys.Stat(name) }
+
+func TestMemFS(t *testing.T) {
+	fsys, err := gogofs.ParseTxtar([]byte(`Starter project
+-- go.mod --
+module example.com/app
+-- models/user.go --
+package models
+
+type User struct {
+	ID int
+}
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	project, err := gogo.New(gogo.Options{FS: fsys, ConflictFunc: gogo.ConflictAccept})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := project.Struct(gogo.StructOpts{Filename: "models/user.go", Name: "User", Fields: []gogo.StructField{{Name: "ID", Type: "int64"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := project.Type(gogo.TypeOpts{Filename: "ids/ids.go", Types: []gogo.TypeDef{{Name: "ID", Definition: "int64"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The standard library sees the same tree
+	if err := fstest.TestFS(ioView{fsys}, "go.mod", "models/user.go", "ids/ids.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	archive := string(fsys.Txtar())
+	for _, want := range []string{"-- go.mod --\nmodule example.com/app\n", "-- ids/ids.go --\n", "\tID int64\n"} {
+		if !strings.Contains(archive, want) {
+			t.Errorf("Expected %q in:\n%s", want, archive)
+		}
+	}
+	if strings.Contains(archive, ".gogo") {
+		t.Errorf("Expected no leftover temp files:\n%s", archive)
+	}
+}
+
+func TestMemFSRules(t *testing.T) {
+	fsys := gogofs.NewMemFS()
+
+	if err := fsys.WriteFile("missing/user.go", nil, 0644); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected a missing directory error, got %v", err)
+	}
+	var escape *gogofs.PathEscapeError
+	if err := fsys.WriteFile("../user.go", nil, 0644); !errors.As(err, &escape) {
+		t.Errorf("Expected a PathEscapeError, got %v", err)
+	}
+
+	fsys.MkdirAll("models", 0755)
+	fsys.WriteFile("models/user.go", []byte("package models\n"), 0600)
+	if err := fsys.Remove("models"); err == nil {
+		t.Error("Expected an error removing a directory that isn't empty")
+	}
+
+	// Rewriting keeps the permissions, like the real filesystem
+	fsys.WriteFile("models/user.go", []byte("package models\n\n"), 0644)
+	mtime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys.Chtimes("models/user.go", mtime, mtime)
+	info, err := fsys.Stat("models/user.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode() != 0600 || !info.ModTime().Equal(mtime) || info.Size() != 16 {
+		t.Errorf("Unexpected info: %v %v %d", info.Mode(), info.ModTime(), info.Size())
+	}
+
+	if err := fsys.Rename("models", "people"); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(fsys.Files(), " "); got != "people/user.go" {
+		t.Errorf("Expected the directory moved, got %s", got)
+	}
+
+	t.Run("Concurrency", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				name := filepath.Join("people", strings.Repeat("x", i+1)+".go")
+				fsys.WriteFile(name, []byte("package models\n"), 0644)
+				fsys.ReadDir("people")
+				fsys.ReadFile(name)
+			}()
+		}
+		wg.Wait()
+		if len(fsys.Files()) != 11 {
+			t.Errorf("Expected 11 files, got %v", fsys.Files())
+		}
+	})
+
+	t.Run("Disk", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := fsys.WriteDir(dir); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(filepath.Join(dir, "people", "user.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 || !info.ModTime().Equal(mtime) {
+			t.Errorf("Unexpected info on disk: %v %v", info.Mode(), info.ModTime())
+		}
+
+		loaded, err := gogofs.LoadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(loaded.Txtar()), string(fsys.Txtar()); got != want {
+			t.Errorf("Expected\n%s\nGot\n%s", want, got)
+		}
+	})
+}
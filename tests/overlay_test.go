@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+)
+
+// changesString returns the changes of an overlay as "kind path" pairs
+func changesString(t *testing.T, overlay *gogofs.OverlayFS) string {
+	t.Helper()
+
+	changes, err := overlay.Changes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result []string
+	for _, change := range changes {
+		result = append(result, change.Kind.String()+" "+change.Path)
+	}
+	return strings.Join(result, ", ")
+}
+
+func TestOverlayFS(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "models"), 0755)
+	os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module example.com/app\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "models", "user.go"), []byte("package models\n\ntype User struct {\n\tID int\n}\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "models", "old.go"), []byte("package models\n"), 0644)
+
+	base, err := gogo.OpenFS(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	overlay := gogofs.NewOverlayFS(base)
+
+	project, err := gogo.New(gogo.Options{FS: overlay, ConflictFunc: gogo.ConflictAccept})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := project.Struct(gogo.StructOpts{Filename: "models/user.go", Name: "User", Fields: []gogo.StructField{{Name: "ID", Type: "int64"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := project.Type(gogo.TypeOpts{Filename: "ids/ids.go", Types: []gogo.TypeDef{{Name: "ID", Definition: "int64"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := overlay.Remove("models/old.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The base is untouched, the overlay shows the changes
+	if content, _ := os.ReadFile(filepath.Join(dir, "models", "user.go")); strings.Contains(string(content), "int64") {
+		t.Error("Base modified before commit")
+	}
+	if content, _ := overlay.ReadFile("models/user.go"); !strings.Contains(string(content), "ID int64") {
+		t.Errorf("Expected the change in the overlay, got:\n%s", content)
+	}
+	if _, err := overlay.Stat("models/old.go"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected removed file to be hidden, got %v", err)
+	}
+	entries, err := overlay.ReadDir("models")
+	if err != nil || len(entries) != 1 || entries[0].Name() != "user.go" {
+		t.Errorf("Expected only user.go in models, got %v %v", entries, err)
+	}
+
+	want := "create ids/ids.go, remove models/old.go, modify models/user.go"
+	if got := changesString(t, overlay); got != want {
+		t.Fatalf("Expected changes %s\nGot              %s", want, got)
+	}
+
+	if err := overlay.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if got := changesString(t, overlay); got != "" {
+		t.Errorf("Expected no changes after commit, got %s", got)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "models", "user.go")); !strings.Contains(string(content), "ID int64") {
+		t.Errorf("Expected the change in the base, got:\n%s", content)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "ids", "ids.go")); err != nil {
+		t.Error(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "models", "old.go")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected old.go removed from the base, got %v", err)
+	}
+}
+
+func TestOverlayFSReadOnlyBase(t *testing.T) {
+	base := gogofs.FromIOFS(fstest.MapFS{
+		"models/user.go": {Data: []byte("package models\n"), Mode: 0644},
+	})
+	if err := base.WriteFile("models/user.go", nil, 0644); !errors.Is(err, gogofs.ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly, got %v", err)
+	}
+
+	overlay := gogofs.NewOverlayFS(base)
+	if err := overlay.Rename("models/user.go", "models/person.go"); err != nil {
+		t.Fatal(err)
+	}
+	if err := overlay.WriteFile("missing/file.go", nil, 0644); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("Expected missing directory error, got %v", err)
+	}
+
+	want := "create models/person.go, remove models/user.go"
+	if got := changesString(t, overlay); got != want {
+		t.Errorf("Expected changes %s\nGot              %s", want, got)
+	}
+
+	// Moving it back leaves nothing to commit
+	if err := overlay.Rename("models/person.go", "models/user.go"); err != nil {
+		t.Fatal(err)
+	}
+	if got := changesString(t, overlay); got != "" {
+		t.Errorf("Expected no changes, got %s", got)
+	}
+
+	overlay.WriteFile("models/user.go", []byte("package people\n"), 0644)
+	if err := overlay.Commit(); !errors.Is(err, gogofs.ErrReadOnly) {
+		t.Errorf("Expected ErrReadOnly from commit, got %v", err)
+	}
+	overlay.Discard()
+	if content, _ := overlay.ReadFile("models/user.go"); string(content) != "package models\n" {
+		t.Errorf("Expected the base content after discard, got %q", content)
+	}
+}
+
+func TestOverlayFSPaths(t *testing.T) {
+	overlay := gogofs.NewOverlayFS(gogofs.NewMemFS())
+
+	// Paths leaving the root are rejected, not folded into it
+	for _, name := range []string{"../x.go", "a/../../x.go", "/x.go"} {
+		var escape *gogofs.PathEscapeError
+		if err := overlay.WriteFile(name, []byte("package x\n"), 0644); !errors.As(err, &escape) {
+			t.Errorf("Expected a PathEscapeError writing %s, got %v", name, err)
+		}
+		if _, err := overlay.ReadFile(name); !errors.As(err, &escape) {
+			t.Errorf("Expected a PathEscapeError reading %s, got %v", name, err)
+		}
+	}
+	if _, err := overlay.TempFile(".", "../x-*.tmp"); err == nil {
+		t.Error("Expected an error for a temp file outside the root")
+	}
+	if got := changesString(t, overlay); got != "" {
+		t.Errorf("Expected no changes, got %s", got)
+	}
+
+	// Changes are copies of the content of the overlay
+	if err := overlay.WriteFile("a/../x.go", []byte("package x\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	changes, err := overlay.Changes()
+	if err != nil || len(changes) != 1 || changes[0].Path != "x.go" {
+		t.Fatalf("Expected a change of x.go, got %v %v", changes, err)
+	}
+	changes[0].Data[0] = 'X'
+	if content, _ := overlay.ReadFile("x.go"); string(content) != "package x\n" {
+		t.Errorf("Expected the overlay unchanged, got %q", content)
+	}
+}
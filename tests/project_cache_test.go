@@ -0,0 +1,283 @@
+package tests
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+func TestProjectCache(t *testing.T) {
+	t.Run("BufferedWritesOnFlush", func(t *testing.T) {
+		fs := gogotest.New("")
+
+		var asked []string
+		project, err := gogo.New(gogo.Options{
+			FS:                 fs,
+			InitialPackageName: "models",
+			Buffered:           true,
+			ConflictFunc: func(_ gogofs.FS, filename, _ string, _ gogo.ChangeInfo) bool {
+				asked = append(asked, filename)
+				return true
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, name := range []string{"User", "Post"} {
+			if err := generateModel(project, strings.ToLower(name)+".go", name); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if files := fs.GetFiles(); len(files) != 0 {
+			t.Fatalf("Expected no writes before Flush, got %d files", len(files))
+		}
+
+		if err := project.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Join(asked, ",") != "post.go,user.go" {
+			t.Errorf("Expected one ConflictFunc call per file, got %v", asked)
+		}
+		for _, want := range []string{
+			"type User struct",
+			"func (m *User) Validate() error",
+			"const UserTable = \"user\"",
+			"type Post struct",
+		} {
+			if err := fs.Assert(want); err != nil {
+				t.Error(err)
+			}
+		}
+
+		// Nothing left to write
+		if err := project.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if len(asked) != 2 {
+			t.Errorf("Expected no more calls, got %v", asked)
+		}
+	})
+
+	t.Run("ExternalChangesAreRead", func(t *testing.T) {
+		fs := gogotest.New(`# user.go
+package models
+
+type User struct {
+	ID int
+}
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := generateModel(project, "user.go", "User"); err != nil {
+			t.Fatal(err)
+		}
+
+		// Someone else edits the file
+		content, _ := fs.ReadFile("user.go")
+		edited := strings.Replace(string(content), "type User struct", "// User is edited by hand\ntype User struct", 1)
+		if err := fs.WriteFile("user.go", []byte(edited), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Function(gogo.FunctionOpts{
+			Filename:   "user.go",
+			Name:       "NewUser",
+			ReturnType: "*User",
+			Body:       "return &User{}",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []string{"// User is edited by hand", "func NewUser() *User"} {
+			if err := fs.Assert(want); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	t.Run("ExternalChangesWithSameTimeAndSize", func(t *testing.T) {
+		dir := t.TempDir()
+		project, err := gogo.NewFS(dir, gogo.Options{ConflictFunc: gogo.ConflictAccept, InitialPackageName: "models"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Type(gogo.TypeOpts{Filename: "ids.go", Types: []gogo.TypeDef{{Name: "ID", Definition: "int32"}}}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Someone else changes the file within the resolution of the
+		// modification time: same time, same size
+		filename := filepath.Join(dir, "ids.go")
+		info, err := os.Stat(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, _ := os.ReadFile(filename)
+		edited := strings.Replace(string(content), "int32", "int16", 1)
+		if err := os.WriteFile(filename, []byte(edited), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filename, info.ModTime(), info.ModTime()); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := project.Type(gogo.TypeOpts{Filename: "ids.go", Types: []gogo.TypeDef{{Name: "Name", Definition: "string"}}, PreserveExisting: true}); err != nil {
+			t.Fatal(err)
+		}
+		content, _ = os.ReadFile(filename)
+		for _, want := range []string{"type ID int16", "type Name string"} {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("Expected %q in:\n%s", want, content)
+			}
+		}
+	})
+
+	t.Run("ExternalChangesWithBufferedChanges", func(t *testing.T) {
+		fs := gogotest.New(`# user.go
+package models
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept, Buffered: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := generateModel(project, "user.go", "User"); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := fs.WriteFile("user.go", []byte("package models\n\ntype Other int\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := project.Flush(); err == nil {
+			t.Fatal("Expected error when the file changed on disk")
+		}
+		if err := fs.Assert("type Other int"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("RejectedChangesAreDropped", func(t *testing.T) {
+		fs := gogotest.New(`# user.go
+package models
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictReject, Buffered: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := generateModel(project, "user.go", "User"); err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Flush(); !errors.Is(err, gogo.ErrRejected) {
+			t.Fatalf("Expected the rejection to be reported, got %v", err)
+		}
+
+		content, _ := fs.ReadFile("user.go")
+		if string(content) != "package models\n" {
+			t.Errorf("Rejected changes should not be written:\n%s", content)
+		}
+
+		// The next change starts from the content on disk
+		err = project.Constant(gogo.ConstantOpts{
+			Filename:  "user.go",
+			Constants: []gogo.Constant{{Name: "Version", Value: "1"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := project.Flush(); !errors.Is(err, gogo.ErrRejected) {
+			t.Fatalf("Expected the rejection to be reported, got %v", err)
+		}
+	})
+}
+
+// generateModel adds a struct, two methods and a constant to a file, the
+// typical set of operations of a model generator
+func generateModel(project *gogo.Project, filename, name string) error {
+	err := project.Struct(gogo.StructOpts{
+		Filename: filename,
+		Name:     name,
+		Fields: []gogo.StructField{
+			{Name: "ID", Type: "int", Annotation: "`json:\"id\"`"},
+			{Name: "Name", Type: "string", Annotation: "`json:\"name\"`"},
+			{Name: "Email", Type: "string", Annotation: "`json:\"email\"`"},
+		},
+		PreserveExisting: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = project.Method(gogo.MethodOpts{
+		Filename:     filename,
+		Name:         "Validate",
+		ReceiverName: "m",
+		ReceiverType: "*" + name,
+		ReturnType:   "error",
+		Body:         "if m.Email == \"\" {\n\treturn nil\n}\nreturn nil",
+	})
+	if err != nil {
+		return err
+	}
+
+	err = project.Method(gogo.MethodOpts{
+		Filename:     filename,
+		Name:         "TableName",
+		ReceiverName: "m",
+		ReceiverType: name,
+		ReturnType:   "string",
+		Body:         "return " + name + "Table",
+	})
+	if err != nil {
+		return err
+	}
+
+	return project.Constant(gogo.ConstantOpts{
+		Filename:  filename,
+		Constants: []gogo.Constant{{Name: name + "Table", Value: fmt.Sprintf("%q", strings.ToLower(name))}},
+	})
+}
+
+// benchmarkModels generates a package of models on disk
+func benchmarkModels(b *testing.B, buffered bool) {
+	const models = 400
+
+	for i := 0; i < b.N; i++ {
+		project, err := gogo.NewFS(b.TempDir(), gogo.Options{
+			InitialPackageName: "models",
+			ConflictFunc:       gogo.ConflictAccept,
+			Buffered:           buffered,
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for m := 0; m < models; m++ {
+			name := fmt.Sprintf("Model%d", m)
+			if err := generateModel(project, strings.ToLower(name)+".go", name); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if err := project.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenerateImmediate(b *testing.B) { benchmarkModels(b, false) }
+func BenchmarkGenerateBuffered(b *testing.B)  { benchmarkModels(b, true) }
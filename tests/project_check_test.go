@@ -0,0 +1,96 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+)
+
+// checkGenerator generates an owned file with a struct
+func checkGenerator(p *gogo.Project) error {
+	return p.File(gogo.FileSpec{
+		Path:    "models/user.go",
+		Structs: []gogo.StructOpts{{Name: "User", Fields: []gogo.StructField{{Name: "ID", Type: "int64"}}}},
+	})
+}
+
+func TestProjectCheck(t *testing.T) {
+	fsys := gogofs.NewMemFS()
+	fsys.WriteFile("go.mod", []byte("module example.com/app\n"), 0644)
+	project, err := gogo.New(gogo.Options{FS: fsys, ConflictFunc: gogo.ConflictAccept, InitialPackageName: "models", File: gogo.FileOpts{Header: gogo.GeneratedHeader("checkgen")}, History: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing generated yet
+	drifts, err := project.Check(checkGenerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 1 || drifts[0].File != "models/user.go" || drifts[0].Action != "create" || drifts[0].Owned {
+		t.Fatalf("Expected models/user.go to be created, got %+v", drifts)
+	}
+	if _, err := fsys.Stat("models/user.go"); err == nil {
+		t.Fatal("Expected the check to write nothing")
+	}
+
+	if err := checkGenerator(project); err != nil {
+		t.Fatal(err)
+	}
+	if drifts, err := project.Check(checkGenerator); err != nil || len(drifts) != 0 {
+		t.Fatalf("Expected no drift after generating, got %+v %v", drifts, err)
+	}
+
+	// A hand edit to the generated file is drift
+	content, _ := fsys.ReadFile("models/user.go")
+	edited := strings.Replace(string(content), "ID int64", "ID string", 1)
+	fsys.WriteFile("models/user.go", []byte(edited), 0644)
+
+	drifts, err = project.Check(checkGenerator)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 1 || drifts[0].Action != "modify" || !drifts[0].Owned {
+		t.Fatalf("Expected a modified owned file, got %+v", drifts)
+	}
+	if diff := drifts[0].Diff; !strings.Contains(diff, "-\tID string") || !strings.Contains(diff, "+\tID int64") {
+		t.Errorf("Unexpected diff:\n%s", diff)
+	}
+	if again, _ := fsys.ReadFile("models/user.go"); string(again) != edited {
+		t.Errorf("Expected the edit kept, got:\n%s", again)
+	}
+	if runs := historyFiles(fsys); len(runs) != 1 {
+		t.Errorf("Expected only the real run in the history, got %v", runs)
+	}
+}
+
+func TestProjectCheckDotGogoFiles(t *testing.T) {
+	fsys := gogofs.NewMemFS()
+	fsys.WriteFile("go.mod", []byte("module example.com/app\n"), 0644)
+	project, err := gogo.New(gogo.Options{FS: fsys, ConflictFunc: gogo.ConflictAccept})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the internal files are skipped, not every name starting like them
+	drifts, err := project.Check(func(preview *gogo.Project) error {
+		for _, path := range []string{".gogorc.go", ".gogo-templates/models.go"} {
+			if err := preview.Type(gogo.TypeOpts{Filename: path, Types: []gogo.TypeDef{{Name: "ID", Definition: "int64"}}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var files []string
+	for _, drift := range drifts {
+		files = append(files, drift.File)
+	}
+	if got := strings.Join(files, " "); got != ".gogo-templates/models.go .gogorc.go" {
+		t.Errorf("Expected drift in both files, got %q", got)
+	}
+}
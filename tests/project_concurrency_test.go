@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+func TestProjectConcurrency(t *testing.T) {
+	t.Run("SameFile", func(t *testing.T) {
+		fs := gogotest.New("")
+		project, err := gogo.New(gogo.Options{
+			FS:                 fs,
+			ConflictFunc:       gogo.ConflictAccept,
+			InitialPackageName: "models",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		const methods = 20
+		var wg sync.WaitGroup
+		errs := make(chan error, methods)
+		for i := 0; i < methods; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- project.Method(gogo.MethodOpts{
+					Filename:     "user.go",
+					Name:         fmt.Sprintf("Method%d", i),
+					ReceiverName: "u",
+					ReceiverType: "*User",
+					ReturnType:   "int",
+					Body:         fmt.Sprintf("return %d", i),
+				})
+			}()
+		}
+		wg.Wait()
+		close(errs)
+		for err := range errs {
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		content, _ := fs.ReadFile("user.go")
+		for i := 0; i < methods; i++ {
+			if !strings.Contains(string(content), fmt.Sprintf("func (u *User) Method%d() int", i)) {
+				t.Errorf("Method%d was lost:\n%s", i, content)
+			}
+		}
+		if len(fs.GetFiles()) != 1 {
+			t.Errorf("Expected only user.go, got %d files", len(fs.GetFiles()))
+		}
+	})
+
+	t.Run("Parallel", func(t *testing.T) {
+		for _, buffered := range []bool{false, true} {
+			fs := gogotest.New("")
+
+			var mu sync.Mutex
+			var active, maxActive int
+			project, err := gogo.New(gogo.Options{
+				FS:                 fs,
+				InitialPackageName: "models",
+				Buffered:           buffered,
+				ConflictFunc: func(_ gogofs.FS, _, _ string, _ gogo.ChangeInfo) bool {
+					mu.Lock()
+					active++
+					maxActive = max(maxActive, active)
+					mu.Unlock()
+
+					time.Sleep(100 * time.Microsecond)
+
+					mu.Lock()
+					active--
+					mu.Unlock()
+					return true
+				},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			const models = 50
+			var tasks []func() error
+			for m := 0; m < models; m++ {
+				name := fmt.Sprintf("Model%d", m)
+				tasks = append(tasks, func() error {
+					return generateModel(project, strings.ToLower(name)+".go", name)
+				})
+			}
+			// Every task also touches a shared file
+			for m := 0; m < models; m++ {
+				tasks = append(tasks, func() error {
+					return project.Constant(gogo.ConstantOpts{
+						Filename:  "tables.go",
+						Constants: []gogo.Constant{{Name: fmt.Sprintf("Table%d", m), Value: fmt.Sprint(m)}},
+					})
+				})
+			}
+
+			if err := project.Parallel(8, tasks...); err != nil {
+				t.Fatal(err)
+			}
+			if err := project.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			files := fs.GetFiles()
+			if len(files) != models+1 {
+				t.Errorf("Expected %d files, got %d", models+1, len(files))
+			}
+			for m := 0; m < models; m++ {
+				if !strings.Contains(string(files[fmt.Sprintf("model%d.go", m)]), fmt.Sprintf("func (m *Model%d) Validate() error", m)) {
+					t.Errorf("model%d.go is incomplete", m)
+				}
+				if !strings.Contains(string(files["tables.go"]), fmt.Sprintf("Table%d = %d", m, m)) {
+					t.Errorf("Table%d was lost", m)
+				}
+			}
+			if maxActive != 1 {
+				t.Errorf("ConflictFunc calls should not overlap, got %d at once", maxActive)
+			}
+		}
+	})
+
+	t.Run("ParallelErrors", func(t *testing.T) {
+		project, err := gogo.New(gogo.Options{FS: gogotest.New(""), ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Parallel(0,
+			func() error { return project.Struct(gogo.StructOpts{Name: "A"}) },
+			func() error { return nil },
+			func() error { return project.Method(gogo.MethodOpts{Filename: "b.go"}) },
+		)
+		if err == nil {
+			t.Fatal("Expected errors")
+		}
+		if lines := strings.Count(err.Error(), "\n") + 1; lines != 2 {
+			t.Errorf("Expected both errors, got: %v", err)
+		}
+	})
+}
@@ -0,0 +1,221 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+func TestProjectContext(t *testing.T) {
+	version := []gogo.Constant{{Name: "Version", Value: "1"}}
+
+	t.Run("CancelledBeforeStart", func(t *testing.T) {
+		fs := gogotest.New("")
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err = project.ConstantContext(ctx, gogo.ConstantOpts{Filename: "version.go", Constants: version})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+		if len(fs.GetFiles()) != 0 {
+			t.Errorf("Nothing should be written, got:\n%s", fs)
+		}
+	})
+
+	t.Run("CancelledWhileAsking", func(t *testing.T) {
+		fs := gogotest.New(`# version.go
+package models
+`)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		project, err := gogo.New(gogo.Options{
+			FS: fs,
+			ConflictFuncContext: func(ctx context.Context, _ gogofs.FS, _, _ string, _ gogo.ChangeInfo) bool {
+				// Ctrl-C at the prompt
+				cancel()
+				return true
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.ConstantContext(ctx, gogo.ConstantOpts{Filename: "version.go", Constants: version})
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+
+		// No partial write and no temp file or journal left behind
+		files := fs.GetFiles()
+		if len(files) != 1 || string(files["version.go"]) != "package models\n" {
+			t.Errorf("Expected version.go untouched and nothing else, got:\n%s", fs)
+		}
+	})
+
+	t.Run("CancelledChangeIsDropped", func(t *testing.T) {
+		fs := gogotest.New(`# version.go
+package models
+`)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		project, err := gogo.New(gogo.Options{
+			FS: fs,
+			ConflictFuncContext: func(ctx context.Context, _ gogofs.FS, _, _ string, _ gogo.ChangeInfo) bool {
+				cancel()
+				return true
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cancelled := []gogo.Constant{{Name: "Cancelled", Value: "1"}}
+		err = project.ConstantContext(ctx, gogo.ConstantOpts{Filename: "version.go", Constants: cancelled})
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("Expected context.Canceled, got %v", err)
+		}
+
+		// The next operation on the file doesn't write the cancelled change
+		later := []gogo.Constant{{Name: "Later", Value: "2"}}
+		if err := project.ConstantContext(context.Background(), gogo.ConstantOpts{Filename: "version.go", Constants: later}); err != nil {
+			t.Fatal(err)
+		}
+		content := string(fs.GetFiles()["version.go"])
+		if strings.Contains(content, "Cancelled") || !strings.Contains(content, "Later = 2") {
+			t.Errorf("Expected only the later change, got:\n%s", content)
+		}
+	})
+
+	t.Run("FlushStopsBetweenFiles", func(t *testing.T) {
+		fs := gogotest.New("")
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var asked []string
+		project, err := gogo.New(gogo.Options{
+			FS:                 fs,
+			InitialPackageName: "models",
+			Buffered:           true,
+			ConflictFuncContext: func(ctx context.Context, _ gogofs.FS, _, _ string, info gogo.ChangeInfo) bool {
+				asked = append(asked, info.FileName)
+				if info.FileName == "b.go" {
+					cancel()
+				}
+				return true
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, filename := range []string{"a.go", "b.go", "c.go"} {
+			if err := project.ConstantContext(ctx, gogo.ConstantOpts{Filename: filename, Constants: version}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := project.FlushContext(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+		if files := fs.GetFiles(); len(files) != 1 || files["a.go"] == nil {
+			t.Errorf("Expected only a.go to be written, got:\n%s", fs)
+		}
+		if len(asked) != 2 {
+			t.Errorf("Expected to stop after b.go, asked about %v", asked)
+		}
+
+		// The cancelled change is dropped, the ones not reached are still
+		// buffered
+		if err := project.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if files := fs.GetFiles(); len(files) != 2 || files["c.go"] == nil {
+			t.Errorf("Expected a.go and c.go to be written, got:\n%s", fs)
+		}
+	})
+
+	t.Run("AskAfterCancelledPrompt", func(t *testing.T) {
+		helper := exec.Command(os.Args[0], "-test.run=^TestAskHelperProcess$")
+		helper.Env = append(os.Environ(), "GOGO_ASK_HELPER=1")
+		stdin, err := helper.StdinPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdout, err := helper.StdoutPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := helper.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer helper.Process.Kill()
+
+		// Answer once the first prompt is cancelled, the answer goes to the
+		// next prompt
+		output := bufio.NewReader(stdout)
+		for {
+			line, err := output.ReadString('\n')
+			if err != nil {
+				t.Fatalf("Helper exited before cancelling the prompt: %v", err)
+			}
+			if line == "cancelled\n" {
+				break
+			}
+		}
+		io.WriteString(stdin, "y\n")
+		stdin.Close()
+
+		rest, _ := io.ReadAll(output)
+		helper.Wait()
+		if !strings.Contains(string(rest), "accepted: true\n") {
+			t.Errorf("Expected the answer to reach the second prompt, got:\n%s", rest)
+		}
+	})
+
+	t.Run("ConflictFuncsAreExclusive", func(t *testing.T) {
+		_, err := gogo.New(gogo.Options{
+			FS:                  gogotest.New(""),
+			ConflictFunc:        gogo.ConflictAccept,
+			ConflictFuncContext: gogo.ConflictAskContext,
+		})
+		if !errors.Is(err, gogo.ErrInvalidOptions) {
+			t.Errorf("Expected ErrInvalidOptions, got %v", err)
+		}
+	})
+}
+
+// TestAskHelperProcess is not a real test. It is run as a separate process by
+// TestProjectContext to answer a prompt after another one was cancelled.
+func TestAskHelperProcess(t *testing.T) {
+	if os.Getenv("GOGO_ASK_HELPER") == "" {
+		t.Skip("helper process")
+	}
+
+	info := gogo.ChangeInfo{Action: "modify", FileName: "version.go", Diff: "+const Version = 1"}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	gogo.ConflictAskContext(ctx, nil, "", "", info)
+	os.Stdout.WriteString("cancelled\n")
+
+	accepted := gogo.ConflictAskContext(context.Background(), nil, "", "", info)
+	fmt.Printf("accepted: %v\n", accepted)
+}
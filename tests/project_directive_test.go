@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+)
+
+// directiveProject returns a project with directives in two packages
+func directiveProject(t *testing.T) (*gogo.Project, *gogofs.MemFS) {
+	t.Helper()
+	fsys, err := gogofs.ParseTxtar([]byte(`-- go.mod --
+module example.com/app
+-- models/user.go --
+package models
+
+//gogo:enum Status active,in_progress
+//gogo:generate crud table=users title="User \"accounts\""
+type User struct {
+	ID int64
+}
+-- api/api.go --
+package api
+
+// Comments that only mention //gogo:enum are not directives
+var Name = "//gogo:enum Fake a,b"
+
+//gogo:enum Level low,high type=int
+-- models/gen.go --
+// Code generated by hand. DO NOT EDIT.
+
+package models
+
+//gogo:enum Ignored a,b
+-- vendor/lib/lib.go --
+package lib
+
+//gogo:enum Ignored a,b
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	project, err := gogo.New(gogo.Options{FS: fsys, ConflictFunc: gogo.ConflictAccept})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return project, fsys
+}
+
+func TestProjectDirectives(t *testing.T) {
+	project, _ := directiveProject(t)
+
+	directives, err := project.Directives()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []gogo.Directive{
+		{Name: "enum", Args: []string{"Level", "low,high"}, Options: map[string]string{"type": "int"}, File: "api/api.go", Line: 6, Package: "api"},
+		{Name: "enum", Args: []string{"Status", "active,in_progress"}, Options: map[string]string{}, File: "models/user.go", Line: 3, Package: "models"},
+		{Name: "crud", Options: map[string]string{"table": "users", "title": `User "accounts"`}, File: "models/user.go", Line: 4, Package: "models"},
+	}
+	if !reflect.DeepEqual(directives, want) {
+		t.Errorf("Expected\n%+v\ngot\n%+v", want, directives)
+	}
+
+	if directives, _ := project.Directives("./api"); len(directives) != 1 || directives[0].File != "api/api.go" {
+		t.Errorf("Expected the directives of ./api, got %+v", directives)
+	}
+	if directives, _ := project.Directives("models/..."); len(directives) != 2 {
+		t.Errorf("Expected the directives of models/..., got %+v", directives)
+	}
+}
+
+func TestProjectRunDirectives(t *testing.T) {
+	project, fsys := directiveProject(t)
+
+	registry := gogo.NewRegistry()
+	if err := project.RunDirectives(registry); !errors.Is(err, gogo.ErrNotFound) || !strings.Contains(err.Error(), "models/user.go:4: //gogo:crud") {
+		t.Fatalf("Expected no generator for crud, got %v", err)
+	}
+
+	crud := template.Must(template.New("crud").Funcs(gogo.TemplateFuncs).Parse(`{"version": 1, "files": [{
+  "path": {{printf "%s/%s_store.go" .Dir .Options.table | json}},
+  "package": {{json .Package}},
+  "functions": [{"name": "Title", "returns": "string", "body": {{printf "return %q" .Options.title | json}}}]
+}]}`))
+	registry.RegisterTemplate("crud", crud)
+	if err := project.RunDirectives(registry); err != nil {
+		t.Fatal(err)
+	}
+
+	// New files join the package of the directive
+	for file, want := range map[string][]string{
+		"models/status_enum.go": {"package models", "type Status string", `StatusInProgress Status = "in_progress"`},
+		"models/users_store.go": {"package models", "func Title() string {", `return "User \"accounts\""`},
+		"api/level_enum.go":     {"package api", "type Level int", "LevelHigh Level = 1"},
+	} {
+		content, err := fsys.ReadFile(file)
+		if err != nil {
+			t.Errorf("Expected %s: %v", file, err)
+			continue
+		}
+		for _, want := range want {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("Expected %q in %s:\n%s", want, file, content)
+			}
+		}
+	}
+	if _, err := fsys.Stat("models/ignored_enum.go"); err == nil {
+		t.Error("Expected the directives of generated and vendored files skipped")
+	}
+
+	// Invalid directives report where they are
+	fsys.WriteFile("api/bad.go", []byte("package api\n\n//gogo:enum Level\n"), 0644)
+	if err := project.RunDirectives(registry, "./api"); !errors.Is(err, gogo.ErrInvalidOptions) || !strings.Contains(err.Error(), "api/bad.go:3") {
+		t.Errorf("Expected an invalid directive in api/bad.go, got %v", err)
+	}
+}
@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+	"github.com/guillermo/gogo/template"
+)
+
+func TestProjectErrors(t *testing.T) {
+	t.Run("Rejected", func(t *testing.T) {
+		project, err := gogo.New(gogo.Options{FS: gogotest.New(""), ConflictFunc: gogo.ConflictReject})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Constant(gogo.ConstantOpts{Filename: "version.go", Constants: []gogo.Constant{{Name: "Version", Value: "1"}}})
+		if !errors.Is(err, gogo.ErrRejected) {
+			t.Errorf("Expected ErrRejected, got %v", err)
+		}
+	})
+
+	t.Run("InvalidOptions", func(t *testing.T) {
+		project, err := gogo.New(gogo.Options{FS: gogotest.New(""), ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for name, err := range map[string]error{
+			"Struct":   project.Struct(gogo.StructOpts{Filename: "user.go", Name: "User"}),
+			"Method":   project.Method(gogo.MethodOpts{Filename: "user.go", Name: "Key", Body: "return 1"}),
+			"Variable": project.Variable(gogo.VariableOpts{Variables: []gogo.Variable{{Name: "A", Value: "1"}}}),
+			"File":     project.File(gogo.FileSpec{Path: "user.go", Consts: []gogo.Constant{{Value: "1"}}}),
+		} {
+			if !errors.Is(err, gogo.ErrInvalidOptions) {
+				t.Errorf("%s: expected ErrInvalidOptions, got %v", name, err)
+			}
+		}
+		if _, err := gogo.New(gogo.Options{}); !errors.Is(err, gogo.ErrInvalidOptions) {
+			t.Errorf("Expected ErrInvalidOptions without filesystem, got %v", err)
+		}
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		tmpl, err := template.New(gogotest.New("# user.go\npackage models\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tmpl.ExtractStruct("User"); !errors.Is(err, gogo.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+
+		project, err := gogo.New(gogo.Options{FS: gogotest.New(""), ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := project.ImportPathFor("models"); !errors.Is(err, gogo.ErrNotFound) {
+			t.Errorf("Expected ErrNotFound without go.mod, got %v", err)
+		}
+	})
+
+	t.Run("ParseErrorInFile", func(t *testing.T) {
+		fs := gogotest.New(`# user.go
+package models
+
+type User struct {
+	ID int =
+}
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Constant(gogo.ConstantOpts{Filename: "user.go", Constants: []gogo.Constant{{Name: "Version", Value: "1"}}})
+		var parseErr *gogo.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("Expected a ParseError, got %v", err)
+		}
+		if parseErr.File != "user.go" || parseErr.Snippet != "" || parseErr.Line != 4 {
+			t.Errorf("Unexpected error: %+v", parseErr)
+		}
+	})
+
+	t.Run("ParseErrorInSnippet", func(t *testing.T) {
+		project, err := gogo.New(gogo.Options{FS: gogotest.New(""), ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Variable(gogo.VariableOpts{Filename: "vars.go", Content: "var A = 1\nvar B = (\n"})
+		var parseErr *gogo.ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("Expected a ParseError, got %v", err)
+		}
+		if parseErr.File != "vars.go" || parseErr.Snippet == "" || parseErr.Line != 2 {
+			t.Errorf("Unexpected error: %+v", parseErr)
+		}
+	})
+}
+
+func TestProjectResults(t *testing.T) {
+	fs := gogotest.New(`# manual.go
+package models
+# existing.go
+package models
+
+const Version = "1"
+`)
+	project, err := gogo.New(gogo.Options{
+		FS:                 fs,
+		InitialPackageName: "models",
+		ConflictFunc: func(_ gogofs.FS, _, _ string, info gogo.ChangeInfo) bool {
+			return info.FileName != "manual.go"
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	version := []gogo.Constant{{Name: "Version", Value: `"1"`}}
+	project.Constant(gogo.ConstantOpts{Filename: "existing.go", Constants: version})
+	project.Constant(gogo.ConstantOpts{Filename: "new.go", Constants: version})
+	project.Constant(gogo.ConstantOpts{Filename: "new.go", Constants: []gogo.Constant{{Name: "Name", Value: `"app"`}}})
+	project.Constant(gogo.ConstantOpts{Filename: "manual.go", Constants: version})
+	project.Constant(gogo.ConstantOpts{Filename: "broken.go", Content: "const ("})
+
+	want := map[string]gogo.FileStatus{
+		"broken.go":   gogo.StatusFailed,
+		"existing.go": gogo.StatusUnchanged,
+		"manual.go":   gogo.StatusRejected,
+		"new.go":      gogo.StatusCreated,
+	}
+	results := project.Results()
+	if len(results) != len(want) {
+		t.Fatalf("Expected %d results, got %v", len(want), results)
+	}
+	for _, result := range results {
+		if result.Status != want[result.File] {
+			t.Errorf("Expected %s to be %v, got %v", result.File, want[result.File], result.Status)
+		}
+		if (result.Err != nil) != (result.Status == gogo.StatusFailed) {
+			t.Errorf("Unexpected error for %s: %v", result.File, result.Err)
+		}
+	}
+
+	t.Run("Buffered", func(t *testing.T) {
+		project, err := gogo.New(gogo.Options{FS: gogotest.New(""), ConflictFunc: gogo.ConflictAccept, Buffered: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		project.Constant(gogo.ConstantOpts{Filename: "version.go", Constants: version})
+		if results := project.Results(); len(results) != 1 || results[0].Status != gogo.StatusPending {
+			t.Errorf("Expected the change to be pending, got %v", results)
+		}
+
+		if err := project.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if results := project.Results(); len(results) != 1 || results[0].Status != gogo.StatusCreated {
+			t.Errorf("Expected the file to be created, got %v", results)
+		}
+	})
+}
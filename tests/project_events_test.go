@@ -0,0 +1,170 @@
+package tests
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+// eventLog records the events of a project as "kind:name@file action"
+type eventLog []string
+
+func (l *eventLog) listener() gogo.EventFuncs {
+	record := func(kind string) func(gogo.Event) {
+		return func(e gogo.Event) {
+			entry := fmt.Sprintf("%s %s:%s@%s", kind, e.Kind, e.Name, e.File)
+			if e.Action != "" {
+				entry += " " + e.Action
+			}
+			*l = append(*l, entry)
+		}
+	}
+	return gogo.EventFuncs{
+		Plan:      record("plan"),
+		Apply:     record("apply"),
+		Reject:    record("reject"),
+		Unchanged: record("unchanged"),
+		Error:     record("error"),
+	}
+}
+
+func (l eventLog) String() string {
+	return strings.Join(l, "\n")
+}
+
+func TestProjectEvents(t *testing.T) {
+	t.Run("Immediate", func(t *testing.T) {
+		var events eventLog
+		project, err := gogo.New(gogo.Options{
+			FS:                 gogotest.New(""),
+			InitialPackageName: "models",
+			Events:             events.listener(),
+			ConflictFunc: func(_ gogofs.FS, _, _ string, info gogo.ChangeInfo) bool {
+				return info.FileName != "rejected.go"
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fields := []gogo.StructField{{Name: "ID", Type: "int"}}
+		project.Struct(gogo.StructOpts{Filename: "user.go", Name: "User", Fields: fields})
+		project.Struct(gogo.StructOpts{Filename: "user.go", Name: "User", Fields: fields})
+		project.Method(gogo.MethodOpts{Filename: "user.go", Name: "Key", ReceiverName: "u", ReceiverType: "*User", ReturnType: "int", Body: "return u.ID"})
+		project.Constant(gogo.ConstantOpts{Filename: "rejected.go", Constants: []gogo.Constant{{Name: "A", Value: "1"}, {Name: "B", Value: "2"}}})
+		project.Function(gogo.FunctionOpts{Filename: "broken.go", Name: "Broken", Body: "return {"})
+
+		want := eventLog{
+			"plan struct:User@user.go create",
+			"apply struct:User@user.go create",
+			"unchanged struct:User@user.go",
+			"plan method:User.Key@user.go modify",
+			"apply method:User.Key@user.go modify",
+			"plan constant:A, B@rejected.go create",
+			"reject constant:A, B@rejected.go create",
+			"error function:Broken@broken.go",
+		}
+		if events.String() != want.String() {
+			t.Errorf("Expected:\n%s\nGot:\n%s", want, events)
+		}
+	})
+
+	t.Run("Buffered", func(t *testing.T) {
+		var events eventLog
+		fs := gogotest.New("")
+		project, err := gogo.New(gogo.Options{
+			FS:                 fs,
+			InitialPackageName: "models",
+			ConflictFunc:       gogo.ConflictAccept,
+			Buffered:           true,
+			Events:             events.listener(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		project.Type(gogo.TypeOpts{Filename: "types.go", Types: []gogo.TypeDef{{Name: "ID", Definition: "int"}}})
+		project.Variable(gogo.VariableOpts{Filename: "types.go", Variables: []gogo.Variable{{Name: "Zero", Value: "ID(0)"}}})
+		project.File(gogo.FileSpec{Path: "other.go", Consts: []gogo.Constant{{Name: "Max", Value: "10"}}})
+
+		// Repeating an operation changes nothing
+		project.File(gogo.FileSpec{Path: "other.go", Consts: []gogo.Constant{{Name: "Max", Value: "10"}}})
+
+		if err := project.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		want := eventLog{
+			"plan type:ID@types.go create",
+			"plan variable:Zero@types.go create",
+			"plan file:Max@other.go create",
+			"unchanged file:Max@other.go",
+			"apply file:Max@other.go create",
+			"apply type:ID@types.go create",
+			"apply variable:Zero@types.go create",
+		}
+		if events.String() != want.String() {
+			t.Errorf("Expected:\n%s\nGot:\n%s", want, events)
+		}
+	})
+
+	t.Run("FlushErrors", func(t *testing.T) {
+		var events eventLog
+		fs := gogotest.New("")
+		project, err := gogo.New(gogo.Options{
+			FS:                 fs,
+			InitialPackageName: "models",
+			ConflictFunc:       gogo.ConflictAccept,
+			Buffered:           true,
+			Events:             events.listener(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		project.Type(gogo.TypeOpts{Filename: "types.go", Types: []gogo.TypeDef{{Name: "ID", Definition: "int"}}})
+		fs.WriteFile("types.go", []byte("package models\n\n// Written by hand\n"), 0644)
+
+		if err := project.Flush(); err == nil {
+			t.Fatal("Expected the conflict with the file on disk to fail")
+		}
+		if events[len(events)-1] != "error type:ID@types.go create" {
+			t.Errorf("Expected the buffered change to fail, got:\n%s", events)
+		}
+	})
+
+	t.Run("Logger", func(t *testing.T) {
+		var buf bytes.Buffer
+		project, err := gogo.New(gogo.Options{
+			FS:                 gogotest.New(""),
+			InitialPackageName: "models",
+			ConflictFunc:       gogo.ConflictAccept,
+			Logger:             slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		project.Struct(gogo.StructOpts{Filename: "user.go", Name: "User", Fields: []gogo.StructField{{Name: "ID", Type: "int"}}})
+		err = project.Struct(gogo.StructOpts{Filename: "user.go", Name: "User"})
+
+		logs := buf.String()
+		for _, want := range []string{
+			`level=INFO msg="applied change" kind=struct file=user.go name=User action=create`,
+			`level=ERROR msg="failed change" kind=struct file=user.go name=User error="` + err.Error() + `"`,
+		} {
+			if !strings.Contains(logs, want) {
+				t.Errorf("Expected %q in logs:\n%s", want, logs)
+			}
+		}
+		if strings.Contains(logs, "planned change") {
+			t.Errorf("Plans are logged at debug level:\n%s", logs)
+		}
+	})
+}
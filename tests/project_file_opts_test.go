@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+func TestProjectFileOpts(t *testing.T) {
+	t.Run("NewFileGetsPreamble", func(t *testing.T) {
+		fs := gogotest.New("")
+		project, err := gogo.New(gogo.Options{
+			FS:                 fs,
+			ConflictFunc:       gogo.ConflictAccept,
+			InitialPackageName: "models",
+			File: gogo.FileOpts{
+				Header:     gogo.GeneratedHeader("modelgen") + "\n\nCopyright 2026 Example Inc.",
+				BuildTags:  "linux && amd64",
+				PackageDoc: "Package models contains the data model.",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Struct(gogo.StructOpts{
+			Filename: "user.go",
+			Name:     "User",
+			Fields:   []gogo.StructField{{Name: "ID", Type: "int"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, _ := fs.ReadFile("user.go")
+		want := `// Code generated by modelgen. DO NOT EDIT.
+//
+// Copyright 2026 Example Inc.
+
+//go:build linux && amd64
+
+// Package models contains the data model.
+package models
+
+type User struct {
+	ID int
+}
+`
+		if string(content) != want {
+			t.Errorf("Unexpected content:\n%s\nwant:\n%s", content, want)
+		}
+	})
+
+	t.Run("ExistingFileIsReconciled", func(t *testing.T) {
+		fs := gogotest.New(`# user.go
+// Copyright 2020 Old Owner
+
+//go:build linux
+// +build linux
+
+//go:generate stringer -type=Kind
+
+// Package models is old.
+package models
+
+type User struct {
+	ID int
+}
+`)
+		project, err := gogo.New(gogo.Options{
+			FS:           fs,
+			ConflictFunc: gogo.ConflictAccept,
+			File: gogo.FileOpts{
+				Header:    "Copyright 2026 New Owner",
+				BuildTags: "linux || darwin",
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Struct(gogo.StructOpts{
+			Filename:         "user.go",
+			Name:             "User",
+			Fields:           []gogo.StructField{{Name: "Name", Type: "string"}},
+			PreserveExisting: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, _ := fs.ReadFile("user.go")
+		want := `// Copyright 2026 New Owner
+
+//go:build linux || darwin
+
+//go:generate stringer -type=Kind
+
+// Package models is old.
+package models
+
+type User struct {
+	ID   int
+	Name string
+}
+`
+		if string(content) != want {
+			t.Errorf("Unexpected content:\n%s\nwant:\n%s", content, want)
+		}
+
+		// Running again does not duplicate anything
+		err = project.Struct(gogo.StructOpts{
+			Filename:         "user.go",
+			Name:             "User",
+			Fields:           []gogo.StructField{{Name: "Name", Type: "string"}},
+			PreserveExisting: true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, _ := fs.ReadFile("user.go")
+		if string(again) != want {
+			t.Errorf("Preamble changed on second run:\n%s", again)
+		}
+	})
+
+	t.Run("OwnedFiles", func(t *testing.T) {
+		fs := gogotest.New(`# generated.go
+// Code generated by modelgen. DO NOT EDIT.
+
+package models
+
+type Generated struct{}
+
+# manual.go
+package models
+
+type Manual struct{}
+`)
+
+		var asked []string
+		project, err := gogo.New(gogo.Options{
+			FS: fs,
+			ConflictFunc: gogo.AcceptOwned(func(_ gogofs.FS, _, _ string, info gogo.ChangeInfo) bool {
+				asked = append(asked, info.FileName)
+				return false
+			}),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, filename := range []string{"generated.go", "manual.go"} {
+			err = project.Constant(gogo.ConstantOpts{
+				Filename:  filename,
+				Constants: []gogo.Constant{{Name: "Version", Value: "1"}},
+			})
+			if rejected := errors.Is(err, gogo.ErrRejected); rejected != (filename == "manual.go") || err != nil && !rejected {
+				t.Fatalf("Unexpected error for %s: %v", filename, err)
+			}
+		}
+
+		if len(asked) != 1 || asked[0] != "manual.go" {
+			t.Errorf("Expected to be asked only about manual.go, got %v", asked)
+		}
+
+		generated, _ := fs.ReadFile("generated.go")
+		if !strings.Contains(string(generated), "const Version = 1") {
+			t.Errorf("Owned file should be updated without asking:\n%s", generated)
+		}
+		manual, _ := fs.ReadFile("manual.go")
+		if strings.Contains(string(manual), "Version") {
+			t.Errorf("Rejected change should not be applied:\n%s", manual)
+		}
+	})
+}
@@ -0,0 +1,426 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+// userSpec returns a file spec for a small User model
+func userSpec(prune bool) gogo.FileSpec {
+	return gogo.FileSpec{
+		Path: "user.go",
+		Structs: []gogo.StructOpts{{
+			Name: "User",
+			Fields: []gogo.StructField{
+				{Name: "ID", Type: "int"},
+				{Name: "Email", Type: "string"},
+			},
+		}},
+		Methods: []gogo.MethodOpts{{
+			Name:         "Validate",
+			ReceiverName: "u",
+			ReceiverType: "*User",
+			ReturnType:   "error",
+			Body:         "if u.Email == \"\" {\n\treturn errors.New(\"email is required\")\n}\nreturn nil",
+		}},
+		Functions: []gogo.FunctionOpts{{
+			Name:       "NewUser",
+			Parameters: []gogo.Parameter{{Name: "email", Type: "string"}},
+			ReturnType: "*User",
+			Body:       "return &User{Email: email}",
+		}},
+		Types:   []gogo.TypeDef{{Name: "UserID", Definition: "int"}},
+		Vars:    []gogo.Variable{{Name: "DefaultUser", Value: "User{}"}},
+		Consts:  []gogo.Constant{{Name: "MaxUsers", Value: "100"}},
+		Imports: []string{"errors"},
+		Prune:   prune,
+	}
+}
+
+func TestProjectFile(t *testing.T) {
+	t.Run("CreateFileInOnePass", func(t *testing.T) {
+		fs := gogotest.New("")
+
+		var changes []gogo.ChangeInfo
+		project, err := gogo.New(gogo.Options{
+			FS:                 fs,
+			InitialPackageName: "models",
+			ConflictFunc: func(_ gogofs.FS, _, _ string, info gogo.ChangeInfo) bool {
+				changes = append(changes, info)
+				return true
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := project.File(userSpec(false)); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(changes) != 1 || changes[0].Action != "create" {
+			t.Fatalf("Expected a single create change, got %d", len(changes))
+		}
+
+		content, _ := fs.ReadFile("user.go")
+		want := `package models
+
+import (
+	"errors"
+)
+
+type User struct {
+	ID    int
+	Email string
+}
+
+type UserID int
+
+const MaxUsers = 100
+
+var DefaultUser = User{}
+
+func NewUser(email string) *User {
+	return &User{Email: email}
+}
+
+func (u *User) Validate() error {
+	if u.Email == "" {
+		return errors.New("email is required")
+	}
+	return nil
+}
+`
+		if string(content) != want {
+			t.Errorf("Unexpected content:\n%s\nwant:\n%s", content, want)
+		}
+
+		// Reconciling again changes nothing
+		if err := project.File(userSpec(false)); err != nil {
+			t.Fatal(err)
+		}
+		if len(changes) != 1 {
+			t.Errorf("Expected no change on the second run, got %d changes", len(changes))
+		}
+	})
+
+	t.Run("ReconcileExistingFile", func(t *testing.T) {
+		fs := gogotest.New(`# user.go
+package models
+
+import "fmt"
+
+// User is a registered user
+type User struct {
+	ID   int // Primary key
+	Name string
+}
+
+// Validate checks the user
+func (u *User) Validate() error {
+	return fmt.Errorf("not implemented")
+}
+
+// Custom is written by hand
+func Custom() {}
+
+const MaxUsers = 10
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		spec := userSpec(true)
+		spec.Structs[0].PreserveExisting = true
+		if err := project.File(spec); err != nil {
+			t.Fatal(err)
+		}
+
+		content, _ := fs.ReadFile("user.go")
+		for _, want := range []string{
+			"// User is a registered user",
+			"ID    int // Primary key",
+			"Email string",
+			"// Validate checks the user",
+			`return errors.New("email is required")`,
+			"// Custom is written by hand",
+			"const MaxUsers = 100",
+			`"fmt"`,
+		} {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("Expected %q in:\n%s", want, content)
+			}
+		}
+		if strings.Count(string(content), "func (u *User) Validate()") != 1 {
+			t.Errorf("Validate should be replaced, not duplicated:\n%s", content)
+		}
+	})
+
+	t.Run("PruneOwnedFile", func(t *testing.T) {
+		fs := gogotest.New(`# user.go
+// Code generated by modelgen. DO NOT EDIT.
+
+package models
+
+import (
+	"errors"
+	"strings"
+)
+
+type User struct {
+	ID    int
+	Email string
+}
+
+type Legacy struct{}
+
+func Normalize(s string) string {
+	return strings.ToLower(s)
+}
+
+const (
+	MaxUsers = 100
+	MinUsers = 1
+)
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := project.File(userSpec(true)); err != nil {
+			t.Fatal(err)
+		}
+
+		content, _ := fs.ReadFile("user.go")
+		for _, gone := range []string{"Legacy", "Normalize", "MinUsers", `"strings"`} {
+			if strings.Contains(string(content), gone) {
+				t.Errorf("Expected %s to be pruned:\n%s", gone, content)
+			}
+		}
+		for _, kept := range []string{"MaxUsers = 100", "func NewUser", `"errors"`} {
+			if !strings.Contains(string(content), kept) {
+				t.Errorf("Expected %s to be kept:\n%s", kept, content)
+			}
+		}
+	})
+
+	t.Run("PruneImportsByPackageName", func(t *testing.T) {
+		fs := gogotest.New(`# go.mod
+module example.com/app
+# internal/api-v1/api.go
+package apiv1
+
+type Request struct{}
+# internal/store/store.go
+package db
+
+type Store struct{}
+# models/user.go
+// Code generated by modelgen. DO NOT EDIT.
+
+package models
+
+import (
+	"strings"
+
+	"example.com/app/internal/api-v1"
+	"example.com/app/internal/store"
+	"github.com/google/uuid"
+	yaml "gopkg.in/yaml.v3"
+)
+
+type User struct {
+	Request apiv1.Request
+}
+
+type Legacy struct {
+	Store db.Store
+	Node  yaml.Node
+}
+
+func Normalize(s string) string {
+	return strings.ToLower(s)
+}
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		spec := gogo.FileSpec{Path: "models/user.go", Prune: true, Structs: []gogo.StructOpts{{
+			Name:   "User",
+			Fields: []gogo.StructField{{Name: "Request", Type: "apiv1.Request"}},
+		}}}
+		if err := project.File(spec); err != nil {
+			t.Fatal(err)
+		}
+
+		// Imports are matched by the name of their package, not their path.
+		// uuid can't be resolved, so it is kept.
+		content, _ := fs.ReadFile("models/user.go")
+		for _, gone := range []string{`"strings"`, `"example.com/app/internal/store"`, `"gopkg.in/yaml.v3"`} {
+			if strings.Contains(string(content), gone) {
+				t.Errorf("Expected %s to be removed:\n%s", gone, content)
+			}
+		}
+		for _, kept := range []string{`"example.com/app/internal/api-v1"`, `"github.com/google/uuid"`} {
+			if !strings.Contains(string(content), kept) {
+				t.Errorf("Expected %s to be kept:\n%s", kept, content)
+			}
+		}
+	})
+
+	t.Run("PruneIgnoresHandwrittenFile", func(t *testing.T) {
+		fs := gogotest.New(`# user.go
+package models
+
+func Custom() {}
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := project.File(userSpec(true)); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := fs.Assert("func Custom() {}"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("Package", func(t *testing.T) {
+		fs := gogotest.New(`# api/api.go
+package api
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, InitialPackageName: "models", ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Package names new files, existing files keep theirs
+		spec := gogo.FileSpec{Path: "api/ids.go", Package: "api", Types: []gogo.TypeDef{{Name: "ID", Definition: "int64"}}}
+		if err := project.File(spec); err != nil {
+			t.Fatal(err)
+		}
+		spec.Path = "api/api.go"
+		spec.Package = "other"
+		if err := project.File(spec); err != nil {
+			t.Fatal(err)
+		}
+		for _, file := range []string{"api/ids.go", "api/api.go"} {
+			if content, _ := fs.ReadFile(file); !strings.HasPrefix(string(content), "package api\n") {
+				t.Errorf("Expected package api in %s, got:\n%s", file, content)
+			}
+		}
+
+		// Without Package, new files use InitialPackageName
+		if err := project.File(gogo.FileSpec{Path: "models/user.go", Types: []gogo.TypeDef{{Name: "Name", Definition: "string"}}}); err != nil {
+			t.Fatal(err)
+		}
+		if content, _ := fs.ReadFile("models/user.go"); !strings.HasPrefix(string(content), "package models\n") {
+			t.Errorf("Expected package models, got:\n%s", content)
+		}
+	})
+
+	t.Run("DuplicateDeclarations", func(t *testing.T) {
+		fs := gogotest.New(`# ids.go
+package models
+
+type ID int
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Two declarations of the same name fail instead of one silently
+		// winning, in new and existing files alike
+		types := []gogo.TypeDef{{Name: "ID", Definition: "int64"}, {Name: "ID", Definition: "string"}}
+		for _, path := range []string{"ids.go", "new.go"} {
+			err := project.File(gogo.FileSpec{Path: path, Types: types})
+			if !errors.Is(err, gogo.ErrInvalidOptions) || !strings.Contains(err.Error(), "duplicate declaration type ID") {
+				t.Errorf("Expected a duplicate declaration in %s, got %v", path, err)
+			}
+		}
+		if err := fs.Assert("type ID int\n"); err != nil {
+			t.Error(err)
+		}
+		if files := fs.GetFiles(); len(files) != 1 {
+			t.Errorf("Expected no new file, got:\n%s", fs)
+		}
+	})
+
+	t.Run("GroupedTypes", func(t *testing.T) {
+		fs := gogotest.New(`# models.go
+package models
+
+type (
+	// User is a user
+	User struct {
+		ID int
+	}
+	ID int
+)
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// A struct and another type of the same block change together
+		err = project.File(gogo.FileSpec{
+			Path:    "models.go",
+			Structs: []gogo.StructOpts{{Name: "User", Fields: []gogo.StructField{{Name: "Name", Type: "string"}}, PreserveExisting: true}},
+			Types:   []gogo.TypeDef{{Name: "ID", Definition: "int64"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Assert(`type (
+	// User is a user
+	User struct {
+		ID   int
+		Name string
+	}
+	ID int64
+)`); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("ValidationErrors", func(t *testing.T) {
+		fs := gogotest.New("")
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Missing path
+		spec := userSpec(false)
+		spec.Path = ""
+		if err := project.File(spec); err == nil {
+			t.Error("Expected error for missing path")
+		}
+
+		// Invalid method
+		spec = userSpec(false)
+		spec.Methods[0].ReceiverType = ""
+		if err := project.File(spec); err == nil {
+			t.Error("Expected error for method without receiver")
+		}
+
+		// Nothing is written when the spec is invalid
+		if len(fs.GetFiles()) != 0 {
+			t.Errorf("Expected no files, got %d", len(fs.GetFiles()))
+		}
+	})
+}
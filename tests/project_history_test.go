@@ -0,0 +1,128 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+const historyUser = `package models
+
+type User struct {
+	ID int
+}
+`
+
+// historyRun generates two files in a new project with history
+func historyRun(t *testing.T, fs gogofs.FS, history int) *gogo.Project {
+	t.Helper()
+
+	project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept, History: history})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := project.Struct(gogo.StructOpts{Filename: "user.go", Name: "User", Fields: []gogo.StructField{{Name: "ID", Type: "int64"}}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := project.Method(gogo.MethodOpts{Filename: "user.go", Name: "Key", ReceiverName: "u", ReceiverType: "User", ReturnType: "int64", Body: "return u.ID"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := project.Type(gogo.TypeOpts{Filename: "ids.go", Types: []gogo.TypeDef{{Name: "ID", Definition: "int64"}}}); err != nil {
+		t.Fatal(err)
+	}
+	return project
+}
+
+// historyFiles returns the runs recorded in the history
+func historyFiles(fs gogofs.FS) []string {
+	entries, _ := fs.ReadDir(".gogo/history")
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+func TestProjectUndo(t *testing.T) {
+	fs := gogotest.New("# user.go\n" + historyUser)
+	project := historyRun(t, fs, 5)
+
+	if err := project.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := fs.ReadFile("user.go"); string(content) != historyUser {
+		t.Errorf("Expected user.go restored, got:\n%s", content)
+	}
+	if _, err := fs.Stat("ids.go"); err == nil {
+		t.Error("Expected ids.go removed")
+	}
+	for name := range fs.GetFiles() {
+		if strings.HasPrefix(name, ".gogo") && !strings.HasPrefix(name, ".gogo/history") {
+			t.Errorf("Leftover file %s", name)
+		}
+	}
+	if runs := historyFiles(fs); len(runs) != 0 {
+		t.Errorf("Expected the run removed from the history, got %v", runs)
+	}
+
+	if err := project.Undo(); !errors.Is(err, gogo.ErrNotFound) {
+		t.Errorf("Expected ErrNotFound with no runs left, got %v", err)
+	}
+}
+
+func TestProjectUndoEdited(t *testing.T) {
+	fs := gogotest.New("# user.go\n" + historyUser)
+	project := historyRun(t, fs, 5)
+
+	edited := []byte("package models\n\n// Edited by hand\ntype ID int64\n")
+	fs.WriteFile("ids.go", edited, 0644)
+
+	if err := project.Undo(); !errors.Is(err, gogo.ErrEdited) || !strings.Contains(err.Error(), "ids.go") {
+		t.Fatalf("Expected ErrEdited for ids.go, got %v", err)
+	}
+	// Nothing was reverted
+	if content, _ := fs.ReadFile("user.go"); !strings.Contains(string(content), "ID int64") {
+		t.Errorf("Expected user.go untouched, got:\n%s", content)
+	}
+	if content, _ := fs.ReadFile("ids.go"); string(content) != string(edited) {
+		t.Errorf("Expected ids.go untouched, got:\n%s", content)
+	}
+}
+
+func TestProjectHistoryRetention(t *testing.T) {
+	fs := gogotest.New("# user.go\n" + historyUser)
+	historyRun(t, fs, 2)
+	for i := 0; i < 3; i++ {
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept, History: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		name := strings.Repeat("A", i+1)
+		if err := project.Constant(gogo.ConstantOpts{Filename: "consts.go", Constants: []gogo.Constant{{Name: name, Value: "1"}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if runs := historyFiles(fs); len(runs) != 2 {
+		t.Fatalf("Expected 2 runs kept, got %v", runs)
+	}
+
+	// Runs are undone last first
+	project, _ := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+	if err := project.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if content, _ := fs.ReadFile("consts.go"); strings.Contains(string(content), "AAA") || !strings.Contains(string(content), "AA") {
+		t.Errorf("Expected the last constant reverted, got:\n%s", content)
+	}
+
+	// Without history nothing is recorded
+	fs = gogotest.New("# user.go\n" + historyUser)
+	historyRun(t, fs, 0)
+	if runs := historyFiles(fs); len(runs) != 0 {
+		t.Errorf("Expected no history, got %v", runs)
+	}
+}
@@ -0,0 +1,195 @@
+package tests
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+func TestProjectLock(t *testing.T) {
+	t.Run("WaitsForOtherWriters", func(t *testing.T) {
+		fs := gogotest.New("")
+		project, err := gogo.New(gogo.Options{
+			FS:           fs,
+			ConflictFunc: gogo.ConflictAccept,
+			LockTimeout:  20 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Another generator is writing
+		unlock, err := fs.Lock(gogofs.LockFile, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = generateModel(project, "user.go", "User")
+		if !errors.Is(err, gogofs.ErrLocked) {
+			t.Fatalf("Expected ErrLocked, got %v", err)
+		}
+		if len(fs.GetFiles()) != 0 {
+			t.Error("Nothing should be written without the lock")
+		}
+
+		// Once it is done, the project writes
+		unlock()
+		if err := generateModel(project, "user.go", "User"); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Assert("type User struct"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("FlushHoldsTheLock", func(t *testing.T) {
+		fs := gogotest.New("")
+		project, err := gogo.New(gogo.Options{
+			FS:       fs,
+			Buffered: true,
+			ConflictFunc: func(_ gogofs.FS, filename, _ string, _ gogo.ChangeInfo) bool {
+				if _, err := fs.Lock(gogofs.LockFile, 0); !errors.Is(err, gogofs.ErrLocked) {
+					t.Errorf("Lock should be held while writing %s, got %v", filename, err)
+				}
+				return true
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, filename := range []string{"a.go", "b.go"} {
+			if err := generateModel(project, filename, "Model"); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := project.Flush(); err != nil {
+			t.Fatal(err)
+		}
+
+		// Released after the flush
+		unlock, err := fs.Lock(gogofs.LockFile, 0)
+		if err != nil {
+			t.Fatalf("Lock should be released after Flush: %v", err)
+		}
+		unlock()
+	})
+
+	t.Run("OtherProcess", func(t *testing.T) {
+		dir := t.TempDir()
+
+		// Start a process that holds the lock until its stdin is closed
+		helper := exec.Command(os.Args[0], "-test.run=^TestLockHelperProcess$")
+		helper.Env = append(os.Environ(), "GOGO_LOCK_HELPER="+dir)
+		stdin, err := helper.StdinPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdout, err := helper.StdoutPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := helper.Start(); err != nil {
+			t.Fatal(err)
+		}
+		defer helper.Process.Kill()
+
+		if line, _ := bufio.NewReader(stdout).ReadString('\n'); line != "locked\n" {
+			t.Fatalf("Helper failed to take the lock: %q", line)
+		}
+
+		project, err := gogo.NewFS(dir, gogo.Options{
+			ConflictFunc: gogo.ConflictAccept,
+			LockTimeout:  100 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = generateModel(project, "user.go", "User")
+		if !errors.Is(err, gogofs.ErrLocked) {
+			t.Fatalf("Expected ErrLocked, got %v", err)
+		}
+
+		stdin.Close()
+		if err := helper.Wait(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, gogofs.LockFile)); !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("Expected the lock file removed on unlock, got %v", err)
+		}
+
+		if err := generateModel(project, "user.go", "User"); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("StaleLock", func(t *testing.T) {
+		dir := t.TempDir()
+
+		helper := exec.Command(os.Args[0], "-test.run=^TestLockHelperProcess$")
+		helper.Env = append(os.Environ(), "GOGO_LOCK_HELPER="+dir)
+		if _, err := helper.StdinPipe(); err != nil { // Never closed, the helper would wait forever
+			t.Fatal(err)
+		}
+		stdout, err := helper.StdoutPipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := helper.Start(); err != nil {
+			t.Fatal(err)
+		}
+		if line, _ := bufio.NewReader(stdout).ReadString('\n'); line != "locked\n" {
+			t.Fatalf("Helper failed to take the lock: %q", line)
+		}
+
+		// The holder dies without releasing the lock
+		helper.Process.Kill()
+		helper.Wait()
+		if _, err := os.Stat(filepath.Join(dir, gogofs.LockFile)); err != nil {
+			t.Fatalf("Expected a lock file left behind: %v", err)
+		}
+
+		project, err := gogo.NewFS(dir, gogo.Options{
+			ConflictFunc: gogo.ConflictAccept,
+			LockTimeout:  time.Second,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := generateModel(project, "user.go", "User"); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
+
+// TestLockHelperProcess is not a real test. It is run as a separate process
+// by TestProjectLock to hold the lock of a directory until stdin is closed.
+func TestLockHelperProcess(t *testing.T) {
+	dir := os.Getenv("GOGO_LOCK_HELPER")
+	if dir == "" {
+		t.Skip("helper process")
+	}
+
+	filesystem, err := gogo.OpenFS(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := filesystem.(gogofs.Locker).Lock(gogofs.LockFile, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout.WriteString("locked\n")
+
+	io.Copy(io.Discard, os.Stdin)
+	unlock()
+}
@@ -0,0 +1,272 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+func TestProjectModule(t *testing.T) {
+	t.Run("ModulePath", func(t *testing.T) {
+		fs := gogotest.New(`# go.mod
+module example.com/app // the app
+
+go 1.24
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		modulePath, err := project.ModulePath()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if modulePath != "example.com/app" {
+			t.Errorf("Expected module path example.com/app, got %s", modulePath)
+		}
+	})
+
+	t.Run("ImportPathFor", func(t *testing.T) {
+		fs := gogotest.New(`# go.mod
+module example.com/app
+
+# tools/go.mod
+module "example.com/tools"
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		tests := map[string]string{
+			".":                  "example.com/app",
+			"internal/models":    "example.com/app/internal/models",
+			"./internal/api/":    "example.com/app/internal/api",
+			"tools":              "example.com/tools",
+			"tools/cmd/generate": "example.com/tools/cmd/generate",
+		}
+		for dir, want := range tests {
+			got, err := project.ImportPathFor(dir)
+			if err != nil {
+				t.Fatalf("ImportPathFor(%q): %v", dir, err)
+			}
+			if got != want {
+				t.Errorf("ImportPathFor(%q) = %s, want %s", dir, got, want)
+			}
+		}
+
+		if _, err := project.ImportPathFor("../outside"); err == nil {
+			t.Error("Expected error for directory outside the project")
+		}
+	})
+
+	t.Run("Workspace", func(t *testing.T) {
+		fs := gogotest.New(`# go.work
+go 1.24
+
+use (
+	./api // the service
+	"./lib"
+)
+
+# api/go.mod
+module example.com/api
+
+# lib/go.mod
+module example.com/lib
+
+# scratch/go.mod
+module example.com/scratch
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := project.ImportPathFor("lib/strutil")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != "example.com/lib/strutil" {
+			t.Errorf("Expected example.com/lib/strutil, got %s", got)
+		}
+
+		if _, err := project.ImportPathFor("scratch"); err == nil {
+			t.Error("Expected error for module not used by go.work")
+		}
+
+		if _, err := project.ModulePath(); err == nil {
+			t.Error("Expected error for workspace with several modules")
+		}
+	})
+
+	t.Run("MissingGoMod", func(t *testing.T) {
+		fs := gogotest.New("")
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := project.ModulePath(); err == nil {
+			t.Error("Expected error without go.mod")
+		}
+		if _, err := project.ImportPathFor("models"); err == nil {
+			t.Error("Expected error without go.mod")
+		}
+	})
+
+	t.Run("LocalPackageTypes", func(t *testing.T) {
+		fs := gogotest.New(`# go.mod
+module example.com/app
+`)
+		project, err := gogo.New(gogo.Options{
+			FS:                 fs,
+			ConflictFunc:       gogo.ConflictAccept,
+			InitialPackageName: "api",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Struct(gogo.StructOpts{
+			Filename: "internal/api/handler.go",
+			Name:     "UserHandler",
+			Fields: []gogo.StructField{
+				{Name: "Users", Type: "map[string]*./internal/models.User"},
+				{Name: "Decoder", Type: "*encoding/json.Decoder"},
+				{Name: "Next", Type: "*./internal/api.UserHandler"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, err := fs.ReadFile("internal/api/handler.go")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range []string{
+			`"encoding/json"`,
+			`"example.com/app/internal/models"`,
+			"Users   map[string]*models.User",
+			"Decoder *json.Decoder",
+			"Next    *UserHandler",
+		} {
+			if !strings.Contains(string(content), want) {
+				t.Errorf("Expected %q in:\n%s", want, content)
+			}
+		}
+		if strings.Contains(string(content), `"example.com/app/internal/api"`) {
+			t.Errorf("File should not import its own package:\n%s", content)
+		}
+
+		// Add a function to the same file, which already has an import block
+		err = project.Function(gogo.FunctionOpts{
+			Filename:   "internal/api/handler.go",
+			Name:       "NewUserHandler",
+			Parameters: []gogo.Parameter{{Name: "db", Type: "*database/sql.DB"}},
+			ReturnType: "*./internal/api.UserHandler",
+			Body:       "return &UserHandler{}",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := fs.Assert(`"database/sql"`); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Assert(`func NewUserHandler(db *sql.DB) *UserHandler`); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("VersionedImportPaths", func(t *testing.T) {
+		fs := gogotest.New(`# types.go
+package types
+
+import "fmt"
+
+var _ = fmt.Sprint
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Type(gogo.TypeOpts{
+			Filename: "types.go",
+			Types: []gogo.TypeDef{
+				{Name: "Node", Definition: "gopkg.in/yaml.v3.Node"},
+				{Name: "Client", Definition: "github.com/redis/go-redis/v9.Client"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The names are guesses, so the imports are named
+		for _, want := range []string{
+			`"fmt"`,
+			`yaml "gopkg.in/yaml.v3"`,
+			`redis "github.com/redis/go-redis/v9"`,
+			`type Node yaml.Node`,
+			`type Client redis.Client`,
+		} {
+			if err := fs.Assert(want); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+
+	t.Run("PackageNames", func(t *testing.T) {
+		fs := gogotest.New(`# go.mod
+module example.com/app
+# internal/api-v1/api.go
+package apiv1
+# internal/util/util.go
+package util
+# internal/core/core.go
+package core
+`)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept, InitialPackageName: "handlers"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Type(gogo.TypeOpts{
+			Filename: "handlers/types.go",
+			Types: []gogo.TypeDef{
+				{Name: "Request", Definition: "./internal/api-v1.Request"},
+				{Name: "Helper", Definition: "example.com/app/internal/util.Helper"},
+				{Name: "Other", Definition: "example.com/lib/util.Helper"},
+				{Name: "Config", Definition: "./internal/core.Config"},
+				{Name: "Pod", Definition: "k8s.io/api/core/v1.Pod"},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Project packages use their package clause, others a named import,
+		// and names used twice get a number
+		for _, want := range []string{
+			`apiv1 "example.com/app/internal/api-v1"`,
+			"\t\"example.com/app/internal/util\"",
+			`util2 "example.com/lib/util"`,
+			"\t\"example.com/app/internal/core\"",
+			`core2 "k8s.io/api/core/v1"`,
+			"type Request apiv1.Request",
+			"type Helper util.Helper",
+			"type Other util2.Helper",
+			"type Config core.Config",
+			"type Pod core2.Pod",
+		} {
+			if err := fs.Assert(want); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
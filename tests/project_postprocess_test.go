@@ -0,0 +1,179 @@
+package tests
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+func TestProjectPostProcessors(t *testing.T) {
+	t.Run("RunInOrder", func(t *testing.T) {
+		fs := gogotest.New("")
+
+		var calls []string
+		project, err := gogo.New(gogo.Options{
+			FS:                 fs,
+			ConflictFunc:       gogo.ConflictAccept,
+			InitialPackageName: "models",
+			PostProcessors: []gogo.PostProcessor{
+				func(filename string, src []byte) ([]byte, error) {
+					calls = append(calls, "first "+filename)
+					return []byte(strings.Replace(string(src), "package models", "// House rule\npackage models", 1)), nil
+				},
+				func(filename string, src []byte) ([]byte, error) {
+					calls = append(calls, "second "+filename)
+					if !strings.Contains(string(src), "// House rule") {
+						t.Error("Processors should run on the output of the previous one")
+					}
+					return src, nil
+				},
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Constant(gogo.ConstantOpts{Filename: "version.go", Constants: []gogo.Constant{{Name: "Version", Value: "1"}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Join(calls, ",") != "first version.go,second version.go" {
+			t.Errorf("Unexpected calls: %v", calls)
+		}
+		if err := fs.Assert("// House rule\npackage models"); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		errHouseRule := errors.New("house rule violated")
+		for name, processor := range map[string]gogo.PostProcessor{
+			"Failure": func(string, []byte) ([]byte, error) { return nil, errHouseRule },
+			"InvalidGo": func(string, []byte) ([]byte, error) {
+				return []byte("package models\n\nfunc {"), nil
+			},
+		} {
+			fs := gogotest.New("")
+			project, err := gogo.New(gogo.Options{
+				FS:             fs,
+				ConflictFunc:   gogo.ConflictAccept,
+				PostProcessors: []gogo.PostProcessor{processor},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = project.Constant(gogo.ConstantOpts{Filename: "version.go", Constants: []gogo.Constant{{Name: "Version", Value: "1"}}})
+			if err == nil || !strings.Contains(err.Error(), "version.go") {
+				t.Errorf("%s: expected an error naming the file, got %v", name, err)
+			}
+			if name == "Failure" && !errors.Is(err, errHouseRule) {
+				t.Errorf("%s: expected the processor error to be wrapped, got %v", name, err)
+			}
+			if len(fs.GetFiles()) != 0 {
+				t.Errorf("%s: nothing should be written", name)
+			}
+		}
+	})
+
+	t.Run("GroupImports", func(t *testing.T) {
+		fs := gogotest.New(`# handler.go
+package api
+
+import (
+	"example.com/app/models"
+
+	// Logging for the handlers
+	"log/slog"
+
+	"github.com/google/uuid"
+	"fmt" // Errors
+)
+
+var (
+	_ = models.User{}
+	_ = uuid.New
+	_ = slog.Info
+	_ = fmt.Errorf
+)
+`)
+		project, err := gogo.New(gogo.Options{
+			FS:             fs,
+			ConflictFunc:   gogo.ConflictAccept,
+			PostProcessors: []gogo.PostProcessor{gogo.GroupImports("example.com/app")},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Struct(gogo.StructOpts{
+			Filename: "handler.go",
+			Name:     "Handler",
+			Fields:   []gogo.StructField{{Name: "DB", Type: "*database/sql.DB"}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		content, _ := fs.ReadFile("handler.go")
+		want := `import (
+	"database/sql"
+	"fmt" // Errors
+	// Logging for the handlers
+	"log/slog"
+
+	"github.com/google/uuid"
+
+	"example.com/app/models"
+)`
+		if !strings.Contains(string(content), want) {
+			t.Errorf("Imports not grouped:\n%s", content)
+		}
+	})
+
+	t.Run("Simplify", func(t *testing.T) {
+		fs := gogotest.New("")
+		project, err := gogo.New(gogo.Options{
+			FS:                 fs,
+			ConflictFunc:       gogo.ConflictAccept,
+			InitialPackageName: "models",
+			PostProcessors:     []gogo.PostProcessor{gogo.Simplify},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Function(gogo.FunctionOpts{
+			Filename: "points.go",
+			Name:     "Points",
+			Body: `points := []Point{Point{1, 2}, Point{3, 4}}
+refs := map[string]*Point{"a": &Point{5, 6}}
+for i, _ := range points {
+	points[i].X++
+}
+for _ = range refs {
+}
+return points[1:len(points)]`,
+			ReturnType: "[]Point",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, want := range []string{
+			"points := []Point{{1, 2}, {3, 4}}",
+			`refs := map[string]*Point{"a": {5, 6}}`,
+			"for i := range points {",
+			"for range refs {",
+			"return points[1:]",
+		} {
+			if err := fs.Assert(want); err != nil {
+				t.Error(err)
+			}
+		}
+	})
+}
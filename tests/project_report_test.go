@@ -0,0 +1,139 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+// reportProject runs a small generation with a file of each status and
+// returns its report
+func reportProject(t *testing.T) gogo.Report {
+	t.Helper()
+
+	fs := gogotest.New(`# manual.go
+package models
+# user.go
+package models
+
+type User struct {
+	ID int
+}
+
+const Version = "1"
+`)
+	project, err := gogo.New(gogo.Options{
+		FS:                 fs,
+		InitialPackageName: "models",
+		ConflictFunc: func(_ gogofs.FS, _, _ string, info gogo.ChangeInfo) bool {
+			return info.FileName != "manual.go"
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	project.Struct(gogo.StructOpts{Filename: "user.go", Name: "User", Fields: []gogo.StructField{{Name: "ID", Type: "int64"}}})
+	project.Method(gogo.MethodOpts{Filename: "user.go", Name: "Key", ReceiverName: "u", ReceiverType: "*User", ReturnType: "int64", Body: "return u.ID"})
+	project.Constant(gogo.ConstantOpts{Filename: "user.go", Constants: []gogo.Constant{{Name: "Version", Value: `"1"`}}})
+	project.Type(gogo.TypeOpts{Filename: "ids.go", Types: []gogo.TypeDef{{Name: "ID", Definition: "int64"}}})
+	project.Constant(gogo.ConstantOpts{Filename: "manual.go", Constants: []gogo.Constant{{Name: "Version", Value: `"1"`}}})
+	project.Function(gogo.FunctionOpts{Filename: "broken.go", Name: "Broken", Body: "return {"})
+
+	return project.Report()
+}
+
+func TestProjectReport(t *testing.T) {
+	report := reportProject(t)
+
+	want := []gogo.FileReport{
+		{File: "broken.go", Status: gogo.StatusFailed, Declarations: []string{"function Broken"}},
+		{File: "ids.go", Status: gogo.StatusCreated, Declarations: []string{"type ID"}, Added: 3},
+		{File: "manual.go", Status: gogo.StatusRejected, Declarations: []string{"constant Version"}},
+		{File: "user.go", Status: gogo.StatusModified, Declarations: []string{"struct User", "method User.Key"}, Added: 5, Removed: 1},
+	}
+	if len(report.Files) != len(want) {
+		t.Fatalf("Expected %d files, got %+v", len(want), report.Files)
+	}
+	for i, file := range report.Files {
+		file.Error = ""
+		if strings.Join(file.Declarations, ",") != strings.Join(want[i].Declarations, ",") {
+			t.Errorf("Expected declarations %v for %s, got %v", want[i].Declarations, file.File, file.Declarations)
+		}
+		file.Declarations, want[i].Declarations = nil, nil
+		if file.File != want[i].File || file.Status != want[i].Status || file.Added != want[i].Added || file.Removed != want[i].Removed {
+			t.Errorf("Expected %+v, got %+v", want[i], file)
+		}
+	}
+	if report.Files[0].Error == "" {
+		t.Error("Expected the error of the failed file")
+	}
+
+	t.Run("Text", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := report.WriteText(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		text := buf.String()
+		for _, want := range []string{
+			"created   ids.go     +3 -0  type ID\n",
+			"modified  user.go    +5 -1  struct User, method User.Key\n",
+			"4 files: 1 created, 1 modified, 1 rejected, 1 failed\n",
+		} {
+			if !strings.Contains(text, want) {
+				t.Errorf("Expected %q in:\n%s", want, text)
+			}
+		}
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := report.WriteJSON(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), `"status": "rejected"`) {
+			t.Errorf("Expected statuses by name:\n%s", buf.String())
+		}
+
+		var decoded gogo.Report
+		if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+			t.Fatal(err)
+		}
+		if len(decoded.Files) != 4 || decoded.Files[3].Status != gogo.StatusModified || decoded.Files[3].Added != 5 {
+			t.Errorf("Report doesn't round-trip: %+v", decoded)
+		}
+	})
+
+	t.Run("JUnit", func(t *testing.T) {
+		var buf bytes.Buffer
+		if err := report.WriteJUnit(&buf); err != nil {
+			t.Fatal(err)
+		}
+
+		var suite struct {
+			Tests    int `xml:"tests,attr"`
+			Failures int `xml:"failures,attr"`
+			Skipped  int `xml:"skipped,attr"`
+			Cases    []struct {
+				Name    string    `xml:"name,attr"`
+				Failure *struct{} `xml:"failure"`
+			} `xml:"testcase"`
+		}
+		if err := xml.Unmarshal(buf.Bytes(), &suite); err != nil {
+			t.Fatalf("Invalid XML: %v\n%s", err, buf.String())
+		}
+		if suite.Tests != 4 || suite.Failures != 1 || suite.Skipped != 1 {
+			t.Errorf("Unexpected counts:\n%s", buf.String())
+		}
+		if suite.Cases[0].Name != "broken.go" || suite.Cases[0].Failure == nil {
+			t.Errorf("Expected broken.go to fail:\n%s", buf.String())
+		}
+	})
+}
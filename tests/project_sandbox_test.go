@@ -0,0 +1,68 @@
+package tests
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+func TestProjectSandbox(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "project")
+	outside := filepath.Join(parent, "outside")
+	os.MkdirAll(filepath.Join(root, "models"), 0755)
+	os.MkdirAll(outside, 0755)
+	if err := os.Symlink(outside, filepath.Join(root, "escape")); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	os.Symlink(filepath.Join("..", "..", "outside", "x.go"), filepath.Join(root, "models", "dangling.go"))
+	os.Symlink("models", filepath.Join(root, "alias"))
+
+	real, err := gogo.OpenFS(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, fs := range map[string]gogofs.FS{"Real": real, "Mock": gogotest.New("")} {
+		t.Run(name, func(t *testing.T) {
+			project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept, InitialPackageName: "models"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			filenames := []string{"../outside/x.go", "models/../../outside/x.go", filepath.Join(outside, "x.go")}
+			if name == "Real" {
+				filenames = append(filenames, "escape/x.go", "models/dangling.go")
+			}
+			for _, filename := range filenames {
+				err := project.Type(gogo.TypeOpts{Filename: filename, Types: []gogo.TypeDef{{Name: "ID", Definition: "int"}}})
+				var escape *gogofs.PathEscapeError
+				if !errors.As(err, &escape) {
+					t.Errorf("Expected a PathEscapeError for %s, got %v", filename, err)
+				}
+			}
+			if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+				t.Errorf("Wrote outside the root: %v", entries)
+			}
+
+			// Paths that stay inside are fine
+			if err := project.Type(gogo.TypeOpts{Filename: "models/../ids.go", Types: []gogo.TypeDef{{Name: "ID", Definition: "int"}}}); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+
+	t.Run("SymlinkInside", func(t *testing.T) {
+		if err := real.WriteFile("alias/user.go", []byte("package models\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(filepath.Join(root, "models", "user.go")); err != nil {
+			t.Error(err)
+		}
+	})
+}
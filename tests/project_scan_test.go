@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+const scanTree = `# go.mod
+module example.com/app
+# main.go
+package main
+# models/user.go
+package models
+# models/user_gen.go
+package models
+# models/testdata/fixture.go
+package fixture
+# vendor/github.com/google/uuid/uuid.go
+package uuid
+# .git/hooks/hook.go
+package hooks
+`
+
+// scanFilesystems returns the scan tree on the mock and on the real
+// filesystem
+func scanFilesystems(t *testing.T) map[string]gogofs.FS {
+	t.Helper()
+
+	mock := gogotest.New(scanTree)
+	dir := t.TempDir()
+	for name, content := range mock.GetFiles() {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	real, err := gogo.OpenFS(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return map[string]gogofs.FS{"Mock": mock, "Real": real}
+}
+
+func TestWalkDir(t *testing.T) {
+	for name, fs := range scanFilesystems(t) {
+		t.Run(name, func(t *testing.T) {
+			var visited []string
+			err := gogofs.WalkDir(fs, ".", func(path string, entry gogofs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if entry.IsDir() && (entry.Name() == "vendor" || entry.Name() == ".git") {
+					return gogofs.SkipDir
+				}
+				if entry.IsDir() {
+					path += "/"
+				}
+				visited = append(visited, path)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := "./ go.mod main.go models/ models/testdata/ models/testdata/fixture.go models/user.go models/user_gen.go"
+			if got := strings.Join(visited, " "); got != want {
+				t.Errorf("Expected %s\nGot      %s", want, got)
+			}
+		})
+	}
+}
+
+func TestProjectFiles(t *testing.T) {
+	for name, fs := range scanFilesystems(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, test := range []struct {
+				exclude []string
+				want    string
+			}{
+				{nil, "main.go models/user.go models/user_gen.go"},
+				{[]string{"*_gen.go", "vendor/", "models/testdata/", ".git/"}, "main.go models/user.go"},
+				{[]string{}, ".git/hooks/hook.go main.go models/testdata/fixture.go models/user.go models/user_gen.go vendor/github.com/google/uuid/uuid.go"},
+			} {
+				project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept, Exclude: test.exclude})
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				files, err := project.Files()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := strings.Join(files, " "); got != test.want {
+					t.Errorf("Exclude %q:\nExpected %s\nGot      %s", test.exclude, test.want, got)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+)
+
+func TestProjectTextStyle(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"CRLF", "package models\r\n\r\ntype User struct {\r\n\tID int\r\n}\r\n"},
+		{"BOM", "\xef\xbb\xbfpackage models\n\ntype User struct {\n\tID int\n}\n"},
+		{"NoFinalNewline", "package models\n\ntype User struct {\n\tID int\n}"},
+		{"All", "\xef\xbb\xbfpackage models\r\n\r\ntype User struct {\r\n\tID int\r\n}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := gogofs.NewMemFS()
+			fsys.WriteFile("user.go", []byte(tt.content), 0644)
+
+			var diff string
+			project, err := gogo.New(gogo.Options{FS: fsys, ConflictFunc: func(fs gogofs.FS, oldPath, newPath string, info gogo.ChangeInfo) bool {
+				diff = info.Diff
+				return true
+			}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := project.Struct(gogo.StructOpts{Filename: "user.go", Name: "User", Fields: []gogo.StructField{{Name: "ID", Type: "int64"}}}); err != nil {
+				t.Fatal(err)
+			}
+
+			content, _ := fsys.ReadFile("user.go")
+			if want := strings.Replace(tt.content, "ID int", "ID int64", 1); string(content) != want {
+				t.Errorf("Expected %q, got %q", want, content)
+			}
+			if strings.ContainsAny(diff, "\r\ufeff") || strings.Count(diff, "\n-") != 1 || strings.Count(diff, "\n+") != 2 {
+				t.Errorf("Expected only the field in the diff, got:\n%q", diff)
+			}
+
+			// Running again changes nothing
+			if err := project.Struct(gogo.StructOpts{Filename: "user.go", Name: "User", Fields: []gogo.StructField{{Name: "ID", Type: "int64"}}}); err != nil {
+				t.Fatal(err)
+			}
+			if again, _ := fsys.ReadFile("user.go"); string(again) != string(content) {
+				t.Errorf("Expected no change, got %q", again)
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+const typeCheckProject = `# go.mod
+module example.com/app
+
+go 1.24
+# models/user.go
+package models
+
+type User struct {
+	ID   int
+	Name string
+}
+# models/broken.go
+package models
+
+// Not part of the changes, so its errors are not reported
+var Broken int = "broken"
+`
+
+func TestProjectTypeCheck(t *testing.T) {
+	t.Run("Refuse", func(t *testing.T) {
+		fs := gogotest.New(typeCheckProject)
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept, TypeCheck: gogo.TypeCheckRefuse})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Method(gogo.MethodOpts{
+			Filename:     "models/user.go",
+			Name:         "Contact",
+			ReceiverName: "u",
+			ReceiverType: "*User",
+			ReturnType:   "string",
+			Body:         "return u.Email",
+		})
+
+		var typeErr gogo.TypeError
+		if !errors.As(err, &typeErr) {
+			t.Fatalf("Expected a TypeError, got %v", err)
+		}
+		if typeErr.File != "models/user.go" || typeErr.Line != 9 {
+			t.Errorf("Unexpected error position: %v", typeErr)
+		}
+
+		content, _ := fs.ReadFile("models/user.go")
+		if string(content) != string(gogotest.New(typeCheckProject).GetFiles()["models/user.go"]) {
+			t.Errorf("File should not change:\n%s", content)
+		}
+
+		// The refused change is dropped, a valid change to the file is written
+		err = project.Variable(gogo.VariableOpts{
+			Filename:  "models/user.go",
+			Variables: []gogo.Variable{{Name: "Guest", Value: "User{Name: \"guest\"}"}},
+		})
+		if err != nil {
+			t.Fatalf("Expected the valid change to be written, got %v", err)
+		}
+		if err := fs.Assert("Guest"); err != nil {
+			t.Error(err)
+		}
+		if err := fs.Assert("u.Email"); err == nil {
+			t.Error("Refused change should not be written")
+		}
+	})
+
+	t.Run("Mark", func(t *testing.T) {
+		fs := gogotest.New(typeCheckProject)
+
+		var typeErrors []gogo.TypeError
+		project, err := gogo.New(gogo.Options{
+			FS:        fs,
+			TypeCheck: gogo.TypeCheckMark,
+			ConflictFunc: func(_ gogofs.FS, _, _ string, info gogo.ChangeInfo) bool {
+				typeErrors = info.TypeErrors
+				return len(info.TypeErrors) == 0
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = project.Variable(gogo.VariableOpts{
+			Filename:  "models/user.go",
+			Variables: []gogo.Variable{{Name: "Admin", Value: "User{Role: \"admin\"}"}},
+		})
+		if !errors.Is(err, gogo.ErrRejected) {
+			t.Fatalf("Expected the change to be rejected, got %v", err)
+		}
+
+		if len(typeErrors) != 1 || typeErrors[0].Msg != "unknown field Role in struct literal of type User" {
+			t.Errorf("Expected the unknown field to be flagged, got %v", typeErrors)
+		}
+		if err := fs.Assert("Admin"); err == nil {
+			t.Error("Flagged change should have been rejected")
+		}
+	})
+
+	t.Run("ValidChanges", func(t *testing.T) {
+		fs := gogotest.New(typeCheckProject)
+		project, err := gogo.New(gogo.Options{
+			FS:                 fs,
+			ConflictFunc:       gogo.ConflictAccept,
+			TypeCheck:          gogo.TypeCheckRefuse,
+			InitialPackageName: "api",
+			Buffered:           true,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Uses the standard library and a package of the project
+		err = project.File(gogo.FileSpec{
+			Path: "api/format.go",
+			Functions: []gogo.FunctionOpts{{
+				Name:       "Format",
+				Parameters: []gogo.Parameter{{Name: "u", Type: "*./models.User"}},
+				ReturnType: "string",
+				Body:       "return fmt.Sprintf(\"%d: %s\", u.ID, u.Name)",
+			}},
+			Imports: []string{"fmt"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Uses a declaration still buffered in another file of the package
+		err = project.Function(gogo.FunctionOpts{
+			Filename:   "api/handler.go",
+			Name:       "Handle",
+			Parameters: []gogo.Parameter{{Name: "u", Type: "*./models.User"}},
+			ReturnType: "string",
+			Body:       "return Format(u)",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := project.Flush(); err != nil {
+			t.Fatal(err)
+		}
+		if err := fs.Assert("func Handle(u *models.User) string"); err != nil {
+			t.Error(err)
+		}
+	})
+}
@@ -0,0 +1,271 @@
+package tests
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+	"github.com/guillermo/gogo/gogotest"
+)
+
+// crashFS simulates a process dying in the middle of a write: the rename of a
+// temp file over a Go file panics
+type crashFS struct {
+	gogofs.FS
+}
+
+func (fs crashFS) Rename(oldpath, newpath string) error {
+	if strings.HasSuffix(newpath, ".go") {
+		panic("crash")
+	}
+	return fs.FS.Rename(oldpath, newpath)
+}
+
+// crash runs f and recovers the simulated crash
+func crash(t *testing.T, f func() error) {
+	t.Helper()
+	defer func() {
+		if recover() == nil {
+			t.Fatal("Expected a crash")
+		}
+	}()
+	f()
+}
+
+func TestProjectWrite(t *testing.T) {
+	t.Run("NoLeftovers", func(t *testing.T) {
+		fs := gogotest.New(`# user.go
+package models
+`)
+
+		var tempPath string
+		project, err := gogo.New(gogo.Options{
+			FS: fs,
+			ConflictFunc: func(_ gogofs.FS, _, newPath string, _ gogo.ChangeInfo) bool {
+				tempPath = newPath
+				return true
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := generateModel(project, "user.go", "User"); err != nil {
+			t.Fatal(err)
+		}
+
+		// The toolchain ignores files starting with a dot and not ending in .go
+		if base := filepath.Base(tempPath); !strings.HasPrefix(base, ".") || strings.HasSuffix(base, ".go") {
+			t.Errorf("Temp file %s would be compiled", tempPath)
+		}
+
+		files := fs.GetFiles()
+		if len(files) != 1 || files["user.go"] == nil {
+			t.Errorf("Expected only user.go, got:\n%s", fs)
+		}
+	})
+
+	t.Run("PermissionsArePreserved", func(t *testing.T) {
+		fs := gogotest.New("")
+		if err := fs.WriteFile("secret.go", []byte("package models\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		project, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, filename := range []string{"secret.go", "public.go"} {
+			if err := generateModel(project, filename, "User"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		for filename, want := range map[string]os.FileMode{"secret.go": 0600, "public.go": 0644} {
+			info, err := fs.Stat(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Mode().Perm() != want {
+				t.Errorf("Expected %s to have mode %v, got %v", filename, want, info.Mode().Perm())
+			}
+		}
+	})
+
+	t.Run("PermissionsArePreservedOnDisk", func(t *testing.T) {
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "secret.go"), []byte("package models\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		project, err := gogo.NewFS(dir, gogo.Options{ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := generateModel(project, "secret.go", "User"); err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := os.Stat(filepath.Join(dir, "secret.go"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+		}
+
+		entries, _ := os.ReadDir(dir)
+		for _, entry := range entries {
+			if entry.Name() != "secret.go" && entry.Name() != gogofs.LockFile {
+				t.Errorf("Unexpected leftover %s", entry.Name())
+			}
+		}
+	})
+
+	t.Run("RecoverAcceptedWrite", func(t *testing.T) {
+		fs := gogotest.New(`# user.go
+package models
+`)
+		project, err := gogo.New(gogo.Options{FS: crashFS{fs}, ConflictFunc: gogo.ConflictAccept})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// The change was accepted, but the process died before renaming it
+		crash(t, func() error { return generateModel(project, "user.go", "User") })
+		if content, _ := fs.ReadFile("user.go"); string(content) != "package models\n" {
+			t.Fatalf("The change should not be applied yet:\n%s", content)
+		}
+
+		// The next run completes it
+		if _, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept}); err != nil {
+			t.Fatal(err)
+		}
+		if content, _ := fs.ReadFile("user.go"); !strings.Contains(string(content), "type User struct") {
+			t.Errorf("Expected the change to be completed:\n%s", content)
+		}
+		if files := fs.GetFiles(); len(files) != 1 {
+			t.Errorf("Expected only user.go, got:\n%s", fs)
+		}
+	})
+
+	t.Run("RecoverUnacceptedWrite", func(t *testing.T) {
+		fs := gogotest.New(`# user.go
+package models
+`)
+		project, err := gogo.New(gogo.Options{
+			FS: fs,
+			ConflictFunc: func(_ gogofs.FS, _, _ string, _ gogo.ChangeInfo) bool {
+				panic("crash while asking")
+			},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		crash(t, func() error { return generateModel(project, "user.go", "User") })
+		if len(fs.GetFiles()) == 1 {
+			t.Fatal("Expected leftovers of the interrupted write")
+		}
+
+		// The next run rolls it back
+		if _, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept}); err != nil {
+			t.Fatal(err)
+		}
+		files := fs.GetFiles()
+		if len(files) != 1 || string(files["user.go"]) != "package models\n" {
+			t.Errorf("Expected user.go untouched and nothing else, got:\n%s", fs)
+		}
+	})
+
+	t.Run("RecoverKeepsNewerChanges", func(t *testing.T) {
+		previous := sha256.Sum256([]byte("package models\n"))
+		fs := gogotest.New(`# user.go
+package models
+
+// Edited after the interrupted run
+# .gogo-temp.tmp
+package models
+
+type User struct{}
+# .gogo-pending
+[{"temp": ".gogo-temp.tmp", "target": "user.go", "accepted": true, "previous": "` + hex.EncodeToString(previous[:]) + `"}]
+`)
+
+		if _, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept}); err != nil {
+			t.Fatal(err)
+		}
+
+		files := fs.GetFiles()
+		if len(files) != 1 || !strings.Contains(string(files["user.go"]), "// Edited after the interrupted run") {
+			t.Errorf("Expected the newer user.go and nothing else, got:\n%s", fs)
+		}
+	})
+
+	t.Run("RecoverLegacyLeftovers", func(t *testing.T) {
+		// Older versions wrote through .gogo-*.go temp files and moved the
+		// file being replaced to a .backup file
+		fs := gogotest.New(`# user.go
+package models
+
+type User struct{}
+# user.go.backup
+package models
+# .gogo-123.go
+package models
+
+type User struct{}
+# models/role.go.backup
+package models
+
+type Role string
+# models/.gogo-456.go
+package models
+
+type Role int
+# notes.backup
+Not written by gogo
+`)
+
+		if _, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept}); err != nil {
+			t.Fatal(err)
+		}
+
+		// Completed writes keep their file, interrupted ones get it back
+		files := fs.GetFiles()
+		if len(files) != 3 || !strings.Contains(string(files["user.go"]), "type User struct{}") || files["notes.backup"] == nil {
+			t.Errorf("Expected user.go, models/role.go and notes.backup, got:\n%s", fs)
+		}
+		if content := string(files["models/role.go"]); !strings.Contains(content, "type Role string") {
+			t.Errorf("Expected models/role.go restored, got:\n%s", content)
+		}
+	})
+
+	t.Run("RecoverWaitsForOtherWriters", func(t *testing.T) {
+		fs := gogotest.New(`# .gogo-temp.tmp
+package models
+# .gogo-pending
+[{"temp": ".gogo-temp.tmp", "target": "user.go"}]
+`)
+
+		// Another process is writing, the journal is its own
+		unlock, err := fs.Lock(gogofs.LockFile, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer unlock()
+
+		if _, err := gogo.New(gogo.Options{FS: fs, ConflictFunc: gogo.ConflictAccept}); err != nil {
+			t.Fatal(err)
+		}
+		if len(fs.GetFiles()) != 2 {
+			t.Errorf("Expected the journal to be left alone, got:\n%s", fs)
+		}
+	})
+}
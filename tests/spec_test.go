@@ -0,0 +1,189 @@
+package tests
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/guillermo/gogo"
+	gogofs "github.com/guillermo/gogo/fs"
+)
+
+const userSpecJSON = `{
+  "version": 1,
+  "include": ["common/status.json"],
+  "files": [{
+    "path": "models/user.go",
+    "structs": [{"name": "User", "fields": [
+      {"name": "ID", "type": "int64", "tag": "json:\"id\""},
+      {"name": "Status", "type": "Status", "tag": "json:\"status\""}
+    ]}],
+    "methods": [{"name": "Active", "receiver": {"name": "u", "type": "User"}, "returns": "bool", "body": "return u.Status == StatusActive"}],
+    "consts": [{"name": "MaxUsers", "value": "100"}]
+  }]
+}`
+
+const statusSpecJSON = `{
+  "version": 1,
+  "files": [{
+    "path": "models/status.go",
+    "enums": [
+      {"name": "Status", "values": ["active", "in_progress"]},
+      {"name": "Level", "type": "int", "values": ["low", "high"]}
+    ]
+  }]
+}`
+
+// specProject returns a project on an empty module
+func specProject(t *testing.T) (*gogo.Project, *gogofs.MemFS) {
+	t.Helper()
+	fsys := gogofs.NewMemFS()
+	fsys.WriteFile("go.mod", []byte("module example.com/app\n"), 0644)
+	project, err := gogo.New(gogo.Options{FS: fsys, ConflictFunc: gogo.ConflictAccept, InitialPackageName: "models"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return project, fsys
+}
+
+func TestApplySpec(t *testing.T) {
+	project, fsys := specProject(t)
+	fsys.MkdirAll("common", 0755)
+	fsys.WriteFile("common/status.json", []byte(statusSpecJSON), 0644)
+
+	if err := gogo.ApplySpec(project, strings.NewReader(userSpecJSON)); err != nil {
+		t.Fatal(err)
+	}
+
+	user, _ := fsys.ReadFile("models/user.go")
+	status, _ := fsys.ReadFile("models/status.go")
+	for _, want := range []string{"ID     int64  `json:\"id\"`", "func (u User) Active() bool {", "const MaxUsers = 100"} {
+		if !strings.Contains(string(user), want) {
+			t.Errorf("Expected %q in:\n%s", want, user)
+		}
+	}
+	for _, want := range []string{"type Status string", `StatusInProgress Status = "in_progress"`, "type Level int", "LevelHigh Level = 1"} {
+		if !strings.Contains(string(status), want) {
+			t.Errorf("Expected %q in:\n%s", want, status)
+		}
+	}
+
+	// Applying it again changes nothing
+	if err := gogo.ApplySpec(project, strings.NewReader(userSpecJSON)); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := fsys.ReadFile("models/user.go"); string(again) != string(user) {
+		t.Errorf("Expected no change, got:\n%s", again)
+	}
+}
+
+func TestApplySpecFS(t *testing.T) {
+	project, fsys := specProject(t)
+	specs := fstest.MapFS{
+		"specs/user.json":             {Data: []byte(strings.Replace(userSpecJSON, "common/", "../common/", 1))},
+		"common/status.json":          {Data: []byte(statusSpecJSON)},
+		"cycle/a.json":                {Data: []byte(`{"version": 1, "include": ["b.json"]}`)},
+		"cycle/b.json":                {Data: []byte(`{"version": 1, "include": ["a.json"]}`)},
+		"broken/include.json":         {Data: []byte(`{"version": 1, "include": ["bad.json"]}`)},
+		"broken/bad.json":             {Data: []byte(`{"version": 1, "files": [{}]}`)},
+		"broken/missing_include.json": {Data: []byte(`{"version": 1, "include": ["missing.json"]}`)},
+	}
+
+	if err := gogo.ApplySpecFS(project, specs, "specs/user.json"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fsys.Stat("models/status.go"); err != nil {
+		t.Errorf("Expected the included spec applied: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"cycle/a.json", "cycle/b.json#/include/0: include cycle through cycle/a.json"},
+		{"broken/include.json", "broken/bad.json#/files/0/path: is required"},
+		{"broken/missing_include.json", "broken/missing_include.json#/include/0: "},
+	}
+	for _, tt := range tests {
+		err := gogo.ApplySpecFS(project, specs, tt.name)
+		var specErr *gogo.SpecError
+		if !errors.As(err, &specErr) || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected a SpecError with %q, got %v", tt.name, tt.want, err)
+		}
+	}
+}
+
+func TestApplySpecValidation(t *testing.T) {
+	project, fsys := specProject(t)
+	spec := `{
+  "version": 2,
+  "files": [{
+    "path": "models/user.go",
+    "structs": [{"name": "User", "fields": [{"name": "first name", "type": "string"}, {"name": "ID"}]}],
+    "enums": [{"name": "Status", "values": []}],
+    "extra": true
+  }, "models/other.go"]
+}`
+	err := gogo.ApplySpec(project, strings.NewReader(spec))
+	if err == nil {
+		t.Fatal("Expected an invalid spec")
+	}
+
+	var pointers []string
+	var joined interface{ Unwrap() []error }
+	if errors.As(err, &joined) {
+		for _, err := range joined.Unwrap() {
+			var specErr *gogo.SpecError
+			if errors.As(err, &specErr) {
+				pointers = append(pointers, specErr.Pointer)
+			}
+		}
+	}
+	want := []string{
+		"/files/0/enums/0/values",
+		"/files/0/extra",
+		"/files/0/structs/0/fields/0/name",
+		"/files/0/structs/0/fields/1/type",
+		"/files/1",
+		"/version",
+	}
+	if strings.Join(pointers, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected errors at %v, got %v:\n%v", want, pointers, err)
+	}
+	if _, err := fsys.Stat("models/user.go"); err == nil {
+		t.Error("Expected nothing written for an invalid spec")
+	}
+
+	if err := gogo.ApplySpec(project, strings.NewReader("{")); err == nil {
+		t.Error("Expected an error for malformed JSON")
+	}
+
+	// Specs valid against the schema but not for Project.File change nothing
+	spec = `{
+  "version": 1,
+  "files": [
+    {"path": "models/ids.go", "types": [{"name": "ID", "definition": "int64"}]},
+    {"path": "models/user.go", "structs": [{"name": "User"}], "functions": [{"name": "NewUser"}]}
+  ]
+}`
+	err = gogo.ApplySpec(project, strings.NewReader(spec))
+	if !errors.Is(err, gogo.ErrInvalidOptions) || !strings.Contains(err.Error(), "models/user.go") {
+		t.Errorf("Expected invalid options for models/user.go, got %v", err)
+	}
+	if _, err := fsys.Stat("models/ids.go"); err == nil {
+		t.Error("Expected nothing written for an invalid spec")
+	}
+}
+
+func TestSpecSchema(t *testing.T) {
+	var schema map[string]any
+	if err := json.Unmarshal([]byte(gogo.SpecSchema), &schema); err != nil {
+		t.Fatal(err)
+	}
+	version := schema["properties"].(map[string]any)["version"].(map[string]any)["const"]
+	if version != float64(gogo.SpecVersion) {
+		t.Errorf("Expected the schema for version %d, got %v", gogo.SpecVersion, version)
+	}
+}
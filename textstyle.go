@@ -0,0 +1,67 @@
+package gogo
+
+import "bytes"
+
+// utf8BOM is the byte order mark some editors put at the start of UTF-8 files
+var utf8BOM = []byte("\xef\xbb\xbf")
+
+// textStyle is how an existing file differs from the canonical form gofmt
+// produces. Files are edited in canonical form and written back in the style
+// they had, so regenerating a file doesn't rewrite every line of it.
+type textStyle struct {
+	crlf           bool // Lines end in \r\n
+	bom            bool // Starts with a byte order mark
+	noFinalNewline bool // The last line has no line ending
+}
+
+// detectStyle returns the style of the content of a file. The first line
+// ending decides the line endings of the whole file.
+func detectStyle(content []byte) textStyle {
+	var style textStyle
+	style.bom = bytes.HasPrefix(content, utf8BOM)
+	if i := bytes.IndexByte(content, '\n'); i > 0 && content[i-1] == '\r' {
+		style.crlf = true
+	}
+	style.noFinalNewline = len(content) > 0 && content[len(content)-1] != '\n'
+	return style
+}
+
+// canonical returns content without the byte order mark and with \n line
+// endings. The missing final newline is left to the formatter.
+func (s textStyle) canonical(content []byte) []byte {
+	if s.bom {
+		content = bytes.TrimPrefix(content, utf8BOM)
+	}
+	if s.crlf {
+		content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	}
+	return content
+}
+
+// apply converts canonical content back to the style
+func (s textStyle) apply(content []byte) []byte {
+	if len(content) == 0 {
+		return content
+	}
+	if s.noFinalNewline {
+		content = bytes.TrimSuffix(content, []byte("\n"))
+	}
+	if s.crlf {
+		content = bytes.ReplaceAll(content, []byte("\n"), []byte("\r\n"))
+	}
+	if s.bom {
+		content = append(append([]byte{}, utf8BOM...), content...)
+	}
+	return content
+}
+
+// normalizeText returns content in canonical form whatever its style, so
+// line endings, byte order marks and final newlines don't show up in diffs
+func normalizeText(content []byte) []byte {
+	content = bytes.TrimPrefix(content, utf8BOM)
+	content = bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		content = append(content[:len(content):len(content)], '\n')
+	}
+	return content
+}
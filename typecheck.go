@@ -0,0 +1,302 @@
+package gogo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TypeCheckMode tells what a project does with changes that don't type-check
+type TypeCheckMode int
+
+const (
+	TypeCheckOff    TypeCheckMode = iota // Don't type-check changes (default)
+	TypeCheckMark                        // Report the errors in ChangeInfo.TypeErrors and let the ConflictFunc decide
+	TypeCheckRefuse                      // Don't apply changes with errors and return them
+)
+
+// TypeError is a type-checking error in the new content of a file
+type TypeError struct {
+	File   string // File with the error, relative to the project root
+	Line   int    // Line of the error, starting at 1
+	Column int    // Column of the error, starting at 1
+	Msg    string // Error message
+}
+
+func (e TypeError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Msg)
+}
+
+// typeChecker type-checks the packages of a project as they will be once the
+// pending changes are written. Packages of the project modules are loaded
+// from the project filesystem, other packages from source with go/build.
+type typeChecker struct {
+	mu       sync.Mutex // Serializes checks, the importer isn't safe for concurrent use
+	fset     *token.FileSet
+	importer types.ImporterFrom     // Packages outside the project, cached across checks
+	parsed   map[string]*parsedFile // Files of the project by name, reused while unchanged
+}
+
+// parsedFile is a parsed file of the project. Type checking doesn't modify
+// syntax trees, so they can be shared between checks.
+type parsedFile struct {
+	sum  [sha256.Size]byte
+	file *ast.File
+	err  error
+}
+
+// newTypeChecker creates a type checker with an empty cache of imports
+func newTypeChecker() *typeChecker {
+	fset := token.NewFileSet()
+	return &typeChecker{
+		fset:     fset,
+		importer: importer.ForCompiler(fset, "source", nil).(types.ImporterFrom),
+		parsed:   make(map[string]*parsedFile),
+	}
+}
+
+// parse parses a file of the project, unless it was already parsed with the
+// same content
+func (c *typeChecker) parse(filename string, content []byte) (*ast.File, error) {
+	sum := sha256.Sum256(content)
+	if parsed, ok := c.parsed[filename]; ok {
+		if parsed.sum == sum {
+			return parsed.file, parsed.err
+		}
+		if parsed.file != nil {
+			if file := c.fset.File(parsed.file.Package); file != nil {
+				c.fset.RemoveFile(file)
+			}
+		}
+	}
+
+	file, err := parser.ParseFile(c.fset, filename, content, parser.ParseComments)
+	c.parsed[filename] = &parsedFile{sum: sum, file: file, err: err}
+	return file, err
+}
+
+// typeCheck type-checks the package of filename with its new content and the
+// pending changes of the other files of the package. It only returns the
+// errors in filename; missing imports are not reported, as they depend on the
+// environment rather than on the change.
+func (p *Project) typeCheck(filename string, content []byte) ([]TypeError, error) {
+	p.checker.mu.Lock()
+	defer p.checker.mu.Unlock()
+
+	filename = path.Clean(filename)
+	check := &packageCheck{
+		p:        p,
+		checker:  p.checker,
+		changed:  filename,
+		content:  content,
+		packages: make(map[string]*types.Package),
+	}
+
+	var typeErrors []TypeError
+	dir := path.Dir(filename)
+	importPath, err := p.ImportPathFor(dir)
+	if err != nil {
+		importPath = dir
+	}
+
+	_, err = check.checkPackage(dir, importPath, func(err error) {
+		var typeErr types.Error
+		if !errors.As(err, &typeErr) {
+			return
+		}
+		pos := typeErr.Fset.Position(typeErr.Pos)
+		if pos.Filename != filename || strings.HasPrefix(typeErr.Msg, "could not import") {
+			return
+		}
+		typeErrors = append(typeErrors, TypeError{File: filename, Line: pos.Line, Column: pos.Column, Msg: typeErr.Msg})
+	})
+
+	// Type errors are reported above, anything else means the package could
+	// not be checked at all
+	var typeErr types.Error
+	if err != nil && !errors.As(err, &typeErr) {
+		return nil, fmt.Errorf("failed to type-check %s: %w", filename, err)
+	}
+	return typeErrors, nil
+}
+
+// packageCheck is a single type check, with its own cache of the packages of
+// the project, as their content changes between checks
+type packageCheck struct {
+	p        *Project
+	checker  *typeChecker
+	changed  string // File with new content
+	content  []byte // New content of the changed file
+	packages map[string]*types.Package
+}
+
+// ImportFrom implements types.ImporterFrom, loading packages of the project
+// from the project filesystem
+func (c *packageCheck) ImportFrom(importPath, srcDir string, mode types.ImportMode) (*types.Package, error) {
+	if pkg, ok := c.packages[importPath]; ok {
+		if pkg == nil {
+			return nil, fmt.Errorf("import cycle through %s", importPath)
+		}
+		return pkg, nil
+	}
+
+	dir, ok := c.p.localDir(importPath)
+	if !ok {
+		return c.checker.importer.ImportFrom(importPath, srcDir, mode)
+	}
+
+	// Errors in the imported package are not reported, they are not part of
+	// the change
+	c.packages[importPath] = nil
+	pkg, err := c.checkPackage(dir, importPath, func(error) {})
+	if err != nil && pkg == nil {
+		delete(c.packages, importPath)
+		return nil, err
+	}
+	c.packages[importPath] = pkg
+	return pkg, nil
+}
+
+// Import implements types.Importer
+func (c *packageCheck) Import(importPath string) (*types.Package, error) {
+	return c.ImportFrom(importPath, "", 0)
+}
+
+// checkPackage type-checks the package in dir
+func (c *packageCheck) checkPackage(dir, importPath string, report func(error)) (*types.Package, error) {
+	files, err := c.parsePackage(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no Go files in %s", dir)
+	}
+
+	conf := types.Config{
+		Importer:    c,
+		Error:       report,
+		FakeImportC: true,
+	}
+	return conf.Check(importPath, c.checker.fset, files, nil)
+}
+
+// parsePackage parses the files of the package in dir, with the pending
+// changes, that match the build context. Test files and files of other
+// packages are left out.
+func (c *packageCheck) parsePackage(dir string) ([]*ast.File, error) {
+	sources := make(map[string][]byte)
+	for filename, content := range c.p.cache.overlay(dir) {
+		sources[path.Clean(filename)] = content
+	}
+	if path.Dir(c.changed) == dir {
+		sources[c.changed] = c.content
+	}
+
+	// Read the files not in the overlay
+	entries, err := c.p.fs.ReadDir(dir)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		filename := path.Join(dir, entry.Name())
+		if entry.IsDir() || !strings.HasSuffix(filename, ".go") || sources[filename] != nil {
+			continue
+		}
+		content, err := c.p.fs.ReadFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", filename, err)
+		}
+		sources[filename] = content
+	}
+
+	// Select the files of the package for the current platform
+	ctxt := build.Default
+	ctxt.OpenFile = func(filename string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(sources[path.Clean(filename)])), nil
+	}
+	ctxt.JoinPath = path.Join
+
+	var filenames []string
+	for filename := range sources {
+		name := path.Base(filename)
+		if !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		if match, err := ctxt.MatchFile(dir, name); err != nil || !match {
+			continue
+		}
+		filenames = append(filenames, filename)
+	}
+	sort.Strings(filenames)
+
+	var files []*ast.File
+	packageName := ""
+	for _, filename := range filenames {
+		file, err := c.checker.parse(filename, sources[filename])
+		if err != nil {
+			if filename == c.changed {
+				return nil, fmt.Errorf("failed to parse %s: %w", filename, err)
+			}
+			// Broken files that are not part of the change are left out
+			continue
+		}
+
+		// The changed file decides the package, other packages in the same
+		// directory (e.g. documentation or ignored files) are left out
+		if filename == c.changed || packageName == "" {
+			packageName = file.Name.Name
+		}
+		files = append(files, file)
+	}
+
+	kept := files[:0]
+	for _, file := range files {
+		if file.Name.Name == packageName {
+			kept = append(kept, file)
+		}
+	}
+	return kept, nil
+}
+
+// localDir returns the directory of a package of the project modules
+func (p *Project) localDir(importPath string) (string, bool) {
+	var modules []goModule
+	if uses, err := p.readWorkspace(); err == nil {
+		for _, use := range uses {
+			if mod, err := p.readModule(use); err == nil {
+				modules = append(modules, mod)
+			}
+		}
+	} else if mod, err := p.readModule("."); err == nil {
+		modules = append(modules, mod)
+	}
+
+	// The longest module path wins, nested modules are more specific
+	sort.Slice(modules, func(i, j int) bool { return len(modules[i].Path) > len(modules[j].Path) })
+	for _, mod := range modules {
+		if importPath != mod.Path && !strings.HasPrefix(importPath, mod.Path+"/") {
+			continue
+		}
+		dir := path.Join(mod.Dir, strings.TrimPrefix(importPath, mod.Path))
+
+		// The directory could belong to a nested module
+		if owner, err := p.findModule(dir); err != nil || owner.Dir != mod.Dir {
+			return "", false
+		}
+		return dir, true
+	}
+	return "", false
+}